@@ -10,11 +10,14 @@ type FormInputs = {
   login: string;
   password: string;
   confirmPassword: string;
+  partnerInn: string;
+  partnerName: string;
 };
 
 const getFriendlyErrorMessage = (error: string): string => {
   if (error.includes("Invalid request body")) return "Неверно сформирован запрос";
   if (error.includes("duplicate key")) return "Пользователь с таким логином уже существует";
+  if (error.includes("ИНН организации") || error.includes("Название организации")) return error;
   if (error.includes("Registration failed")) return "Ошибка регистрации. Проверьте введённые данные.";
   return "Что-то пошло не так. Пожалуйста, попробуйте ещё раз.";
 };
@@ -49,6 +52,8 @@ export default function RegisterPage() {
             login: data.login,
             password: data.password,
             password_confirmation: data.confirmPassword,
+            partner_inn: data.partnerInn,
+            partner_name: data.partnerName,
           }),
         }
       );
@@ -65,7 +70,7 @@ export default function RegisterPage() {
         throw new Error(message);
       }
 
-      setSuccess("Регистрация прошла успешно. Перенаправление на страницу входа...");
+      setSuccess("Регистрация прошла успешно. Заявка на присоединение к организации отправлена менеджеру. Перенаправление на страницу входа...");
       setTimeout(() => router.push("/login"), 2000);
     } catch (err: unknown) {
       console.error("Ошибка регистрации:", err);
@@ -168,6 +173,44 @@ export default function RegisterPage() {
               {errors.confirmPassword && <p className="text-discord-danger text-xs mt-1">{errors.confirmPassword.message}</p>}
             </div>
 
+            <div>
+              <label htmlFor="partnerInn" className="block text-discord-text-secondary text-sm mb-1.5 font-medium">
+                ИНН организации <span className="text-discord-danger">*</span>
+              </label>
+              <input
+                id="partnerInn"
+                type="text"
+                inputMode="numeric"
+                placeholder="10 или 12 цифр"
+                className={`block w-full px-4 py-2 rounded-md bg-discord-input border text-discord-text placeholder:text-discord-text-muted focus:outline-none focus:ring-2 focus:ring-discord-accent focus:border-transparent transition duration-150 ${errors.partnerInn ? 'border-discord-danger' : 'border-discord-border'}`}
+                {...register("partnerInn", {
+                  required: "ИНН организации обязателен",
+                  pattern: {
+                    value: /^(\d{10}|\d{12})$/,
+                    message: "ИНН должен содержать 10 или 12 цифр"
+                  }
+                })}
+              />
+              {errors.partnerInn && <p className="text-discord-danger text-xs mt-1">{errors.partnerInn.message}</p>}
+            </div>
+
+            <div>
+              <label htmlFor="partnerName" className="block text-discord-text-secondary text-sm mb-1.5 font-medium">
+                Название организации <span className="text-discord-danger">*</span>
+              </label>
+              <input
+                id="partnerName"
+                type="text"
+                placeholder="Например, ООО Ромашка"
+                className={`block w-full px-4 py-2 rounded-md bg-discord-input border text-discord-text placeholder:text-discord-text-muted focus:outline-none focus:ring-2 focus:ring-discord-accent focus:border-transparent transition duration-150 ${errors.partnerName ? 'border-discord-danger' : 'border-discord-border'}`}
+                {...register("partnerName", { required: "Название организации обязательно" })}
+              />
+              {errors.partnerName && <p className="text-discord-danger text-xs mt-1">{errors.partnerName.message}</p>}
+              <p className="text-discord-text-muted text-xs mt-1">
+                Менеджер проверит заявку и привяжет вашу учетную запись к организации
+              </p>
+            </div>
+
             {isLoading && (
               <div className="w-full bg-discord-input rounded-full h-2 overflow-hidden">
                 <div className="h-full bg-discord-accent rounded-full animate-pulse"></div>
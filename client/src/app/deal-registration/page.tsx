@@ -103,7 +103,17 @@ export default function DealRegistrationPage() {
 
       try {
         const client = await findEndClientByINN(endClientInnValue);
-        if (client) {
+        if (client?.external) {
+          // Данные из внешнего реестра: клиент будет создан вместе с заявкой
+          setFoundEndClient(null);
+          setSearchStatusMessage(`Найден в реестре: ${client.name}. Проверьте данные.`);
+          setValue('endClientId', null);
+          setValue('endClientName', client.name);
+          setValue('endClientCity', client.city || '');
+          setValue('endClientFullAddress', client.full_address || '');
+          setValue('endClientContactDetails', client.contact_person_details || '');
+          trigger('endClientName');
+        } else if (client) {
           setFoundEndClient(client);
           setSearchStatusMessage(`Клиент найден: ${client.name}`);
           setValue('endClientId', client.id);
@@ -628,6 +638,7 @@ export default function DealRegistrationPage() {
                     <input
                       id="attachmentFile"
                       type="file"
+                              accept=".pdf,.docx,.xlsx,.zip,.jpg,.jpeg,.png,.txt,.csv"
                               multiple // <<< Разрешаем выбор нескольких файлов
                               className="hidden"
                               {...attachmentFileRegisterProps}
@@ -644,7 +655,7 @@ export default function DealRegistrationPage() {
                           <p className="pl-1 pointer-events-none">или нажмите, чтобы выбрать</p>
                         </div>
                         <p className="text-xs text-gray-400 pointer-events-none">
-                          (PDF, DOC, DOCX, XLS, XLSX, JPG, PNG и др.)
+                          (PDF, DOCX, XLSX, ZIP, JPG, PNG, TXT)
                         </p>
                       </div>
                     </div>
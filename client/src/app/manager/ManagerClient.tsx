@@ -208,6 +208,7 @@ export default function ManagerClient() {
             {r.status}
           </button>
           {r.manager_comment && <span title="Есть комментарий" className="inline-block w-2.5 h-2.5 rounded-full bg-discord-accent" />}
+          {r.is_conflicting && <span title="Сделка ранее зарегистрирована другим партнером" className="text-discord-danger font-semibold">!</span>}
         </div>
       )},
       { id: 'created_at', header: 'Создана', sortable: true, align: 'right', className: 'col-span-full md:col-span-2 md:text-right', accessor: (r) => (
@@ -141,8 +141,14 @@ export default function RequestDetailsPage() {
                     <div className="border border-discord-border p-4 rounded-lg bg-discord-background space-y-2">
                         <h3 className="text-lg font-semibold text-discord-text mb-2">Детали проекта</h3>
                         <DetailItem label="Название проекта" value={request.project_name} />
-                        <DetailItem label="Количество" value={request.quantity} />
-                        <DetailItem label="Цена за ед." value={request.unit_price ? parseFloat(request.unit_price).toFixed(2) : null} />
+                        {request.items?.map(item => (
+                            <DetailItem
+                                key={item.id}
+                                label={`${item.position}. ${item.product_name}${item.sku ? ` (${item.sku})` : ''}`}
+                                value={`${item.quantity} × ${parseFloat(item.unit_price).toFixed(2)}${parseFloat(item.discount_percent) > 0 ? `, скидка ${item.discount_percent}%` : ''} = ${parseFloat(item.line_total).toFixed(2)}`}
+                            />
+                        ))}
+                        <DetailItem label="Сумма" value={request.total_price ? `${parseFloat(request.total_price).toFixed(2)} ${request.currency ?? 'RUB'}` : null} />
                         <DetailItem label="Описание/ТЗ" value={request.deal_state_description} />
                     </div>
 
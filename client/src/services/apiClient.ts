@@ -30,6 +30,13 @@ function extractMessage(data: unknown, fallback: string): string {
     const rec = data as Record<string, unknown>;
     if (typeof rec.error === 'string') return rec.error;
     if (typeof rec.message === 'string') return rec.message;
+    // Ошибки валидации по полям: {"errors": [{"field": "...", "message": "..."}]}
+    if (Array.isArray(rec.errors) && rec.errors.length > 0) {
+      const messages = rec.errors
+        .map((e) => (e && typeof e === 'object' ? (e as Record<string, unknown>).message : undefined))
+        .filter((m): m is string => typeof m === 'string');
+      if (messages.length > 0) return messages.join('; ');
+    }
   }
   return fallback;
 }
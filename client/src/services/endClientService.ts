@@ -8,6 +8,7 @@ export interface EndClient {
   inn?: string;                  // omitempty -> optional
   full_address?: string;         // omitempty -> optional
   contact_person_details?: string; // omitempty -> optional
+  external?: boolean;            // true — найден во внешнем реестре, еще не сохранен
   created_at: string;           // time.Time -> string
   updated_at: string;           // time.Time -> string
 }
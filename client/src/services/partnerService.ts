@@ -1,4 +1,4 @@
-import { apiFetch } from './apiClient';
+import { apiFetch, PaginatedResponse } from './apiClient';
 
 // Интерфейс Partner на основе server/models/partner.go
 export interface Partner {
@@ -7,15 +7,31 @@ export interface Partner {
   address?: string;        // omitempty -> optional
   inn?: string;            // omitempty -> optional
   partner_status?: string; // omitempty -> optional
+  is_distributor: boolean;
   assigned_manager_id?: number; // *int -> optional number
   created_at: string;      // time.Time -> string
   updated_at: string;      // time.Time -> string
 }
 
+export interface PartnerListParams {
+  search?: string;       // Название или начало ИНН
+  status?: string;       // active | pending | inactive
+  distributor?: boolean; // Только дистрибьюторы (для выбора distributor_id)
+  page?: number;
+  limit?: number;
+}
+
 /**
- * Fetches the list of all available partners.
- * Throws ApiError on failure. Returns Partner[] on success.
+ * Fetches a page of partners matching the filters.
+ * Throws ApiError on failure.
  */
-export async function getPartners(): Promise<Partner[]> {
-  return apiFetch<Partner[]>('/api/partners');
-}
\ No newline at end of file
+export async function getPartners(params: PartnerListParams = {}): Promise<PaginatedResponse<Partner>> {
+  const query = new URLSearchParams();
+  if (params.search) query.append('search', params.search);
+  if (params.status) query.append('status', params.status);
+  if (params.distributor) query.append('distributor', 'true');
+  if (params.page) query.append('page', params.page.toString());
+  if (params.limit) query.append('limit', params.limit.toString());
+  const qs = query.toString();
+  return apiFetch<PaginatedResponse<Partner>>(qs ? `/api/partners?${qs}` : '/api/partners');
+}
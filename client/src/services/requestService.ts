@@ -41,14 +41,18 @@ export interface Product {
 export interface RequestItem {
     id: number;
     request_id: number;
-    product_id?: number;
-    custom_item_sku?: string;
-    custom_item_name?: string;
-    custom_item_description?: string;
+    position: number;
+    product_name: string;
+    sku?: string;
     quantity: number;
-    unit_price?: number;
-    total_price?: number;
-    product?: Product;
+    unit_price: string; // Приходит как строка
+    discount_percent: string;
+    line_total: string; // Рассчитывается сервером
+    // Продукт каталога по SKU и прайсовая цена на момент регистрации
+    product_id?: number;
+    list_price?: string;
+    list_currency?: string;
+    list_discount_percent?: string; // Скидка от прайса, рассчитывается сервером
 }
 
 export interface User {
@@ -83,6 +87,8 @@ export interface Request {
   partner_activities?: string;
   deal_state_description?: string;
   estimated_close_date?: string;
+  protected_until?: string;
+  expired_at?: string;
   status: string;
   manager_comment?: string;
   overall_tz_file_id?: number;
@@ -91,9 +97,10 @@ export interface Request {
   
   // Новые поля
   project_name?: string;
-  quantity?: number;
-  unit_price?: string; // Приходит как строка
-  total_price?: string; // Приходит как строка
+  total_price?: string; // Сумма позиций, приходит как строка
+  currency?: string; // Код валюты ISO 4217 для цен и total_price
+  exchange_rate?: string;
+  total_price_base?: string; // Сумма в рублях
 
   // Связанные данные (вложенные объекты)
   partner?: Partner;
@@ -103,6 +110,27 @@ export interface Request {
   items?: RequestItem[];
   overall_tz_file?: FileInfo;
   files?: FileInfo[]; // Новое поле для массива файлов
+
+  // Пересечения с заявками других партнеров (только для менеджера)
+  is_conflicting?: boolean;
+  conflicts?: RequestConflict[];
+}
+
+export interface RequestConflict {
+  request_id: number;
+  direction: 'earlier' | 'later';
+  reasons: string[];
+  name_similarity?: number;
+  detected_at: string;
+  partner_id: number;
+  partner_name: string;
+  project_name?: string;
+  total_price?: string;
+  currency?: string;
+  total_price_base?: string;
+  status: string;
+  withdrawn: boolean;
+  created_at: string;
 }
 
 export interface RequestQueryParams {
@@ -178,10 +206,14 @@ export async function submitDealRegistration(data: DealRegistrationData): Promis
     deal_state_description: formDataWithoutFile.dealDescription || "", // dealDescription из формы мапится сюда
     estimated_close_date: formDataWithoutFile.estimatedCloseDate || "",
     project_name: formDataWithoutFile.projectName || "",
-    quantity: formDataWithoutFile.quantity,
-    unit_price: (typeof formDataWithoutFile.unitPrice === 'number' && isFinite(formDataWithoutFile.unitPrice)) 
-      ? formDataWithoutFile.unitPrice.toString() 
-      : "",
+    // Форма пока содержит одну позицию; итог считает сервер
+    items: (typeof formDataWithoutFile.quantity === 'number' && typeof formDataWithoutFile.unitPrice === 'number' && isFinite(formDataWithoutFile.unitPrice))
+      ? [{
+          product_name: formDataWithoutFile.projectName || "Позиция 1",
+          quantity: formDataWithoutFile.quantity,
+          unit_price: formDataWithoutFile.unitPrice.toString(),
+        }]
+      : [],
   };
 
   // 3. Добавляем JSON как текстовое поле
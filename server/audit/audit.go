@@ -0,0 +1,157 @@
+// Package audit собирает сведения об изменениях в рамках одного HTTP-запроса.
+// Запись в журнал (таблица audit_events) выполняет middleware.Audit, а обработчики
+// и репозитории лишь дополняют запись через контекст: кто действовал и что изменилось.
+package audit
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+type contextKey struct{}
+
+// Entry накапливает данные для одной записи журнала аудита.
+type Entry struct {
+	mu         sync.Mutex
+	actorID    *int
+	actorRole  string
+	entityType string
+	entityID   string
+	before     map[string]any
+	after      map[string]any
+}
+
+// Snapshot — неизменяемая копия данных Entry на момент записи в журнал.
+type Snapshot struct {
+	ActorID    *int
+	ActorRole  string
+	EntityType string
+	EntityID   string
+	Before     map[string]any
+	After      map[string]any
+}
+
+// NewContext возвращает контекст, в котором обработчики могут дополнять запись e.
+func NewContext(ctx context.Context, e *Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, e)
+}
+
+// FromContext возвращает запись аудита текущего запроса или nil, если аудит не ведется.
+func FromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(contextKey{}).(*Entry)
+	return e
+}
+
+// Enabled сообщает, ведется ли аудит для контекста. Позволяет не делать лишних запросов за снимками.
+func Enabled(ctx context.Context) bool {
+	return FromContext(ctx) != nil
+}
+
+// SetActor указывает автора действия для запросов без токена (вход, регистрация).
+func SetActor(ctx context.Context, userID int, role string) {
+	e := FromContext(ctx)
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.actorID = &userID
+	e.actorRole = role
+}
+
+// SetTarget указывает сущность, над которой выполнено действие.
+func SetTarget(ctx context.Context, entityType string, entityID int) {
+	e := FromContext(ctx)
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.entityType = entityType
+	e.entityID = strconv.Itoa(entityID)
+}
+
+// RecordChange фиксирует изменение сущности. В журнал попадают только отличающиеся поля.
+// При нескольких изменениях одной сущности сохраняется самое раннее "до" и самое позднее "после".
+func RecordChange(ctx context.Context, entityType string, entityID int, before, after map[string]any) {
+	record(ctx, entityType, strconv.Itoa(entityID), before, after)
+}
+
+// RecordBulkChange фиксирует изменение сразу многих сущностей одного типа (например, импорт
+// прайс-листа). ID сущности в журнале не указывается; before и after сравниваются, как в RecordChange.
+func RecordBulkChange(ctx context.Context, entityType string, before, after map[string]any) {
+	record(ctx, entityType, "", before, after)
+}
+
+func record(ctx context.Context, entityType, entityID string, before, after map[string]any) {
+	e := FromContext(ctx)
+	if e == nil {
+		return
+	}
+	changedBefore, changedAfter := Diff(before, after)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.entityType = entityType
+	e.entityID = entityID
+	if len(changedBefore) > 0 && e.before == nil {
+		e.before = map[string]any{}
+	}
+	for k, v := range changedBefore {
+		if _, ok := e.before[k]; !ok {
+			e.before[k] = v
+		}
+	}
+	if len(changedAfter) > 0 && e.after == nil {
+		e.after = map[string]any{}
+	}
+	for k, v := range changedAfter {
+		e.after[k] = v
+	}
+}
+
+// Snapshot возвращает накопленные данные, дополняя автора значениями по умолчанию.
+func (e *Entry) Snapshot(defaultActorID *int, defaultRole string) Snapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := Snapshot{
+		ActorID:    e.actorID,
+		ActorRole:  e.actorRole,
+		EntityType: e.entityType,
+		EntityID:   e.entityID,
+		Before:     e.before,
+		After:      e.after,
+	}
+	if s.ActorID == nil {
+		s.ActorID = defaultActorID
+		s.ActorRole = defaultRole
+	}
+	return s
+}
+
+// Diff оставляет в before и after только ключи, значения которых различаются.
+// Отсутствующий ключ равносилен nil. Для создания (before == nil) и удаления (after == nil)
+// возвращается полный снимок с другой стороны.
+func Diff(before, after map[string]any) (map[string]any, map[string]any) {
+	if before == nil || after == nil {
+		return before, after
+	}
+	changedBefore := map[string]any{}
+	changedAfter := map[string]any{}
+	for k, b := range before {
+		a := after[k]
+		if !reflect.DeepEqual(a, b) {
+			changedBefore[k] = b
+			changedAfter[k] = a
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok && a != nil {
+			changedBefore[k] = nil
+			changedAfter[k] = a
+		}
+	}
+	return changedBefore, changedAfter
+}
@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	before := map[string]any{"status": "На рассмотрении", "quantity": float64(1), "comment": nil}
+	after := map[string]any{"status": "Одобрено", "quantity": float64(1), "comment": "ok"}
+
+	gotBefore, gotAfter := Diff(before, after)
+
+	wantBefore := map[string]any{"status": "На рассмотрении", "comment": nil}
+	wantAfter := map[string]any{"status": "Одобрено", "comment": "ok"}
+	if !reflect.DeepEqual(gotBefore, wantBefore) {
+		t.Errorf("before = %v, want %v", gotBefore, wantBefore)
+	}
+	if !reflect.DeepEqual(gotAfter, wantAfter) {
+		t.Errorf("after = %v, want %v", gotAfter, wantAfter)
+	}
+}
+
+func TestDiffCreate(t *testing.T) {
+	after := map[string]any{"id": float64(7)}
+	gotBefore, gotAfter := Diff(nil, after)
+	if gotBefore != nil || !reflect.DeepEqual(gotAfter, after) {
+		t.Errorf("Diff(nil, after) = (%v, %v)", gotBefore, gotAfter)
+	}
+}
+
+func TestRecordChangeMergesAndKeepsEarliestBefore(t *testing.T) {
+	e := &Entry{}
+	ctx := NewContext(context.Background(), e)
+
+	RecordChange(ctx, "request", 5, map[string]any{"status": "A"}, map[string]any{"status": "B"})
+	RecordChange(ctx, "request", 5, map[string]any{"status": "B"}, map[string]any{"status": "C"})
+
+	s := e.Snapshot(nil, "")
+	if s.EntityType != "request" || s.EntityID != "5" {
+		t.Errorf("target = %s/%s, want request/5", s.EntityType, s.EntityID)
+	}
+	if s.Before["status"] != "A" || s.After["status"] != "C" {
+		t.Errorf("status change = %v -> %v, want A -> C", s.Before["status"], s.After["status"])
+	}
+}
+
+func TestRecordBulkChangeHasNoEntityID(t *testing.T) {
+	e := &Entry{}
+	ctx := NewContext(context.Background(), e)
+
+	RecordBulkChange(ctx, "product", map[string]any{"products": map[string]any{}},
+		map[string]any{"created": 2, "products": map[string]any{}})
+
+	s := e.Snapshot(nil, "")
+	if s.EntityType != "product" || s.EntityID != "" {
+		t.Errorf("target = %q/%q, want product without ID", s.EntityType, s.EntityID)
+	}
+	if _, ok := s.After["products"]; ok || s.After["created"] != 2 {
+		t.Errorf("after = %v, want only the created count", s.After)
+	}
+}
+
+func TestSnapshotPrefersExplicitActor(t *testing.T) {
+	e := &Entry{}
+	ctx := NewContext(context.Background(), e)
+	defaultID := 1
+
+	if s := e.Snapshot(&defaultID, "USER"); s.ActorID == nil || *s.ActorID != 1 {
+		t.Fatalf("expected default actor, got %v", s.ActorID)
+	}
+
+	SetActor(ctx, 2, "MANAGER")
+	s := e.Snapshot(&defaultID, "USER")
+	if s.ActorID == nil || *s.ActorID != 2 || s.ActorRole != "MANAGER" {
+		t.Errorf("actor = %v/%s, want 2/MANAGER", s.ActorID, s.ActorRole)
+	}
+}
+
+func TestHelpersWithoutEntryAreNoop(t *testing.T) {
+	ctx := context.Background()
+	if Enabled(ctx) {
+		t.Fatal("audit must be disabled without entry")
+	}
+	// Не должно паниковать
+	SetActor(ctx, 1, "USER")
+	SetTarget(ctx, "request", 1)
+	RecordChange(ctx, "request", 1, nil, map[string]any{"id": 1})
+}
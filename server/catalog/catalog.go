@@ -0,0 +1,76 @@
+// Package catalog проверяет продукты каталога и разбирает прайс-лист в CSV для массового импорта.
+package catalog
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eeephemera/zvk-requests/server/currency"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+// DefaultCurrency — валюта прайсовой цены, если она не указана.
+const DefaultCurrency = currency.Base
+
+const (
+	maxSKULength      = 100
+	maxNameLength     = 255
+	maxCategoryLength = 100
+)
+
+// Предел numeric(12,2) для products.list_price
+var maxListPrice = decimal.New(1, 10)
+
+// FieldError — ошибка в поле продукта.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Normalize приводит поля продукта к каноническому виду (пробелы по краям, валюта заглавными,
+// пустая категория — nil, валюта по умолчанию) и возвращает ошибки по полям.
+func Normalize(p *models.Product) []FieldError {
+	p.SKU = strings.TrimSpace(p.SKU)
+	p.Name = strings.TrimSpace(p.Name)
+	p.Currency = currency.Normalize(p.Currency)
+	if p.Category != nil {
+		category := strings.TrimSpace(*p.Category)
+		if category == "" {
+			p.Category = nil
+		} else {
+			p.Category = &category
+		}
+	}
+
+	var errs []FieldError
+	switch {
+	case p.SKU == "":
+		errs = append(errs, FieldError{"sku", "SKU is required"})
+	case utf8.RuneCountInString(p.SKU) > maxSKULength:
+		errs = append(errs, FieldError{"sku", "SKU is too long"})
+	}
+	switch {
+	case p.Name == "":
+		errs = append(errs, FieldError{"name", "Name is required"})
+	case utf8.RuneCountInString(p.Name) > maxNameLength:
+		errs = append(errs, FieldError{"name", "Name is too long"})
+	}
+	if p.Category != nil && utf8.RuneCountInString(*p.Category) > maxCategoryLength {
+		errs = append(errs, FieldError{"category", "Category is too long"})
+	}
+	switch {
+	case p.ListPrice.IsNegative() || !p.ListPrice.LessThan(maxListPrice):
+		errs = append(errs, FieldError{"list_price", "List price is out of range"})
+	case !p.ListPrice.Equal(p.ListPrice.Round(2)):
+		errs = append(errs, FieldError{"list_price", "List price must have at most 2 decimal places"})
+	}
+	if err := currency.Validate(p.Currency); err != nil {
+		errs = append(errs, FieldError{"currency", err.Error()})
+	}
+	return errs
+}
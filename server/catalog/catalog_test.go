@@ -0,0 +1,122 @@
+package catalog
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestNormalize(t *testing.T) {
+	category := "  "
+	p := models.Product{SKU: " SRV-01 ", Name: " Сервер ", Category: &category, ListPrice: decimal.RequireFromString("1500.50"), Currency: " usd"}
+	if errs := Normalize(&p); len(errs) != 0 {
+		t.Fatalf("Normalize: %v", errs)
+	}
+	if p.SKU != "SRV-01" || p.Name != "Сервер" || p.Category != nil || p.Currency != "USD" {
+		t.Errorf("product not normalized: %+v", p)
+	}
+
+	p = models.Product{SKU: "X", Name: "Y"}
+	if errs := Normalize(&p); len(errs) != 0 || p.Currency != DefaultCurrency {
+		t.Errorf("Normalize default currency = %q, errs %v", p.Currency, errs)
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		product models.Product
+		field   string
+	}{
+		{"MissingSKU", models.Product{Name: "Y"}, "sku"},
+		{"MissingName", models.Product{SKU: "X"}, "name"},
+		{"NegativePrice", models.Product{SKU: "X", Name: "Y", ListPrice: decimal.NewFromInt(-1)}, "list_price"},
+		{"PriceScale", models.Product{SKU: "X", Name: "Y", ListPrice: decimal.RequireFromString("1.005")}, "list_price"},
+		{"BadCurrency", models.Product{SKU: "X", Name: "Y", Currency: "RUR1"}, "currency"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			errs := Normalize(&tc.product)
+			if len(errs) != 1 || errs[0].Field != tc.field {
+				t.Errorf("Normalize errors = %v, want one error on %s", errs, tc.field)
+			}
+		})
+	}
+}
+
+func TestParseCSV(t *testing.T) {
+	input := "\ufeffSKU;Name;Category;List_Price;Currency;Active\n" +
+		"SRV-01;Сервер;Оборудование;1 234,50;;\n" +
+		"\n" +
+		"LIC-01;\"Лицензия; год\";;990;usd;нет\n"
+	products, rowErrs, err := ParseCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	if len(rowErrs) != 0 {
+		t.Fatalf("unexpected row errors: %v", rowErrs)
+	}
+	if len(products) != 2 {
+		t.Fatalf("got %d products, want 2", len(products))
+	}
+
+	first, second := products[0], products[1]
+	if first.SKU != "SRV-01" || first.Category == nil || *first.Category != "Оборудование" ||
+		!first.ListPrice.Equal(decimal.RequireFromString("1234.5")) || first.Currency != "RUB" || !first.IsActive {
+		t.Errorf("first product = %+v", first)
+	}
+	if second.Name != "Лицензия; год" || second.Category != nil || second.Currency != "USD" || second.IsActive {
+		t.Errorf("second product = %+v", second)
+	}
+}
+
+func TestParseCSVRowErrors(t *testing.T) {
+	input := "sku,name,list_price,active\n" +
+		"A,Товар,abc,\n" +
+		"B,,10,maybe\n" +
+		"C,Товар,10,\n" +
+		"C,Дубль,20,\n"
+	products, rowErrs, err := ParseCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCSV: %v", err)
+	}
+	if len(products) != 1 || products[0].SKU != "C" {
+		t.Errorf("products = %+v, want only C", products)
+	}
+
+	want := []struct {
+		row   int
+		field string
+	}{{2, "list_price"}, {3, "active"}, {3, "name"}, {5, "sku"}}
+	if len(rowErrs) != len(want) {
+		t.Fatalf("row errors = %v, want %d", rowErrs, len(want))
+	}
+	for i, w := range want {
+		if rowErrs[i].Row != w.row || rowErrs[i].Field != w.field {
+			t.Errorf("rowErrs[%d] = %v, want row %d field %s", i, rowErrs[i], w.row, w.field)
+		}
+	}
+}
+
+func TestParseCSVInvalidFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Empty", ""},
+		{"MissingColumn", "sku,name\nA,B\n"},
+		{"UnknownColumn", "sku,name,list_price,color\nA,B,1,red\n"},
+		{"NoRows", "sku,name,list_price\n"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := ParseCSV(strings.NewReader(tc.input)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
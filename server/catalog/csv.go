@@ -0,0 +1,187 @@
+package catalog
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+// MaxImportRows — максимальное число продуктов в одном файле импорта.
+const MaxImportRows = 10000
+
+// Колонки прайс-листа. Обязательны sku, name и list_price, порядок колонок произвольный.
+var (
+	requiredColumns = []string{"sku", "name", "list_price"}
+	knownColumns    = map[string]bool{
+		"sku": true, "name": true, "category": true, "list_price": true, "currency": true, "active": true,
+	}
+)
+
+// RowError — ошибка в строке файла. Row — номер строки, считая заголовок первой.
+type RowError struct {
+	Row int
+	FieldError
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.FieldError.Error())
+}
+
+// ParseCSV разбирает прайс-лист с заголовком (sku, name, category, list_price, currency, active).
+// Разделитель — запятая или точка с запятой (как сохраняет Excel), цена допускает десятичную запятую
+// и пробелы между разрядами. Ошибки в данных возвращаются списком по строкам; error — если файл
+// нельзя разобрать целиком (нет заголовка, нет обязательных колонок, слишком много строк).
+func ParseCSV(r io.Reader) ([]models.Product, []RowError, error) {
+	br := bufio.NewReader(r)
+	reader := csv.NewReader(br)
+	reader.Comma = detectDelimiter(br)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, nil, errors.New("file is empty")
+		}
+		return nil, nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !knownColumns[name] {
+			return nil, nil, fmt.Errorf("unknown column %q", name)
+		}
+		if _, dup := columns[name]; dup {
+			return nil, nil, fmt.Errorf("duplicate column %q", name)
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("missing required column %q", name)
+		}
+	}
+
+	var products []models.Product
+	var rowErrs []RowError
+	seen := map[string]int{}
+	count := 0
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid CSV at row %d: %w", row, err)
+		}
+		if isBlank(record) {
+			continue
+		}
+		if count++; count > MaxImportRows {
+			return nil, nil, fmt.Errorf("too many rows: at most %d allowed", MaxImportRows)
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		p, errs := parseRow(field)
+		if len(errs) == 0 {
+			if first, dup := seen[p.SKU]; dup {
+				errs = append(errs, FieldError{"sku", fmt.Sprintf("SKU is already listed in row %d", first)})
+			} else {
+				seen[p.SKU] = row
+			}
+		}
+		if len(errs) > 0 {
+			for _, e := range errs {
+				rowErrs = append(rowErrs, RowError{Row: row, FieldError: e})
+			}
+			continue
+		}
+		products = append(products, p)
+	}
+	if len(products) == 0 && len(rowErrs) == 0 {
+		return nil, nil, errors.New("file contains no products")
+	}
+	return products, rowErrs, nil
+}
+
+func parseRow(field func(string) string) (models.Product, []FieldError) {
+	p := models.Product{
+		SKU:      field("sku"),
+		Name:     field("name"),
+		Currency: field("currency"),
+		IsActive: true,
+	}
+	if category := field("category"); category != "" {
+		p.Category = &category
+	}
+
+	var errs []FieldError
+	price, err := parsePrice(field("list_price"))
+	if err != nil {
+		errs = append(errs, FieldError{"list_price", "List price must be a number"})
+	}
+	p.ListPrice = price
+	if active, ok := parseActive(field("active")); ok {
+		p.IsActive = active
+	} else {
+		errs = append(errs, FieldError{"active", "Active must be true or false"})
+	}
+
+	for _, e := range Normalize(&p) {
+		// Ошибку формата цены уже сообщили
+		if e.Field == "list_price" && err != nil {
+			continue
+		}
+		errs = append(errs, e)
+	}
+	return p, errs
+}
+
+// parsePrice принимает "1234.50", "1234,50" и "1 234,50".
+func parsePrice(s string) (decimal.Decimal, error) {
+	s = strings.NewReplacer(" ", "", "\u00a0", "", ",", ".").Replace(s)
+	return decimal.NewFromString(s)
+}
+
+// parseActive разбирает признак активности; пустое значение означает активный продукт.
+func parseActive(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "", "true", "1", "yes", "да":
+		return true, true
+	case "false", "0", "no", "нет":
+		return false, true
+	}
+	return false, false
+}
+
+// detectDelimiter выбирает разделитель по первой строке, не продвигая чтение.
+func detectDelimiter(br *bufio.Reader) rune {
+	line, _ := br.Peek(4096)
+	if i := strings.IndexByte(string(line), '\n'); i >= 0 {
+		line = line[:i]
+	}
+	if strings.Count(string(line), ";") > strings.Count(string(line), ",") {
+		return ';'
+	}
+	return ','
+}
+
+func isBlank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,184 @@
+// Package certificate формирует PDF-свидетельство о регистрации одобренной сделки,
+// которое партнер показывает конечному клиенту и дистрибьютору.
+package certificate
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/go-pdf/fpdf"
+	"github.com/shopspring/decimal"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Data — данные свидетельства. Request должен быть загружен со связанными партнером,
+// конечным клиентом, дистрибьютором и позициями (GetRequestDetailsByID).
+type Data struct {
+	Request     *models.Request
+	ApprovedAt  time.Time
+	ManagerName string // Менеджер, одобривший сделку; пусто, если неизвестен
+	Code        string // Код проверки подлинности (Signer.Code)
+	VerifyURL   string // Адрес публичной проверки по коду; пусто — адрес не печатается
+}
+
+const (
+	fontFamily = "Go"
+	pageMargin = 15.0
+	labelWidth = 55.0
+	lineHeight = 6.0
+)
+
+// Render записывает свидетельство в формате PDF (A4). Шрифты Go встроены в бинарник
+// и содержат кириллицу, поэтому внешние файлы шрифтов не нужны.
+func Render(w io.Writer, d Data) error {
+	req := d.Request
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(pageMargin, pageMargin, pageMargin)
+	pdf.SetAutoPageBreak(true, pageMargin)
+	pdf.AddUTF8FontFromBytes(fontFamily, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontFamily, "B", gobold.TTF)
+	pdf.SetTitle(fmt.Sprintf("Свидетельство о регистрации сделки № %d", req.ID), true)
+	pdf.SetCreationDate(d.ApprovedAt)
+	pdf.AddPage()
+	width, _ := pdf.GetPageSize()
+	contentWidth := width - 2*pageMargin
+
+	pdf.SetFont(fontFamily, "B", 16)
+	pdf.CellFormat(contentWidth, 10, "СВИДЕТЕЛЬСТВО О РЕГИСТРАЦИИ СДЕЛКИ", "", 1, "C", false, 0, "")
+	pdf.SetFont(fontFamily, "", 12)
+	pdf.CellFormat(contentWidth, 8, fmt.Sprintf("№ %d от %s", req.ID, formatDate(d.ApprovedAt)), "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	field := func(label, value string) {
+		if value == "" {
+			value = "—"
+		}
+		pdf.SetFont(fontFamily, "B", 10)
+		y := pdf.GetY()
+		pdf.MultiCell(labelWidth, lineHeight, label, "", "L", false)
+		labelEnd := pdf.GetY()
+		pdf.SetXY(pageMargin+labelWidth, y)
+		pdf.SetFont(fontFamily, "", 10)
+		pdf.MultiCell(contentWidth-labelWidth, lineHeight, value, "", "L", false)
+		if labelEnd > pdf.GetY() {
+			pdf.SetY(labelEnd)
+		}
+	}
+
+	if req.Partner != nil {
+		field("Партнер", req.Partner.Name)
+		field("ИНН партнера", str(req.Partner.INN))
+	}
+	clientName, clientINN := str(req.EndClientDetailsOverride), ""
+	if req.EndClient != nil {
+		clientName, clientINN = req.EndClient.Name, str(req.EndClient.INN)
+	}
+	field("Конечный клиент", clientName)
+	field("ИНН конечного клиента", clientINN)
+	if req.Distributor != nil {
+		field("Дистрибьютор", req.Distributor.Name)
+	}
+	field("Проект", str(req.ProjectName))
+	field("Статус", string(req.Status))
+	field("Дата одобрения", formatDate(d.ApprovedAt))
+	field("Менеджер", d.ManagerName)
+	protection := ""
+	if req.ProtectedUntil != nil {
+		protection = fmt.Sprintf("с %s по %s", formatDate(d.ApprovedAt), formatDate(*req.ProtectedUntil))
+	}
+	field("Период защиты сделки", protection)
+
+	if len(req.Items) > 0 {
+		pdf.Ln(6)
+		renderItems(pdf, req, contentWidth)
+	}
+
+	pdf.Ln(4)
+	if req.TotalPrice != nil {
+		field("Сумма сделки", formatMoney(*req.TotalPrice)+" "+req.Currency)
+	}
+	if req.TotalPriceBase != nil && req.Currency != "RUB" {
+		field("Сумма сделки в рублях", formatMoney(*req.TotalPriceBase)+" RUB")
+	}
+
+	pdf.Ln(10)
+	pdf.SetFont(fontFamily, "B", 12)
+	pdf.CellFormat(contentWidth, 8, "Код проверки: "+d.Code, "1", 1, "C", false, 0, "")
+	pdf.SetFont(fontFamily, "", 8)
+	note := "Свидетельство подтверждает регистрацию сделки за партнером на период защиты. " +
+		"Подлинность свидетельства и актуальный статус сделки можно проверить по коду без регистрации"
+	if d.VerifyURL != "" {
+		note += ": " + d.VerifyURL
+	}
+	pdf.MultiCell(contentWidth, 4, note+".", "", "C", false)
+
+	return pdf.Output(w)
+}
+
+// renderItems выводит таблицу товарных позиций заявки.
+func renderItems(pdf *fpdf.Fpdf, req *models.Request, contentWidth float64) {
+	widths := []float64{10, 0, 30, 18, 28, 16, 30}
+	fixed := 0.0
+	for _, w := range widths {
+		fixed += w
+	}
+	widths[1] = contentWidth - fixed
+	header := []string{"№", "Наименование", "Артикул", "Кол-во", "Цена", "Скидка, %", "Сумма"}
+	align := []string{"C", "L", "L", "R", "R", "R", "R"}
+
+	pdf.SetFont(fontFamily, "B", 9)
+	for i, title := range header {
+		pdf.CellFormat(widths[i], 7, title, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont(fontFamily, "", 9)
+	for _, item := range req.Items {
+		row := []string{
+			strconv.Itoa(item.Position), item.ProductName, str(item.SKU), strconv.Itoa(item.Quantity),
+			formatMoney(item.UnitPrice), item.DiscountPercent.String(), formatMoney(item.LineTotal),
+		}
+		for i, value := range row {
+			// Длинные значения обрезаем, чтобы строка таблицы оставалась в одну линию
+			for pdf.GetStringWidth(value) > widths[i]-2 && len([]rune(value)) > 1 {
+				r := []rune(value)
+				value = string(r[:len(r)-2]) + "…"
+			}
+			pdf.CellFormat(widths[i], 6, value, "1", 0, align[i], false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("02.01.2006")
+}
+
+// formatMoney форматирует сумму с двумя знаками, пробелами между разрядами и десятичной запятой.
+func formatMoney(d decimal.Decimal) string {
+	s := d.StringFixed(2)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	var b strings.Builder
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteRune(' ')
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String() + "," + frac
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,64 @@
+package certificate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestFormatMoney(t *testing.T) {
+	tests := map[string]string{
+		"0":          "0,00",
+		"999.5":      "999,50",
+		"1000":       "1 000,00",
+		"1234567.89": "1 234 567,89",
+		"-12345.6":   "-12 345,60",
+	}
+	for in, want := range tests {
+		if got := formatMoney(decimal.RequireFromString(in)); got != want {
+			t.Errorf("formatMoney(%s) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	inn := "7707083893"
+	project := "Поставка серверов для ЦОД"
+	sku := "SRV-01"
+	until := time.Date(2026, 6, 3, 0, 0, 0, 0, time.UTC)
+	total := decimal.RequireFromString("25000")
+	base := decimal.RequireFromString("2300000")
+	req := &models.Request{
+		ID:             12,
+		Status:         models.StatusApproved,
+		ProjectName:    &project,
+		ProtectedUntil: &until,
+		Currency:       "USD",
+		TotalPrice:     &total,
+		TotalPriceBase: &base,
+		Partner:        &models.Partner{Name: "ООО «Интегратор»", INN: &inn},
+		EndClient:      &models.EndClient{Name: "АО «Завод»"},
+		Items: []models.RequestItem{
+			{Position: 1, ProductName: strings.Repeat("Сервер стоечный ", 10), SKU: &sku, Quantity: 5,
+				UnitPrice: decimal.NewFromInt(5000), LineTotal: total},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := Render(&buf, Data{
+		Request:     req,
+		ApprovedAt:  time.Date(2026, 3, 5, 10, 30, 0, 0, time.UTC),
+		ManagerName: "Иванов Иван",
+		Code:        "ABCD-EFGH-IJKL-MNOP",
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("output is not a PDF: %q", buf.Bytes()[:min(buf.Len(), 16)])
+	}
+}
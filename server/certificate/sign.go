@@ -0,0 +1,79 @@
+package certificate
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"math"
+	"strings"
+	"time"
+)
+
+// Код проверки — 15 байт в base32 (24 символа группами по 4): номер заявки (4 байта),
+// момент одобрения в секундах Unix (4 байта) и первые 7 байт HMAC-SHA256 от них.
+// Код самодостаточен: для проверки не нужно хранить выданные коды.
+const (
+	codePayloadLen = 8
+	codeMACLen     = 7
+	codeGroupLen   = 4
+	codeDomain     = "zvk-certificate-v1"
+)
+
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Signer выдает и проверяет коды свидетельств. Ключ должен отличаться от ключа подписи JWT.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner создает Signer с ключом key.
+func NewSigner(key []byte) *Signer {
+	return &Signer{key: key}
+}
+
+// Code возвращает код проверки свидетельства заявки requestID, одобренной в approvedAt.
+// Повторное одобрение заявки меняет код.
+func (s *Signer) Code(requestID int, approvedAt time.Time) string {
+	payload := make([]byte, codePayloadLen, codePayloadLen+codeMACLen)
+	binary.BigEndian.PutUint32(payload[0:4], uint32(requestID))
+	binary.BigEndian.PutUint32(payload[4:8], uint32(approvedAt.Unix()))
+	raw := codeEncoding.EncodeToString(append(payload, s.mac(payload)...))
+
+	groups := make([]string, 0, len(raw)/codeGroupLen)
+	for i := 0; i < len(raw); i += codeGroupLen {
+		groups = append(groups, raw[i:i+codeGroupLen])
+	}
+	return strings.Join(groups, "-")
+}
+
+// Parse проверяет подпись кода и возвращает номер заявки и момент одобрения (с точностью до секунды).
+// Регистр, дефисы и пробелы в коде не важны.
+func (s *Signer) Parse(code string) (requestID int, approvedAt time.Time, ok bool) {
+	normalized := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(code))
+	data, err := codeEncoding.DecodeString(normalized)
+	if err != nil || len(data) != codePayloadLen+codeMACLen {
+		return 0, time.Time{}, false
+	}
+	payload, mac := data[:codePayloadLen], data[codePayloadLen:]
+	if !hmac.Equal(mac, s.mac(payload)) {
+		return 0, time.Time{}, false
+	}
+	id := binary.BigEndian.Uint32(payload[0:4])
+	if id == 0 || id > math.MaxInt32 {
+		return 0, time.Time{}, false
+	}
+	return int(id), time.Unix(int64(binary.BigEndian.Uint32(payload[4:8])), 0).UTC(), true
+}
+
+func (s *Signer) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write([]byte(codeDomain))
+	h.Write(payload)
+	return h.Sum(nil)[:codeMACLen]
+}
@@ -0,0 +1,62 @@
+package certificate
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignerCode(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	approvedAt := time.Date(2026, 3, 5, 10, 30, 0, 0, time.UTC)
+	code := signer.Code(12, approvedAt)
+	if !regexp.MustCompile(`^[A-Z2-7]{4}(-[A-Z2-7]{4}){5}$`).MatchString(code) {
+		t.Fatalf("Code = %q, want six groups of base32 characters", code)
+	}
+	if again := signer.Code(12, approvedAt); again != code {
+		t.Errorf("Code is not deterministic: %q != %q", again, code)
+	}
+	for name, other := range map[string]string{
+		"OtherRequest": signer.Code(13, approvedAt),
+		"OtherTime":    signer.Code(12, approvedAt.Add(time.Second)),
+		"OtherKey":     NewSigner([]byte("another")).Code(12, approvedAt),
+	} {
+		if other == code {
+			t.Errorf("%s: code did not change", name)
+		}
+	}
+}
+
+func TestSignerParse(t *testing.T) {
+	signer := NewSigner([]byte("secret"))
+	approvedAt := time.Date(2026, 3, 5, 10, 30, 0, 500, time.UTC)
+	code := signer.Code(12, approvedAt)
+
+	for _, variant := range []string{code, strings.ToLower(code), strings.ReplaceAll(code, "-", ""), " " + strings.ReplaceAll(code, "-", " ")} {
+		id, at, ok := signer.Parse(variant)
+		if !ok || id != 12 || !at.Equal(approvedAt.Truncate(time.Second)) {
+			t.Errorf("Parse(%q) = %d, %v, %v; want 12, %v, true", variant, id, at, ok, approvedAt.Truncate(time.Second))
+		}
+	}
+
+	// Подмена любого символа ломает подпись
+	tampered := []byte(code)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+	invalid := []string{
+		"",
+		"not-a-code",
+		string(tampered),
+		code[:len(code)-1],
+		NewSigner([]byte("another")).Code(12, approvedAt),
+	}
+	for _, c := range invalid {
+		if _, _, ok := signer.Parse(c); ok {
+			t.Errorf("Parse(%q) accepted an invalid code", c)
+		}
+	}
+}
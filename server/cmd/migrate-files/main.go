@@ -0,0 +1,136 @@
+// Команда migrate-files переносит содержимое файлов, загруженных до появления внешнего хранилища,
+// из столбца files.file_data в хранилище, настроенное как у сервера (FILE_STORAGE, см. storage.NewFromEnv).
+//
+// Перенос идемпотентен: обрабатываются только строки без storage_key, поэтому команду можно
+// прерывать и запускать повторно, в том числе при работающем сервере. После полного переноса
+// столбец file_data удаляется миграцией 000024_drop_file_data.
+//
+//	go run ./cmd/migrate-files -dry-run
+//	go run ./cmd/migrate-files -batch 50
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/storage"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	batch := flag.Int("batch", 20, "сколько файлов читать из базы за один запрос")
+	dryRun := flag.Bool("dry-run", false, "только посчитать файлы, которые осталось перенести")
+	flag.Parse()
+	if *batch <= 0 {
+		log.Fatal("-batch должен быть положительным")
+	}
+
+	if os.Getenv("APP_ENV") != "production" {
+		_ = godotenv.Load()
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if err := db.ConnectDB(ctx); err != nil {
+		log.Fatalf("Ошибка подключения к базе данных: %v", err)
+	}
+	defer db.CloseDBConnection()
+	pool := db.GetPool()
+
+	count, size, err := pending(ctx, pool)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Осталось перенести файлов: %d (%d байт)", count, size)
+	if *dryRun {
+		return
+	}
+
+	if count > 0 {
+		blobs, err := storage.NewFromEnv()
+		if err != nil {
+			log.Fatalf("Не удалось настроить хранилище файлов: %v", err)
+		}
+		moved, err := migrate(ctx, pool, blobs, *batch)
+		log.Printf("Перенесено файлов: %d", moved)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// pending возвращает число и суммарный размер файлов, содержимое которых еще хранится в базе.
+func pending(ctx context.Context, pool *pgxpool.Pool) (int64, int64, error) {
+	var count, size int64
+	err := pool.QueryRow(ctx,
+		"SELECT COUNT(*), COALESCE(SUM(file_size), 0) FROM files WHERE storage_key IS NULL",
+	).Scan(&count, &size)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count files to migrate: %w", err)
+	}
+	return count, size, nil
+}
+
+// migrate переносит файлы пачками по batch и возвращает число перенесенных.
+// Содержимое сначала записывается в хранилище и только потом удаляется из базы,
+// поэтому прерывание в любой момент не теряет данные.
+func migrate(ctx context.Context, pool *pgxpool.Pool, blobs storage.BlobStore, batch int) (int, error) {
+	moved := 0
+	for {
+		rows, err := pool.Query(ctx,
+			"SELECT id, file_data FROM files WHERE storage_key IS NULL ORDER BY id LIMIT $1", batch)
+		if err != nil {
+			return moved, fmt.Errorf("failed to select files: %w", err)
+		}
+		type legacyFile struct {
+			id   int
+			data []byte
+		}
+		var files []legacyFile
+		for rows.Next() {
+			var f legacyFile
+			if err := rows.Scan(&f.id, &f.data); err != nil {
+				rows.Close()
+				return moved, fmt.Errorf("failed to scan file: %w", err)
+			}
+			files = append(files, f)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return moved, fmt.Errorf("failed to read files: %w", err)
+		}
+		if len(files) == 0 {
+			return moved, nil
+		}
+
+		for _, f := range files {
+			key := storage.NewKey(time.Now())
+			if _, err := blobs.Put(ctx, key, bytes.NewReader(f.data), int64(len(f.data))); err != nil {
+				return moved, fmt.Errorf("failed to store file %d: %w", f.id, err)
+			}
+			// Условие storage_key IS NULL защищает от параллельного запуска второй копии команды
+			tag, err := pool.Exec(ctx,
+				"UPDATE files SET storage_key = $2, file_data = NULL WHERE id = $1 AND storage_key IS NULL", f.id, key)
+			if err != nil || tag.RowsAffected() == 0 {
+				if delErr := blobs.Delete(context.WithoutCancel(ctx), key); delErr != nil {
+					log.Printf("Не удалось удалить объект %s: %v", key, delErr)
+				}
+				if err != nil {
+					return moved, fmt.Errorf("failed to update file %d: %w", f.id, err)
+				}
+				continue
+			}
+			moved++
+		}
+		log.Printf("Перенесено файлов: %d", moved)
+	}
+}
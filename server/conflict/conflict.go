@@ -0,0 +1,57 @@
+// Package conflict определяет, пересекается ли регистрация сделки с ранее зарегистрированной
+// другим партнером. Кандидатов (незавершенные заявки на того же конечного клиента) выбирает БД,
+// здесь решается, похож ли проект: по названию или по сумме.
+package conflict
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// Причины пересечения, сохраняемые в request_conflicts.reasons
+const (
+	ReasonSameEndClient      = "same_end_client"
+	ReasonSimilarProjectName = "similar_project_name"
+	ReasonSimilarAmount      = "similar_amount"
+	// У сделок не указаны ни сравнимые названия, ни суммы — различить их нельзя
+	ReasonProjectUnspecified = "project_unspecified"
+)
+
+// NameSimilarityThreshold — минимальное сходство названий проектов (similarity из pg_trgm).
+const NameSimilarityThreshold = 0.4
+
+// AmountTolerance — допустимое расхождение сумм сделок относительно большей из них.
+var AmountTolerance = decimal.NewFromFloat(0.2)
+
+// Deal — сравниваемые параметры сделки.
+type Deal struct {
+	TotalPrice *decimal.Decimal // В базовой валюте, чтобы сравнивать сделки в разных валютах
+}
+
+// Evaluate сравнивает сделку с ранее зарегистрированной на того же конечного клиента.
+// nameSimilarity — сходство названий проектов или nil, если у одной из сделок название не указано.
+// Возвращает причины пересечения или nil, если это разные сделки.
+func Evaluate(deal, existing Deal, nameSimilarity *float64) []string {
+	var reasons []string
+	if nameSimilarity != nil && *nameSimilarity >= NameSimilarityThreshold {
+		reasons = append(reasons, ReasonSimilarProjectName)
+	}
+	amountsKnown := isPositive(deal.TotalPrice) && isPositive(existing.TotalPrice)
+	if amountsKnown && amountsClose(*deal.TotalPrice, *existing.TotalPrice) {
+		reasons = append(reasons, ReasonSimilarAmount)
+	}
+	if len(reasons) == 0 {
+		if nameSimilarity != nil || amountsKnown {
+			return nil
+		}
+		reasons = append(reasons, ReasonProjectUnspecified)
+	}
+	return append([]string{ReasonSameEndClient}, reasons...)
+}
+
+func isPositive(d *decimal.Decimal) bool {
+	return d != nil && d.IsPositive()
+}
+
+func amountsClose(a, b decimal.Decimal) bool {
+	return a.Sub(b).Abs().LessThanOrEqual(decimal.Max(a, b).Mul(AmountTolerance))
+}
@@ -0,0 +1,65 @@
+package conflict
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func amount(s string) *decimal.Decimal {
+	d := decimal.RequireFromString(s)
+	return &d
+}
+
+func similarity(v float64) *float64 {
+	return &v
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name       string
+		deal       Deal
+		existing   Deal
+		similarity *float64
+		want       []string
+	}{
+		{
+			"SimilarName", Deal{}, Deal{}, similarity(0.7),
+			[]string{ReasonSameEndClient, ReasonSimilarProjectName},
+		},
+		{
+			"SimilarNameAndAmount", Deal{amount("1000000")}, Deal{amount("900000")}, similarity(0.5),
+			[]string{ReasonSameEndClient, ReasonSimilarProjectName, ReasonSimilarAmount},
+		},
+		{
+			"DifferentNameSimilarAmount", Deal{amount("1000000")}, Deal{amount("1150000")}, similarity(0.1),
+			[]string{ReasonSameEndClient, ReasonSimilarAmount},
+		},
+		{
+			"DifferentNameAndAmount", Deal{amount("1000000")}, Deal{amount("300000")}, similarity(0.1),
+			nil,
+		},
+		{
+			"DifferentNameNoAmount", Deal{}, Deal{amount("300000")}, similarity(0.2),
+			nil,
+		},
+		{
+			"NoNameDifferentAmount", Deal{amount("1000000")}, Deal{amount("300000")}, nil,
+			nil,
+		},
+		{
+			"NothingToCompare", Deal{}, Deal{amount("0")}, nil,
+			[]string{ReasonSameEndClient, ReasonProjectUnspecified},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Evaluate(tc.deal, tc.existing, tc.similarity)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("Evaluate() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
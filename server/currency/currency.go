@@ -0,0 +1,67 @@
+// Package currency проверяет коды валют по списку ISO 4217 и пересчитывает суммы
+// в базовую валюту (рубли) по курсу, заданному менеджерами.
+package currency
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Base — базовая валюта: в ней считаются итоги в списках и агрегатах.
+const Base = "RUB"
+
+// MaxRate — верхняя граница курса, соответствует exchange_rates.rate numeric(12,6).
+var MaxRate = decimal.New(1, 6)
+
+// codes — действующие коды валют ISO 4217 (без драгоценных металлов и расчетных единиц).
+var codes = map[string]bool{}
+
+func init() {
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL BSD BTN BWP BYN BZD
+		CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD
+		GNF GTQ GYD HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT
+		LAK LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR
+		NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP
+		STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XCG
+		XOF XPF YER ZAR ZMW ZWG`) {
+		codes[code] = true
+	}
+}
+
+// Normalize приводит код валюты к виду ISO 4217 (заглавные буквы без пробелов);
+// пустой код означает базовую валюту.
+func Normalize(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return Base
+	}
+	return code
+}
+
+// Validate проверяет, что code — действующий код валюты ISO 4217.
+func Validate(code string) error {
+	if !codes[code] {
+		return fmt.Errorf("unknown currency code %q: use an ISO 4217 code such as RUB, USD or EUR", code)
+	}
+	return nil
+}
+
+// ValidateRate проверяет курс валюты к базовой: положительный, меньше MaxRate, не более 6 знаков после запятой.
+func ValidateRate(rate decimal.Decimal) error {
+	if !rate.IsPositive() || !rate.LessThan(MaxRate) {
+		return fmt.Errorf("rate must be positive and less than %s", MaxRate)
+	}
+	if !rate.Equal(rate.Round(6)) {
+		return fmt.Errorf("rate must have at most 6 decimal places")
+	}
+	return nil
+}
+
+// ToBase пересчитывает сумму в базовую валюту по курсу (рублей за единицу валюты)
+// с округлением до копеек.
+func ToBase(amount, rate decimal.Decimal) decimal.Decimal {
+	return amount.Mul(rate).Round(2)
+}
@@ -0,0 +1,49 @@
+package currency
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{"": Base, " usd ": "USD", "Eur": "EUR"}
+	for in, want := range tests {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, code := range []string{"RUB", "USD", "EUR", "CNY", "KZT"} {
+		if err := Validate(code); err != nil {
+			t.Errorf("Validate(%q): %v", code, err)
+		}
+	}
+	for _, code := range []string{"", "usd", "RUR", "XAU", "ABC", "USDT"} {
+		if err := Validate(code); err == nil {
+			t.Errorf("Validate(%q): expected error", code)
+		}
+	}
+}
+
+func TestValidateRate(t *testing.T) {
+	for _, rate := range []string{"92.5", "0.000001", "999999.999999"} {
+		if err := ValidateRate(decimal.RequireFromString(rate)); err != nil {
+			t.Errorf("ValidateRate(%s): %v", rate, err)
+		}
+	}
+	for _, rate := range []string{"0", "-1", "1000000", "1.0000001"} {
+		if err := ValidateRate(decimal.RequireFromString(rate)); err == nil {
+			t.Errorf("ValidateRate(%s): expected error", rate)
+		}
+	}
+}
+
+func TestToBase(t *testing.T) {
+	got := ToBase(decimal.RequireFromString("1234.56"), decimal.RequireFromString("92.456789"))
+	if want := decimal.RequireFromString("114143.45"); !got.Equal(want) {
+		t.Errorf("ToBase = %s, want %s", got, want)
+	}
+}
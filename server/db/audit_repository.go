@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuditRepository предоставляет методы для работы с журналом аудита audit_events.
+// Реализует middleware.AuditStore.
+type AuditRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewAuditRepository создаёт новый AuditRepository.
+func NewAuditRepository(pool *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{pool: pool}
+}
+
+// AuditFilter — условия выборки журнала аудита. Пустые поля не фильтруют.
+type AuditFilter struct {
+	ActorID    *int
+	Action     string
+	EntityType string
+	EntityID   string
+	From       *time.Time
+	To         *time.Time
+}
+
+// RecordAuditEvent добавляет запись в журнал аудита.
+func (repo *AuditRepository) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	query := `
+		INSERT INTO audit_events (actor_id, actor_role, action, method, path, entity_type, entity_id, ip, status_code, before, after)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id, occurred_at
+	`
+	err := repo.pool.QueryRow(ctx, query,
+		event.ActorID, event.ActorRole, event.Action, event.Method, event.Path,
+		event.EntityType, event.EntityID, event.IP, event.StatusCode,
+		event.Before, event.After,
+	).Scan(&event.ID, &event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents возвращает записи журнала по фильтру, новые сверху, и их общее количество.
+func (repo *AuditRepository) ListAuditEvents(ctx context.Context, filter AuditFilter, limit, offset int) ([]models.AuditEvent, int64, error) {
+	whereClauses := []string{}
+	args := []interface{}{}
+	addClause := func(clause string, value interface{}) {
+		args = append(args, value)
+		whereClauses = append(whereClauses, fmt.Sprintf(clause, len(args)))
+	}
+
+	if filter.ActorID != nil {
+		addClause("actor_id = $%d", *filter.ActorID)
+	}
+	if filter.Action != "" {
+		addClause("action = $%d", filter.Action)
+	}
+	if filter.EntityType != "" {
+		addClause("entity_type = $%d", filter.EntityType)
+	}
+	if filter.EntityID != "" {
+		addClause("entity_id = $%d", filter.EntityID)
+	}
+	if filter.From != nil {
+		addClause("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addClause("occurred_at < $%d", *filter.To)
+	}
+
+	whereQuery := ""
+	if len(whereClauses) > 0 {
+		whereQuery = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	var total int64
+	if err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) FROM audit_events "+whereQuery, args...).Scan(&total); err != nil {
+		log.Printf("Error counting audit events: %v", err)
+		return nil, 0, fmt.Errorf("failed to count audit events: %w", err)
+	}
+	if total == 0 {
+		return []models.AuditEvent{}, 0, nil
+	}
+
+	dataQuery := fmt.Sprintf(`
+		SELECT id, occurred_at, actor_id, actor_role, action, method, path,
+		       entity_type, entity_id, ip, status_code, before, after
+		FROM audit_events
+		%s
+		ORDER BY occurred_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, whereQuery, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := repo.pool.Query(ctx, dataQuery, args...)
+	if err != nil {
+		log.Printf("Error listing audit events: %v", err)
+		return nil, 0, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.AuditEvent, 0, limit)
+	for rows.Next() {
+		var e models.AuditEvent
+		if err := rows.Scan(
+			&e.ID, &e.OccurredAt, &e.ActorID, &e.ActorRole, &e.Action, &e.Method, &e.Path,
+			&e.EntityType, &e.EntityID, &e.IP, &e.StatusCode, &e.Before, &e.After,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error after iterating through audit events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// requestSnapshot возвращает строку заявки как JSON-объект для журнала аудита.
+// Если аудит для контекста не ведется или снимок получить не удалось, возвращает nil.
+func requestSnapshot(ctx context.Context, tx pgx.Tx, requestID int) map[string]any {
+	return rowSnapshot(ctx, tx, "SELECT to_jsonb(r) FROM requests r WHERE r.id = $1", "request", requestID)
+}
+
+// userSnapshot возвращает строку пользователя для журнала аудита без хеша пароля.
+func userSnapshot(ctx context.Context, tx pgx.Tx, userID int) map[string]any {
+	return rowSnapshot(ctx, tx, "SELECT to_jsonb(u) - 'password_hash' FROM users u WHERE u.id = $1", "user", userID)
+}
+
+// partnerSnapshot возвращает строку партнера для журнала аудита.
+func partnerSnapshot(ctx context.Context, tx pgx.Tx, partnerID int) map[string]any {
+	return rowSnapshot(ctx, tx, "SELECT to_jsonb(p) FROM partners p WHERE p.id = $1", "partner", partnerID)
+}
+
+// endClientSnapshot возвращает строку конечного клиента для журнала аудита.
+func endClientSnapshot(ctx context.Context, tx pgx.Tx, endClientID int) map[string]any {
+	return rowSnapshot(ctx, tx, "SELECT to_jsonb(e) FROM end_clients e WHERE e.id = $1", "end_client", endClientID)
+}
+
+// productSnapshot возвращает строку продукта каталога для журнала аудита.
+func productSnapshot(ctx context.Context, tx pgx.Tx, productID int) map[string]any {
+	return rowSnapshot(ctx, tx, "SELECT to_jsonb(p) FROM products p WHERE p.id = $1", "product", productID)
+}
+
+// exchangeRateSnapshot возвращает строку курса валюты для журнала аудита.
+func exchangeRateSnapshot(ctx context.Context, tx pgx.Tx, rateID int) map[string]any {
+	return rowSnapshot(ctx, tx, "SELECT to_jsonb(x) FROM exchange_rates x WHERE x.id = $1", "exchange_rate", rateID)
+}
+
+func rowSnapshot(ctx context.Context, tx pgx.Tx, query, entityType string, id int) map[string]any {
+	if !audit.Enabled(ctx) {
+		return nil
+	}
+	var snapshot map[string]any
+	if err := tx.QueryRow(ctx, query, id).Scan(&snapshot); err != nil {
+		log.Printf("Error taking audit snapshot of %s %d: %v", entityType, id, err)
+		return nil
+	}
+	return snapshot
+}
@@ -0,0 +1,173 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// requestFileLinks — все привязки файлов к заявкам: вложения самой заявки и вложения сообщений переписки.
+// Используется в проверках доступа к файлам и при поиске осиротевших файлов.
+const requestFileLinks = `(
+	SELECT request_id, file_id FROM request_files
+	UNION ALL
+	SELECT c.request_id, cf.file_id
+	FROM request_comment_files cf
+	JOIN request_comments c ON c.id = cf.comment_id
+)`
+
+// unreadCommentsColumn — подзапрос для списков заявок: число чужих сообщений,
+// появившихся после последнего прочтения переписки пользователем $1.
+const unreadCommentsColumn = `(
+	SELECT COUNT(*)
+	FROM request_comments c
+	LEFT JOIN request_comment_reads rr ON rr.request_id = c.request_id AND rr.user_id = $1
+	WHERE c.request_id = r.id
+	  AND c.author_id IS DISTINCT FROM $1
+	  AND (rr.last_read_at IS NULL OR c.created_at > rr.last_read_at)
+) AS unread_comments`
+
+// CreateComment добавляет сообщение в переписку по заявке и привязывает к нему загруженные файлы.
+// Заполняет ID и CreatedAt переданного сообщения.
+func (repo *RequestRepository) CreateComment(ctx context.Context, comment *models.Comment, fileIDs []int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO request_comments (request_id, author_id, body)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(ctx, query, comment.RequestID, comment.AuthorID, comment.Body).Scan(&comment.ID, &comment.CreatedAt)
+	if err != nil {
+		log.Printf("Error inserting comment for request %d: %v", comment.RequestID, err)
+		return fmt.Errorf("failed to insert comment: %w", err)
+	}
+
+	if len(fileIDs) > 0 {
+		rows := make([][]interface{}, len(fileIDs))
+		for i, fileID := range fileIDs {
+			rows[i] = []interface{}{comment.ID, fileID}
+		}
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{"request_comment_files"}, []string{"comment_id", "file_id"}, pgx.CopyFromRows(rows))
+		if err != nil {
+			return fmt.Errorf("failed to link files to comment: %w", err)
+		}
+	}
+
+	// Автор своё сообщение уже видел
+	if comment.AuthorID != nil {
+		if err := markCommentsRead(ctx, tx, comment.RequestID, *comment.AuthorID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request_comment", comment.ID, nil, map[string]any{
+		"request_id": comment.RequestID,
+		"body":       comment.Body,
+		"file_ids":   fileIDs,
+	})
+	return nil
+}
+
+// ListComments возвращает переписку по заявке в хронологическом порядке вместе с вложениями.
+func (repo *RequestRepository) ListComments(ctx context.Context, requestID int) ([]models.Comment, error) {
+	query := `
+		SELECT c.id, c.request_id, c.author_id, u.name, u.role, c.body, c.created_at
+		FROM request_comments c
+		LEFT JOIN users u ON c.author_id = u.id
+		WHERE c.request_id = $1
+		ORDER BY c.created_at, c.id
+	`
+	rows, err := repo.pool.Query(ctx, query, requestID)
+	if err != nil {
+		log.Printf("Error listing comments for request %d: %v", requestID, err)
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	index := map[int]int{}
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.RequestID, &c.AuthorID, &c.AuthorName, &c.AuthorRole, &c.Body, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment row: %w", err)
+		}
+		c.Files = []*models.File{}
+		index[c.ID] = len(comments)
+		comments = append(comments, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating through comment rows: %w", err)
+	}
+	if len(comments) == 0 {
+		return comments, nil
+	}
+
+	fileQuery := `
+		SELECT cf.comment_id, f.id, f.file_name, f.mime_type, f.file_size, f.sha256, f.created_at
+		FROM request_comment_files cf
+		JOIN request_comments c ON c.id = cf.comment_id
+		JOIN files f ON f.id = cf.file_id
+		WHERE c.request_id = $1
+		ORDER BY f.id
+	`
+	fileRows, err := repo.pool.Query(ctx, fileQuery, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query comment files: %w", err)
+	}
+	defer fileRows.Close()
+
+	for fileRows.Next() {
+		var commentID int
+		var file models.File
+		if err := fileRows.Scan(&commentID, &file.ID, &file.FileName, &file.MimeType, &file.FileSize, &file.SHA256, &file.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan comment file row: %w", err)
+		}
+		if i, ok := index[commentID]; ok {
+			comments[i].Files = append(comments[i].Files, &file)
+		}
+	}
+	if err := fileRows.Err(); err != nil {
+		return nil, fmt.Errorf("error after iterating through comment file rows: %w", err)
+	}
+
+	return comments, nil
+}
+
+// MarkCommentsRead отмечает переписку по заявке прочитанной пользователем на текущий момент.
+func (repo *RequestRepository) MarkCommentsRead(ctx context.Context, requestID, userID int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := markCommentsRead(ctx, tx, requestID, userID); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func markCommentsRead(ctx context.Context, tx pgx.Tx, requestID, userID int) error {
+	query := `
+		INSERT INTO request_comment_reads (request_id, user_id, last_read_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (request_id, user_id) DO UPDATE SET last_read_at = EXCLUDED.last_read_at
+	`
+	if _, err := tx.Exec(ctx, query, requestID, userID); err != nil {
+		log.Printf("Error marking comments read for request %d, user %d: %v", requestID, userID, err)
+		return fmt.Errorf("failed to mark comments read: %w", err)
+	}
+	return nil
+}
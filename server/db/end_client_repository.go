@@ -4,7 +4,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 
+	"github.com/eeephemera/zvk-requests/server/audit"
 	"github.com/eeephemera/zvk-requests/server/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,18 +23,35 @@ func NewEndClientRepository(pool *pgxpool.Pool) *EndClientRepository {
 	return &EndClientRepository{pool: pool}
 }
 
+const endClientColumns = `id, name, city, inn, kpp, ogrn, org_type, full_address, contact_person_details, merged_into_id, created_at, updated_at`
+
+func scanEndClient(row pgx.Row, client *models.EndClient) error {
+	return row.Scan(
+		&client.ID, &client.Name, &client.City, &client.INN, &client.KPP, &client.OGRN, &client.OrgType,
+		&client.FullAddress, &client.ContactPersonDetails, &client.MergedIntoID, &client.CreatedAt, &client.UpdatedAt,
+	)
+}
+
+// EndClientFilter — условия нечеткого поиска конечных клиентов. Пустые поля не фильтруют.
+type EndClientFilter struct {
+	Query string // Название (по сходству триграмм) или начало ИНН
+	City  string
+	// PartnerUserID — только клиенты из заявок партнера этого пользователя
+	PartnerUserID int
+}
+
 // CreateEndClient вставляет нового конечного клиента в таблицу end_clients.
 // Возвращает созданный объект EndClient с ID и временными метками.
 func (repo *EndClientRepository) CreateEndClient(ctx context.Context, client *models.EndClient) error {
 	query := `
 		INSERT INTO end_clients (
-			name, city, inn, full_address, contact_person_details
-		) VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at
+			name, city, inn, kpp, ogrn, full_address, contact_person_details
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, org_type, created_at, updated_at
 	`
 	err := repo.pool.QueryRow(ctx, query,
-		client.Name, client.City, client.INN, client.FullAddress, client.ContactPersonDetails,
-	).Scan(&client.ID, &client.CreatedAt, &client.UpdatedAt)
+		client.Name, client.City, client.INN, client.KPP, client.OGRN, client.FullAddress, client.ContactPersonDetails,
+	).Scan(&client.ID, &client.OrgType, &client.CreatedAt, &client.UpdatedAt)
 
 	if err != nil {
 		// TODO: Добавить обработку уникальных ограничений (например, по ИНН)
@@ -43,21 +63,11 @@ func (repo *EndClientRepository) CreateEndClient(ctx context.Context, client *mo
 
 // GetEndClientByID возвращает конечного клиента по его ID.
 func (repo *EndClientRepository) GetEndClientByID(ctx context.Context, id int) (*models.EndClient, error) {
-	query := `
-		SELECT id, name, city, inn, full_address, contact_person_details, created_at, updated_at
-		FROM end_clients
-		WHERE id = $1
-	`
+	query := `SELECT ` + endClientColumns + ` FROM end_clients WHERE id = $1`
 	var client models.EndClient
-	err := repo.pool.QueryRow(ctx, query, id).Scan(
-		&client.ID, &client.Name, &client.City, &client.INN,
-		&client.FullAddress, &client.ContactPersonDetails, &client.CreatedAt, &client.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanEndClient(repo.pool.QueryRow(ctx, query, id), &client); err != nil {
 		if err == pgx.ErrNoRows {
-			// Используем более специфичную ошибку, если нужно
-			// return nil, ErrNotFound
-			return nil, fmt.Errorf("end client not found: id=%d", id)
+			return nil, fmt.Errorf("%w: end client id=%d", ErrNotFound, id)
 		}
 		log.Printf("Error fetching end client by ID %d: %v", id, err)
 		return nil, fmt.Errorf("failed to fetch end client: %w", err)
@@ -65,24 +75,29 @@ func (repo *EndClientRepository) GetEndClientByID(ctx context.Context, id int) (
 	return &client, nil
 }
 
+// ResolveEndClient возвращает конечного клиента по ID, а для объединенного дубликата — основного клиента.
+func (repo *EndClientRepository) ResolveEndClient(ctx context.Context, id int) (*models.EndClient, error) {
+	client, err := repo.GetEndClientByID(ctx, id)
+	if err != nil || client.MergedIntoID == nil {
+		return client, err
+	}
+	return repo.GetEndClientByID(ctx, *client.MergedIntoID)
+}
+
 // FindEndClientByINN ищет конечного клиента по ИНН.
+// Если клиент с этим ИНН был объединен с другим, возвращается основной клиент.
 // Может вернуть nil, nil, если клиент не найден.
 func (repo *EndClientRepository) FindEndClientByINN(ctx context.Context, inn string) (*models.EndClient, error) {
 	if inn == "" { // Не ищем по пустому ИНН
 		return nil, nil
 	}
 	query := `
-		SELECT id, name, city, inn, full_address, contact_person_details, created_at, updated_at
+		SELECT ` + endClientColumns + `
 		FROM end_clients
-		WHERE inn = $1
-		LIMIT 1 
+		WHERE id = (SELECT COALESCE(merged_into_id, id) FROM end_clients WHERE inn = $1 LIMIT 1)
 	` // LIMIT 1 на всякий случай, хотя ИНН должен быть UNIQUE
 	var client models.EndClient
-	err := repo.pool.QueryRow(ctx, query, inn).Scan(
-		&client.ID, &client.Name, &client.City, &client.INN,
-		&client.FullAddress, &client.ContactPersonDetails, &client.CreatedAt, &client.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanEndClient(repo.pool.QueryRow(ctx, query, inn), &client); err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, nil // Не найдено - это не ошибка в данном контексте
 		}
@@ -92,4 +107,253 @@ func (repo *EndClientRepository) FindEndClientByINN(ctx context.Context, inn str
 	return &client, nil
 }
 
-// TODO: Добавить методы ListEndClients, UpdateEndClient, DeleteEndClient по необходимости.
+// SearchEndClients выполняет нечеткий поиск по названию и городу (pg_trgm).
+// Результаты упорядочены по сходству названия; объединенные дубликаты не возвращаются.
+func (repo *EndClientRepository) SearchEndClients(ctx context.Context, filter EndClientFilter, limit, offset int) ([]models.EndClient, int64, error) {
+	whereClauses := []string{"merged_into_id IS NULL"}
+	args := []interface{}{}
+	orderBy := "name ASC, id ASC"
+	if filter.Query != "" {
+		args = append(args, filter.Query)
+		n := len(args)
+		whereClauses = append(whereClauses, fmt.Sprintf(
+			"(name %% $%d OR name ILIKE '%%' || $%d || '%%' OR inn LIKE $%d || '%%')", n, n, n))
+		orderBy = fmt.Sprintf("similarity(name, $%d) DESC, name ASC, id ASC", n)
+	}
+	if filter.City != "" {
+		args = append(args, filter.City)
+		n := len(args)
+		whereClauses = append(whereClauses, fmt.Sprintf("(city %% $%d OR city ILIKE '%%' || $%d || '%%')", n, n))
+	}
+	if filter.PartnerUserID != 0 {
+		args = append(args, filter.PartnerUserID)
+		whereClauses = append(whereClauses, fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM requests r JOIN users u ON u.partner_id = r.partner_id
+			WHERE u.id = $%d AND r.end_client_id = end_clients.id AND r.deleted_at IS NULL)`, len(args)))
+	}
+	whereQuery := "WHERE " + strings.Join(whereClauses, " AND ")
+
+	var total int64
+	if err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) FROM end_clients "+whereQuery, args...).Scan(&total); err != nil {
+		log.Printf("Error counting end clients: %v", err)
+		return nil, 0, fmt.Errorf("failed to count end clients: %w", err)
+	}
+	if total == 0 {
+		return []models.EndClient{}, 0, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM end_clients
+		%s
+		ORDER BY %s
+		LIMIT $%d OFFSET $%d
+	`, endClientColumns, whereQuery, orderBy, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := repo.pool.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error searching end clients: %v", err)
+		return nil, 0, fmt.Errorf("failed to search end clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := make([]models.EndClient, 0, limit)
+	for rows.Next() {
+		var client models.EndClient
+		if err := scanEndClient(rows, &client); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan end client row: %w", err)
+		}
+		clients = append(clients, client)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating end client rows: %w", err)
+	}
+	return clients, total, nil
+}
+
+// UpdateEndClient обновляет реквизиты конечного клиента.
+// Объединенный дубликат изменить нельзя (ErrInvalidState).
+func (repo *EndClientRepository) UpdateEndClient(ctx context.Context, client *models.EndClient) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := lockActiveEndClient(ctx, tx, client.ID); err != nil {
+		return err
+	}
+	before := endClientSnapshot(ctx, tx, client.ID)
+
+	query := `
+		UPDATE end_clients
+		SET name = $1, city = $2, inn = $3, kpp = $4, ogrn = $5, full_address = $6, contact_person_details = $7,
+		    updated_at = NOW()
+		WHERE id = $8
+		RETURNING org_type, created_at, updated_at
+	`
+	err = tx.QueryRow(ctx, query,
+		client.Name, client.City, client.INN, client.KPP, client.OGRN, client.FullAddress, client.ContactPersonDetails, client.ID,
+	).Scan(&client.OrgType, &client.CreatedAt, &client.UpdatedAt)
+	if err != nil {
+		log.Printf("Error updating end client %d: %v", client.ID, err)
+		return fmt.Errorf("failed to update end client: %w", err)
+	}
+
+	after := endClientSnapshot(ctx, tx, client.ID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "end_client", client.ID, before, after)
+	return nil
+}
+
+// MergeEndClients объединяет дубликаты с основным клиентом survivorID: все заявки дубликатов
+// переводятся на основного клиента, пустые реквизиты основного дополняются из дубликатов,
+// а дубликаты помечаются merged_into_id. Возвращает основного клиента и ID перенесенных заявок.
+// Возвращает ErrInvalidState, если основной клиент или дубликат уже объединены с другим.
+func (repo *EndClientRepository) MergeEndClients(ctx context.Context, survivorID int, duplicateIDs []int) (*models.EndClient, []int, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем основного клиента и дубликаты одним запросом в порядке ID,
+	// чтобы параллельные объединения не взаимоблокировались
+	rows, err := tx.Query(ctx, `
+		SELECT `+endClientColumns+`
+		FROM end_clients
+		WHERE id = $1 OR id = ANY($2)
+		ORDER BY id
+		FOR UPDATE`, survivorID, duplicateIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lock end clients: %w", err)
+	}
+	var survivor *models.EndClient
+	duplicates := []models.EndClient{}
+	for rows.Next() {
+		var c models.EndClient
+		if err := scanEndClient(rows, &c); err != nil {
+			rows.Close()
+			return nil, nil, fmt.Errorf("failed to scan end client: %w", err)
+		}
+		if c.ID == survivorID {
+			survivor = &c
+		} else {
+			duplicates = append(duplicates, c)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating end clients: %w", err)
+	}
+	if survivor == nil || len(duplicates) != len(duplicateIDs) {
+		return nil, nil, fmt.Errorf("%w: end client or some duplicates do not exist", ErrNotFound)
+	}
+	if survivor.MergedIntoID != nil {
+		return nil, nil, fmt.Errorf("%w: end client %d is already merged", ErrInvalidState, survivor.ID)
+	}
+	for _, d := range duplicates {
+		if d.MergedIntoID != nil {
+			return nil, nil, fmt.Errorf("%w: end client %d is already merged", ErrInvalidState, d.ID)
+		}
+	}
+	before := endClientSnapshot(ctx, tx, survivorID)
+
+	// Переносим заявки (включая удаленные в корзину и отозванные — ссылка должна остаться валидной).
+	// Проверка пересечений на основного клиента блокируется до конца переноса
+	if err := lockEndClientConflicts(ctx, tx, survivorID); err != nil {
+		return nil, nil, err
+	}
+	movedRows, err := tx.Query(ctx, `
+		UPDATE requests SET end_client_id = $1, updated_at = NOW()
+		WHERE end_client_id = ANY($2)
+		RETURNING id`, survivorID, duplicateIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to move requests: %w", err)
+	}
+	movedIDs, err := pgx.CollectRows(movedRows, pgx.RowTo[int])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to move requests: %w", err)
+	}
+	// Дубликаты от разных партнеров после объединения оказываются на одном клиенте:
+	// пересчитываем пересечения перенесенных заявок с более ранними и более поздними
+	slices.Sort(movedIDs)
+	for _, id := range movedIDs {
+		if err := detectConflicts(ctx, tx, id); err != nil {
+			return nil, nil, err
+		}
+		if err := refreshLaterConflicts(ctx, tx, id); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Дубликаты, ранее объединенные с текущими дубликатами, теперь указывают на основного клиента
+	_, err = tx.Exec(ctx, `
+		UPDATE end_clients SET merged_into_id = $1
+		WHERE merged_into_id = ANY($2)`, survivorID, duplicateIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to re-point merged end clients: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE end_clients SET merged_into_id = $1, merged_at = NOW(), updated_at = NOW()
+		WHERE id = ANY($2)`, survivorID, duplicateIDs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to mark duplicates merged: %w", err)
+	}
+
+	// ИНН остается у дубликата (уникальность), поиск по нему приведет к основному клиенту
+	for _, d := range duplicates {
+		survivor.City = firstNonEmpty(survivor.City, d.City)
+		survivor.FullAddress = firstNonEmpty(survivor.FullAddress, d.FullAddress)
+		survivor.ContactPersonDetails = firstNonEmpty(survivor.ContactPersonDetails, d.ContactPersonDetails)
+	}
+	err = tx.QueryRow(ctx, `
+		UPDATE end_clients
+		SET city = $1, full_address = $2, contact_person_details = $3, updated_at = NOW()
+		WHERE id = $4
+		RETURNING updated_at`,
+		survivor.City, survivor.FullAddress, survivor.ContactPersonDetails, survivorID,
+	).Scan(&survivor.UpdatedAt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to update survivor: %w", err)
+	}
+
+	after := endClientSnapshot(ctx, tx, survivorID)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	if after != nil {
+		after["merged_from"] = duplicateIDs
+		after["moved_request_ids"] = movedIDs
+	}
+	audit.RecordChange(ctx, "end_client", survivorID, before, after)
+	return survivor, movedIDs, nil
+}
+
+// lockActiveEndClient блокирует клиента для изменения. Объединенный дубликат — ErrInvalidState.
+func lockActiveEndClient(ctx context.Context, tx pgx.Tx, id int) (*models.EndClient, error) {
+	query := `SELECT ` + endClientColumns + ` FROM end_clients WHERE id = $1 FOR UPDATE`
+	var client models.EndClient
+	if err := scanEndClient(tx.QueryRow(ctx, query, id), &client); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock end client: %w", err)
+	}
+	if client.MergedIntoID != nil {
+		return nil, fmt.Errorf("%w: end client %d is merged into %d", ErrInvalidState, id, *client.MergedIntoID)
+	}
+	return &client, nil
+}
+
+func firstNonEmpty(values ...*string) *string {
+	for _, v := range values {
+		if v != nil && strings.TrimSpace(*v) != "" {
+			return v
+		}
+	}
+	return nil
+}
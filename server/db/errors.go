@@ -14,6 +14,14 @@ var (
 	ErrAlreadyExists = errors.New("already exists")
 	// ErrNotFound универсальная ошибка для не найденных записей.
 	ErrNotFound = errors.New("record not found")
+	// ErrInvalidState возвращается, когда операция невозможна в текущем состоянии записи.
+	ErrInvalidState = errors.New("invalid record state")
+	// ErrForbidden возвращается, когда операция над записью не разрешена вызывающему пользователю.
+	ErrForbidden = errors.New("operation is not permitted")
+	// ErrProtectionNotExtended возвращается, когда новая дата окончания защиты сделки не позже текущей.
+	ErrProtectionNotExtended = errors.New("protection end date is not extended")
+	// ErrNoExchangeRate возвращается, когда для валюты суммы не задан курс к рублю.
+	ErrNoExchangeRate = errors.New("exchange rate is not set")
 )
 
 // IsUniqueConstraintViolation проверяет, является ли ошибка ошибкой
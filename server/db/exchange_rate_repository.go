@@ -0,0 +1,153 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/currency"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRateRepository предоставляет методы для работы с курсами валют (таблица exchange_rates).
+type ExchangeRateRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewExchangeRateRepository создаёт новый ExchangeRateRepository.
+func NewExchangeRateRepository(pool *pgxpool.Pool) *ExchangeRateRepository {
+	return &ExchangeRateRepository{pool: pool}
+}
+
+const exchangeRateColumns = `id, currency, rate, updated_by, updated_at`
+
+func scanExchangeRate(row pgx.Row, rate *models.ExchangeRate) error {
+	return row.Scan(&rate.ID, &rate.Currency, &rate.Rate, &rate.UpdatedBy, &rate.UpdatedAt)
+}
+
+// ListExchangeRates возвращает заданные курсы валют, отсортированные по коду валюты.
+func (repo *ExchangeRateRepository) ListExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	rows, err := repo.pool.Query(ctx, `SELECT `+exchangeRateColumns+` FROM exchange_rates ORDER BY currency`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exchange rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []models.ExchangeRate{}
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := scanExchangeRate(rows, &rate); err != nil {
+			return nil, fmt.Errorf("failed to scan exchange rate row: %w", err)
+		}
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating exchange rate rows: %w", err)
+	}
+	return rates, nil
+}
+
+// GetExchangeRate возвращает курс валюты к рублю или ErrNotFound, если курс не задан.
+func (repo *ExchangeRateRepository) GetExchangeRate(ctx context.Context, code string) (*models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := scanExchangeRate(repo.pool.QueryRow(ctx, `SELECT `+exchangeRateColumns+` FROM exchange_rates WHERE currency = $1`, code), &rate)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: exchange rate %s", ErrNotFound, code)
+		}
+		return nil, fmt.Errorf("failed to fetch exchange rate: %w", err)
+	}
+	return &rate, nil
+}
+
+// SetExchangeRate задает курс валюты к рублю (создает или заменяет). Суммы уже сохраненных
+// заявок не пересчитываются: они хранят курс на момент сохранения.
+func (repo *ExchangeRateRepository) SetExchangeRate(ctx context.Context, code string, value decimal.Decimal, userID int) (*models.ExchangeRate, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var before map[string]any
+	var existingID int
+	err = tx.QueryRow(ctx, "SELECT id FROM exchange_rates WHERE currency = $1 FOR UPDATE", code).Scan(&existingID)
+	switch {
+	case err == nil:
+		before = exchangeRateSnapshot(ctx, tx, existingID)
+	case err != pgx.ErrNoRows:
+		return nil, fmt.Errorf("failed to lock exchange rate: %w", err)
+	}
+
+	query := `
+        INSERT INTO exchange_rates (currency, rate, updated_by)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (currency) DO UPDATE
+        SET rate = EXCLUDED.rate, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+        RETURNING ` + exchangeRateColumns
+	var rate models.ExchangeRate
+	if err := scanExchangeRate(tx.QueryRow(ctx, query, code, value, userID), &rate); err != nil {
+		return nil, fmt.Errorf("failed to save exchange rate: %w", err)
+	}
+
+	after := exchangeRateSnapshot(ctx, tx, rate.ID)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "exchange_rate", rate.ID, before, after)
+	return &rate, nil
+}
+
+// DeleteExchangeRate удаляет курс валюты: новые заявки в этой валюте сохранить будет нельзя.
+func (repo *ExchangeRateRepository) DeleteExchangeRate(ctx context.Context, code string) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	if err := tx.QueryRow(ctx, "SELECT id FROM exchange_rates WHERE currency = $1 FOR UPDATE", code).Scan(&id); err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock exchange rate: %w", err)
+	}
+	before := exchangeRateSnapshot(ctx, tx, id)
+	if _, err := tx.Exec(ctx, "DELETE FROM exchange_rates WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete exchange rate: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "exchange_rate", id, before, nil)
+	return nil
+}
+
+// applyExchangeRate записывает в заявку курс ее валюты к рублю и сумму в рублях в рамках
+// транзакции сохранения заявки. Для валюты без курса возвращает ErrNoExchangeRate.
+func applyExchangeRate(ctx context.Context, tx pgx.Tx, req *models.Request) error {
+	if req.Currency == "" {
+		req.Currency = currency.Base
+	}
+	rate := decimal.NewFromInt(1)
+	if req.Currency != currency.Base {
+		err := tx.QueryRow(ctx, "SELECT rate FROM exchange_rates WHERE currency = $1 FOR SHARE", req.Currency).Scan(&rate)
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return fmt.Errorf("%w: currency %s", ErrNoExchangeRate, req.Currency)
+			}
+			return fmt.Errorf("failed to fetch exchange rate: %w", err)
+		}
+	}
+	req.ExchangeRate = &rate
+	req.TotalPriceBase = nil
+	if req.TotalPrice != nil {
+		base := currency.ToBase(*req.TotalPrice, rate)
+		req.TotalPriceBase = &base
+	}
+	return nil
+}
@@ -0,0 +1,373 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// JoinRequestRepository предоставляет методы для работы с заявками на присоединение к партнеру.
+type JoinRequestRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewJoinRequestRepository создаёт новый JoinRequestRepository.
+func NewJoinRequestRepository(pool *pgxpool.Pool) *JoinRequestRepository {
+	return &JoinRequestRepository{pool: pool}
+}
+
+const joinRequestColumns = `
+	j.id, j.user_id, j.partner_inn, j.partner_name, j.partner_id, j.status,
+	j.reviewer_id, j.review_comment, j.created_at, j.reviewed_at`
+
+func scanJoinRequest(row pgx.Row, jr *models.JoinRequest, extra ...any) error {
+	dest := append([]any{
+		&jr.ID, &jr.UserID, &jr.PartnerINN, &jr.PartnerName, &jr.PartnerID, &jr.Status,
+		&jr.ReviewerID, &jr.ReviewComment, &jr.CreatedAt, &jr.ReviewedAt,
+	}, extra...)
+	return row.Scan(dest...)
+}
+
+// RegisterUser создает пользователя без партнера и его заявку на присоединение в одной транзакции.
+// Если партнер с указанным ИНН уже есть, он сразу связывается с заявкой.
+func (repo *JoinRequestRepository) RegisterUser(ctx context.Context, user *models.User, jr *models.JoinRequest) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertUser(ctx, tx, user); err != nil {
+		return err
+	}
+	jr.UserID = user.ID
+	if err := insertJoinRequest(ctx, tx, jr); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// CreateJoinRequest подает повторную заявку пользователя, еще не привязанного к партнеру.
+// Возвращает ErrInvalidState, если пользователь уже привязан или его заявка ждет рассмотрения.
+func (repo *JoinRequestRepository) CreateJoinRequest(ctx context.Context, jr *models.JoinRequest) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var partnerID *int
+	err = tx.QueryRow(ctx, "SELECT partner_id FROM users WHERE id = $1 FOR UPDATE", jr.UserID).Scan(&partnerID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+	if partnerID != nil {
+		return fmt.Errorf("%w: user already belongs to a partner", ErrInvalidState)
+	}
+
+	var pending bool
+	err = tx.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM partner_join_requests WHERE user_id = $1 AND status = 'PENDING')", jr.UserID,
+	).Scan(&pending)
+	if err != nil {
+		return fmt.Errorf("failed to check pending join requests: %w", err)
+	}
+	if pending {
+		return fmt.Errorf("%w: join request is already pending", ErrInvalidState)
+	}
+
+	if err := insertJoinRequest(ctx, tx, jr); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func insertJoinRequest(ctx context.Context, tx pgx.Tx, jr *models.JoinRequest) error {
+	// Партнер с таким ИНН может уже существовать — тогда рассматривающему остается только подтвердить
+	partnerID, err := findPartnerIDByINN(ctx, tx, jr.PartnerINN)
+	if err != nil {
+		return err
+	}
+	jr.PartnerID = partnerID
+	jr.Status = models.JoinRequestPending
+
+	query := `
+		INSERT INTO partner_join_requests (user_id, partner_inn, partner_name, partner_id, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(ctx, query, jr.UserID, jr.PartnerINN, jr.PartnerName, jr.PartnerID, jr.Status).Scan(&jr.ID, &jr.CreatedAt)
+	if err != nil {
+		log.Printf("Error creating join request for user %d: %v", jr.UserID, err)
+		return fmt.Errorf("failed to create join request: %w", err)
+	}
+	return nil
+}
+
+func findPartnerIDByINN(ctx context.Context, tx pgx.Tx, inn string) (*int, error) {
+	var id int
+	err := tx.QueryRow(ctx, "SELECT id FROM partners WHERE inn = $1", inn).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find partner by INN: %w", err)
+	}
+	return &id, nil
+}
+
+// GetLatestJoinRequestForUser возвращает последнюю заявку пользователя на присоединение к партнеру.
+func (repo *JoinRequestRepository) GetLatestJoinRequestForUser(ctx context.Context, userID int) (*models.JoinRequest, error) {
+	query := `SELECT ` + joinRequestColumns + `
+		FROM partner_join_requests j
+		WHERE j.user_id = $1
+		ORDER BY j.created_at DESC, j.id DESC
+		LIMIT 1`
+	var jr models.JoinRequest
+	if err := scanJoinRequest(repo.pool.QueryRow(ctx, query, userID), &jr); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch join request: %w", err)
+	}
+	return &jr, nil
+}
+
+// ListJoinRequests возвращает заявки на присоединение с данными пользователя, старые сверху.
+// Пустой status возвращает заявки в любом состоянии. Если managerID задан, возвращаются только заявки
+// к партнерам этого менеджера и заявки, для которых партнера еще нет (см. ApproveJoinRequest).
+func (repo *JoinRequestRepository) ListJoinRequests(ctx context.Context, status models.JoinRequestStatus, managerID *int, limit, offset int) ([]models.JoinRequest, int64, error) {
+	// Партнер заявки — привязанный к ней, а если его нет — найденный по ИНН из заявки
+	baseQuery := `
+		FROM partner_join_requests j
+		JOIN users u ON u.id = j.user_id
+		LEFT JOIN partners p ON p.id = COALESCE(j.partner_id, (SELECT id FROM partners WHERE inn = j.partner_inn))
+		WHERE ($1 = '' OR j.status = $1)
+		  AND ($2::int IS NULL OR p.id IS NULL OR p.assigned_manager_id = $2)
+	`
+
+	var total int64
+	err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) "+baseQuery, string(status), managerID).Scan(&total)
+	if err != nil {
+		log.Printf("Error counting join requests: %v", err)
+		return nil, 0, fmt.Errorf("failed to count join requests: %w", err)
+	}
+	if total == 0 {
+		return []models.JoinRequest{}, 0, nil
+	}
+
+	query := `SELECT ` + joinRequestColumns + `,
+		       u.login, u.name, u.email, u.phone
+		` + baseQuery + `
+		ORDER BY j.created_at ASC, j.id ASC
+		LIMIT $3 OFFSET $4`
+	rows, err := repo.pool.Query(ctx, query, string(status), managerID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing join requests: %v", err)
+		return nil, 0, fmt.Errorf("failed to list join requests: %w", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.JoinRequest, 0, limit)
+	for rows.Next() {
+		var jr models.JoinRequest
+		user := &models.User{}
+		if err := scanJoinRequest(rows, &jr, &user.Login, &user.Name, &user.Email, &user.Phone); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan join request row: %w", err)
+		}
+		user.ID = jr.UserID
+		user.Role = models.RoleUser
+		jr.User = user
+		list = append(list, jr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating join request rows: %w", err)
+	}
+	return list, total, nil
+}
+
+// ApproveJoinRequest одобряет заявку и привязывает пользователя к партнеру.
+// Партнер берется из partnerID, иначе из заявки (найден по ИНН), иначе создается по ИНН и названию из заявки.
+// Если asManager, рассматривающий должен быть ответственным менеджером партнера, иначе возвращается
+// ErrForbidden; созданный для заявки партнер закрепляется за ним. Администратор (asManager = false)
+// может привязать пользователя к любому партнеру.
+// Возвращает ErrInvalidState, если заявка уже рассмотрена.
+func (repo *JoinRequestRepository) ApproveJoinRequest(ctx context.Context, id, reviewerID int, asManager bool, partnerID *int, comment *string) (*models.JoinRequest, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	jr, err := lockPendingJoinRequest(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := joinRequestAuditView(jr)
+
+	var newManagerID *int
+	if asManager {
+		newManagerID = &reviewerID
+	}
+	if partnerID == nil {
+		partnerID = jr.PartnerID
+	}
+	var partnerManagerID *int
+	if partnerID != nil {
+		if partnerManagerID, err = lockPartnerManager(ctx, tx, *partnerID); err != nil {
+			return nil, err
+		}
+	} else {
+		// Партнер мог появиться после регистрации пользователя, в том числе при одновременном одобрении
+		// другой заявки с тем же ИНН: тогда берем существующего
+		var newID int
+		err = tx.QueryRow(ctx, `
+			INSERT INTO partners (name, inn, assigned_manager_id) VALUES ($1, $2, $3)
+			ON CONFLICT (inn) DO UPDATE SET inn = EXCLUDED.inn
+			RETURNING id, assigned_manager_id`,
+			jr.PartnerName, jr.PartnerINN, newManagerID,
+		).Scan(&newID, &partnerManagerID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create partner for join request: %w", err)
+		}
+		partnerID = &newID
+	}
+	if asManager && (partnerManagerID == nil || *partnerManagerID != reviewerID) {
+		return nil, fmt.Errorf("%w: partner %d is not assigned to manager %d", ErrForbidden, *partnerID, reviewerID)
+	}
+
+	tag, err := tx.Exec(ctx, "UPDATE users SET partner_id = $1 WHERE id = $2", *partnerID, jr.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach user to partner: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNotFound
+	}
+
+	if err := reviewJoinRequest(ctx, tx, jr, models.JoinRequestApproved, reviewerID, partnerID, comment); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "join_request", jr.ID, before, joinRequestAuditView(jr))
+	return jr, nil
+}
+
+// RejectJoinRequest отклоняет заявку с комментарием. Пользователь может подать новую.
+// Если asManager и у заявки уже есть партнер (привязанный или найденный по ИНН), рассматривающий
+// должен быть его ответственным менеджером, иначе возвращается ErrForbidden.
+// Возвращает ErrInvalidState, если заявка уже рассмотрена.
+func (repo *JoinRequestRepository) RejectJoinRequest(ctx context.Context, id, reviewerID int, asManager bool, comment *string) (*models.JoinRequest, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	jr, err := lockPendingJoinRequest(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := joinRequestAuditView(jr)
+
+	if asManager {
+		partnerID := jr.PartnerID
+		if partnerID == nil {
+			if partnerID, err = findPartnerIDByINN(ctx, tx, jr.PartnerINN); err != nil {
+				return nil, err
+			}
+		}
+		if partnerID != nil {
+			partnerManagerID, err := lockPartnerManager(ctx, tx, *partnerID)
+			if err != nil {
+				return nil, err
+			}
+			if partnerManagerID == nil || *partnerManagerID != reviewerID {
+				return nil, fmt.Errorf("%w: partner %d is not assigned to manager %d", ErrForbidden, *partnerID, reviewerID)
+			}
+		}
+	}
+
+	if err := reviewJoinRequest(ctx, tx, jr, models.JoinRequestRejected, reviewerID, jr.PartnerID, comment); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "join_request", jr.ID, before, joinRequestAuditView(jr))
+	return jr, nil
+}
+
+// lockPartnerManager возвращает ответственного менеджера партнера, блокируя строку партнера
+// до конца транзакции, чтобы менеджер не сменился во время рассмотрения заявки.
+func lockPartnerManager(ctx context.Context, tx pgx.Tx, partnerID int) (*int, error) {
+	var managerID *int
+	err := tx.QueryRow(ctx, "SELECT assigned_manager_id FROM partners WHERE id = $1 FOR SHARE", partnerID).Scan(&managerID)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to fetch partner for join request: %w", err)
+	}
+	return managerID, nil
+}
+
+func lockPendingJoinRequest(ctx context.Context, tx pgx.Tx, id int) (*models.JoinRequest, error) {
+	query := `SELECT ` + joinRequestColumns + `
+		FROM partner_join_requests j
+		WHERE j.id = $1
+		FOR UPDATE`
+	var jr models.JoinRequest
+	if err := scanJoinRequest(tx.QueryRow(ctx, query, id), &jr); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock join request: %w", err)
+	}
+	if jr.Status != models.JoinRequestPending {
+		return nil, fmt.Errorf("%w: join request is already %s", ErrInvalidState, jr.Status)
+	}
+	return &jr, nil
+}
+
+func reviewJoinRequest(ctx context.Context, tx pgx.Tx, jr *models.JoinRequest, status models.JoinRequestStatus, reviewerID int, partnerID *int, comment *string) error {
+	query := `
+		UPDATE partner_join_requests
+		SET status = $1, reviewer_id = $2, partner_id = $3, review_comment = $4, reviewed_at = NOW()
+		WHERE id = $5
+		RETURNING reviewed_at
+	`
+	if err := tx.QueryRow(ctx, query, status, reviewerID, partnerID, comment, jr.ID).Scan(&jr.ReviewedAt); err != nil {
+		log.Printf("Error reviewing join request %d: %v", jr.ID, err)
+		return fmt.Errorf("failed to update join request: %w", err)
+	}
+	jr.Status = status
+	jr.ReviewerID = &reviewerID
+	jr.PartnerID = partnerID
+	jr.ReviewComment = comment
+	return nil
+}
+
+func joinRequestAuditView(jr *models.JoinRequest) map[string]any {
+	view := map[string]any{
+		"status":     string(jr.Status),
+		"partner_id": nil,
+		"user_id":    jr.UserID,
+	}
+	if jr.PartnerID != nil {
+		view["partner_id"] = *jr.PartnerID
+	}
+	if jr.ReviewComment != nil {
+		view["review_comment"] = *jr.ReviewComment
+	}
+	return view
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.request_status_events;
@@ -0,0 +1,38 @@
+-- История изменения статусов заявок
+CREATE TABLE IF NOT EXISTS public.request_status_events
+(
+    id serial NOT NULL,
+    request_id integer NOT NULL,
+    old_status request_status_enum,
+    new_status request_status_enum NOT NULL,
+    manager_id integer,
+    comment text COLLATE pg_catalog."default",
+    changed_at timestamp with time zone NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT request_status_events_pkey PRIMARY KEY (id),
+    CONSTRAINT request_status_events_request_id_fkey FOREIGN KEY (request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_status_events_manager_id_fkey FOREIGN KEY (manager_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_status_events_request
+    ON public.request_status_events(request_id, changed_at);
+
+COMMENT ON TABLE public.request_status_events IS 'Хронология изменений статусов заявок.';
+COMMENT ON COLUMN public.request_status_events.old_status IS 'Статус до изменения (NULL для события создания заявки)';
+COMMENT ON COLUMN public.request_status_events.manager_id IS 'Менеджер, изменивший статус (NULL, если изменение выполнено партнером или системой)';
+
+-- Переносим то, что известно о существующих заявках:
+-- событие создания и, если статус уже менялся, последнее решение менеджера.
+INSERT INTO public.request_status_events (request_id, old_status, new_status, comment, changed_at)
+SELECT id, NULL, 'На рассмотрении', NULL, created_at
+FROM public.requests;
+
+INSERT INTO public.request_status_events (request_id, old_status, new_status, comment, changed_at)
+SELECT id, 'На рассмотрении', status, manager_comment, updated_at
+FROM public.requests
+WHERE status <> 'На рассмотрении';
@@ -0,0 +1,2 @@
+-- Нормализация данных необратима: исходные устаревшие значения не сохранялись.
+-- This migration is intentionally left empty.
@@ -0,0 +1,16 @@
+-- Приводим устаревшие значения статусов (добавленные в 000006) к каноническим.
+-- Сами значения остаются в request_status_enum: удаление значений ENUM в PostgreSQL не поддерживается.
+UPDATE public.requests SET status = 'Требует уточнения' WHERE status = 'На уточнении';
+UPDATE public.requests SET status = 'Одобрено' WHERE status = 'Одобрена';
+UPDATE public.requests SET status = 'Отклонено' WHERE status = 'Отклонена';
+UPDATE public.requests SET status = 'Выполнено' WHERE status = 'Завершена';
+
+UPDATE public.request_status_events SET new_status = 'Требует уточнения' WHERE new_status = 'На уточнении';
+UPDATE public.request_status_events SET new_status = 'Одобрено' WHERE new_status = 'Одобрена';
+UPDATE public.request_status_events SET new_status = 'Отклонено' WHERE new_status = 'Отклонена';
+UPDATE public.request_status_events SET new_status = 'Выполнено' WHERE new_status = 'Завершена';
+
+UPDATE public.request_status_events SET old_status = 'Требует уточнения' WHERE old_status = 'На уточнении';
+UPDATE public.request_status_events SET old_status = 'Одобрено' WHERE old_status = 'Одобрена';
+UPDATE public.request_status_events SET old_status = 'Отклонено' WHERE old_status = 'Отклонена';
+UPDATE public.request_status_events SET old_status = 'Выполнено' WHERE old_status = 'Завершена';
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_requests_partner_user_active;
+
+ALTER TABLE public.requests
+DROP COLUMN IF EXISTS withdrawn_at,
+DROP COLUMN IF EXISTS withdrawal_reason;
@@ -0,0 +1,11 @@
+-- Отзыв заявки партнером (мягкое удаление с возможностью восстановления)
+ALTER TABLE public.requests
+ADD COLUMN withdrawn_at timestamp with time zone,
+ADD COLUMN withdrawal_reason text;
+
+COMMENT ON COLUMN public.requests.withdrawn_at IS 'Момент отзыва заявки партнером (NULL — заявка активна)';
+COMMENT ON COLUMN public.requests.withdrawal_reason IS 'Причина отзыва, указанная партнером';
+
+CREATE INDEX IF NOT EXISTS idx_requests_partner_user_active
+    ON public.requests(partner_user_id, created_at DESC)
+    WHERE withdrawn_at IS NULL;
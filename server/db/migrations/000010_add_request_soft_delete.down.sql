@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_requests_deleted_at;
+
+ALTER TABLE IF EXISTS public.requests DROP CONSTRAINT IF EXISTS requests_deleted_by_fkey;
+
+ALTER TABLE public.requests
+DROP COLUMN IF EXISTS deleted_at,
+DROP COLUMN IF EXISTS deleted_by;
@@ -0,0 +1,18 @@
+-- Мягкое удаление заявок менеджером вместо каскадного DELETE
+ALTER TABLE public.requests
+ADD COLUMN deleted_at timestamp with time zone,
+ADD COLUMN deleted_by integer;
+
+ALTER TABLE IF EXISTS public.requests
+    ADD CONSTRAINT requests_deleted_by_fkey FOREIGN KEY (deleted_by)
+    REFERENCES public.users (id) MATCH SIMPLE
+    ON UPDATE NO ACTION
+    ON DELETE SET NULL;
+
+COMMENT ON COLUMN public.requests.deleted_at IS 'Момент удаления заявки в корзину (NULL — заявка не удалена)';
+COMMENT ON COLUMN public.requests.deleted_by IS 'Менеджер, удаливший заявку';
+
+-- Корзина и фоновая очистка выбирают только удаленные заявки
+CREATE INDEX IF NOT EXISTS idx_requests_deleted_at
+    ON public.requests(deleted_at)
+    WHERE deleted_at IS NOT NULL;
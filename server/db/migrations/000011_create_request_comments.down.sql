@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.request_comment_reads;
+DROP TABLE IF EXISTS public.request_comment_files;
+DROP TABLE IF EXISTS public.request_comments;
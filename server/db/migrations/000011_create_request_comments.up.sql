@@ -0,0 +1,54 @@
+-- Переписка по заявке между партнером и менеджером
+CREATE TABLE IF NOT EXISTS public.request_comments
+(
+    id serial NOT NULL,
+    request_id integer NOT NULL,
+    author_id integer,
+    body text COLLATE pg_catalog."default" NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT request_comments_pkey PRIMARY KEY (id),
+    CONSTRAINT request_comments_request_id_fkey FOREIGN KEY (request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_comments_author_id_fkey FOREIGN KEY (author_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_comments_request_created
+    ON public.request_comments(request_id, created_at);
+
+-- Вложения к сообщениям
+CREATE TABLE IF NOT EXISTS public.request_comment_files
+(
+    comment_id integer NOT NULL,
+    file_id integer NOT NULL,
+    CONSTRAINT request_comment_files_pkey PRIMARY KEY (comment_id, file_id),
+    CONSTRAINT request_comment_files_comment_id_fkey FOREIGN KEY (comment_id)
+        REFERENCES public.request_comments (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_comment_files_file_id_fkey FOREIGN KEY (file_id)
+        REFERENCES public.files (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+-- Момент, до которого пользователь прочитал переписку по заявке (для счетчика непрочитанных)
+CREATE TABLE IF NOT EXISTS public.request_comment_reads
+(
+    request_id integer NOT NULL,
+    user_id integer NOT NULL,
+    last_read_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT request_comment_reads_pkey PRIMARY KEY (request_id, user_id),
+    CONSTRAINT request_comment_reads_request_id_fkey FOREIGN KEY (request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_comment_reads_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
@@ -0,0 +1,43 @@
+-- Журнал аудита действий пользователей через API (только добавление записей)
+CREATE TABLE IF NOT EXISTS public.audit_events
+(
+    id bigserial NOT NULL,
+    occurred_at timestamp with time zone NOT NULL DEFAULT now(),
+    actor_id integer,
+    actor_role character varying(20) COLLATE pg_catalog."default",
+    action character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    method character varying(10) COLLATE pg_catalog."default" NOT NULL,
+    path text COLLATE pg_catalog."default" NOT NULL,
+    entity_type character varying(50) COLLATE pg_catalog."default",
+    entity_id character varying(50) COLLATE pg_catalog."default",
+    ip character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    status_code integer NOT NULL,
+    before jsonb,
+    after jsonb,
+    CONSTRAINT audit_events_pkey PRIMARY KEY (id)
+);
+
+-- Без внешнего ключа на users: запись журнала должна пережить удаление пользователя
+COMMENT ON COLUMN public.audit_events.actor_id IS 'ID пользователя, выполнившего действие (NULL — без аутентификации)';
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_occurred_at ON public.audit_events(occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON public.audit_events(actor_id, occurred_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_entity ON public.audit_events(entity_type, entity_id);
+
+-- Запрещаем изменение и удаление записей журнала
+CREATE OR REPLACE FUNCTION public.audit_events_append_only()
+RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_no_update ON public.audit_events;
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE OR DELETE ON public.audit_events
+    FOR EACH ROW EXECUTE FUNCTION public.audit_events_append_only();
+
+DROP TRIGGER IF EXISTS audit_events_no_truncate ON public.audit_events;
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON public.audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION public.audit_events_append_only();
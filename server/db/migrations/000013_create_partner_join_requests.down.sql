@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.partner_join_requests;
@@ -0,0 +1,37 @@
+-- Заявки на присоединение зарегистрировавшихся пользователей к организации-партнеру
+CREATE TABLE IF NOT EXISTS public.partner_join_requests
+(
+    id serial NOT NULL,
+    user_id integer NOT NULL,
+    partner_inn character varying(12) COLLATE pg_catalog."default" NOT NULL,
+    partner_name character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    -- Партнер, найденный по ИНН при регистрации или выбранный при одобрении
+    partner_id integer,
+    status character varying(20) COLLATE pg_catalog."default" NOT NULL DEFAULT 'PENDING',
+    reviewer_id integer,
+    review_comment text COLLATE pg_catalog."default",
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    reviewed_at timestamp with time zone,
+    CONSTRAINT partner_join_requests_pkey PRIMARY KEY (id),
+    CONSTRAINT partner_join_requests_status_check CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED')),
+    CONSTRAINT partner_join_requests_user_id_fkey FOREIGN KEY (user_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT partner_join_requests_partner_id_fkey FOREIGN KEY (partner_id)
+        REFERENCES public.partners (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL,
+    CONSTRAINT partner_join_requests_reviewer_id_fkey FOREIGN KEY (reviewer_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL
+);
+
+-- У пользователя может быть только одна нерассмотренная заявка
+CREATE UNIQUE INDEX IF NOT EXISTS idx_partner_join_requests_pending_user
+    ON public.partner_join_requests(user_id)
+    WHERE status = 'PENDING';
+
+CREATE INDEX IF NOT EXISTS idx_partner_join_requests_status_created
+    ON public.partner_join_requests(status, created_at);
@@ -0,0 +1,3 @@
+ALTER TABLE public.partners DROP CONSTRAINT IF EXISTS partners_partner_status_check;
+ALTER TABLE public.partners ALTER COLUMN partner_status DROP DEFAULT;
+ALTER TABLE public.partners DROP COLUMN IF EXISTS is_distributor;
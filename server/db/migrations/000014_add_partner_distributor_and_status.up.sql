@@ -0,0 +1,18 @@
+-- Признак дистрибьютора для выбора distributor_id в заявке
+ALTER TABLE public.partners
+    ADD COLUMN IF NOT EXISTS is_distributor boolean NOT NULL DEFAULT false;
+
+-- Партнеры, уже указанные дистрибьюторами в заявках
+UPDATE public.partners p
+SET is_distributor = true
+WHERE EXISTS (SELECT 1 FROM public.requests r WHERE r.distributor_id = p.id);
+
+-- Статус партнера: active, pending, inactive
+UPDATE public.partners SET partner_status = 'active' WHERE partner_status IS NULL;
+
+ALTER TABLE public.partners
+    ALTER COLUMN partner_status SET DEFAULT 'active';
+
+ALTER TABLE public.partners
+    ADD CONSTRAINT partners_partner_status_check
+    CHECK (partner_status IN ('active', 'pending', 'inactive')) NOT VALID;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS public.idx_end_clients_merged_into_id;
+ALTER TABLE public.end_clients
+    DROP CONSTRAINT IF EXISTS end_clients_merged_into_id_fkey,
+    DROP COLUMN IF EXISTS merged_at,
+    DROP COLUMN IF EXISTS merged_into_id;
+DROP INDEX IF EXISTS public.idx_end_clients_city_trgm;
+DROP INDEX IF EXISTS public.idx_end_clients_name_trgm;
+-- Расширение pg_trgm не удаляем: им могут пользоваться другие объекты
@@ -0,0 +1,22 @@
+-- Нечеткий поиск конечных клиентов по названию и городу
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_end_clients_name_trgm
+    ON public.end_clients USING gin (name gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_end_clients_city_trgm
+    ON public.end_clients USING gin (city gin_trgm_ops);
+
+-- Объединенный дубликат остается записью-указателем на основного клиента,
+-- чтобы поиск по его ИНН находил основного, а не создавал новый дубликат
+ALTER TABLE public.end_clients
+    ADD COLUMN IF NOT EXISTS merged_into_id integer,
+    ADD COLUMN IF NOT EXISTS merged_at timestamp with time zone,
+    ADD CONSTRAINT end_clients_merged_into_id_fkey FOREIGN KEY (merged_into_id)
+        REFERENCES public.end_clients (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_end_clients_merged_into_id
+    ON public.end_clients(merged_into_id)
+    WHERE merged_into_id IS NOT NULL;
@@ -0,0 +1,8 @@
+ALTER TABLE public.end_clients
+    DROP COLUMN IF EXISTS org_type,
+    DROP COLUMN IF EXISTS ogrn,
+    DROP COLUMN IF EXISTS kpp;
+ALTER TABLE public.partners
+    DROP COLUMN IF EXISTS org_type,
+    DROP COLUMN IF EXISTS ogrn,
+    DROP COLUMN IF EXISTS kpp;
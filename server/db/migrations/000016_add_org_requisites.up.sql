@@ -0,0 +1,20 @@
+-- КПП и ОГРН (ОГРНИП) организаций. Необязательны; формат и контрольное число проверяет сервер
+ALTER TABLE public.partners
+    ADD COLUMN IF NOT EXISTS kpp character varying(9),
+    ADD COLUMN IF NOT EXISTS ogrn character varying(15),
+    ADD COLUMN IF NOT EXISTS org_type text GENERATED ALWAYS AS (
+        CASE length(inn)
+            WHEN 10 THEN 'legal_entity'
+            WHEN 12 THEN 'individual_entrepreneur'
+        END
+    ) STORED;
+
+ALTER TABLE public.end_clients
+    ADD COLUMN IF NOT EXISTS kpp character varying(9),
+    ADD COLUMN IF NOT EXISTS ogrn character varying(15),
+    ADD COLUMN IF NOT EXISTS org_type text GENERATED ALWAYS AS (
+        CASE length(inn)
+            WHEN 10 THEN 'legal_entity'
+            WHEN 12 THEN 'individual_entrepreneur'
+        END
+    ) STORED;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.idx_request_conflicts_conflicting_request;
+DROP TABLE IF EXISTS public.request_conflicts;
+ALTER TABLE public.requests DROP COLUMN IF EXISTS is_conflicting;
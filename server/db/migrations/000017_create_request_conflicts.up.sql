@@ -0,0 +1,26 @@
+-- Пересечения регистраций сделок: более поздняя заявка другого партнера на того же конечного клиента
+-- с похожим проектом. Первым зарегистрировавший сделку партнер защищен, поздняя заявка помечается.
+ALTER TABLE public.requests
+    ADD COLUMN IF NOT EXISTS is_conflicting boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS public.request_conflicts
+(
+    request_id integer NOT NULL,
+    conflicting_request_id integer NOT NULL,
+    reasons text[] NOT NULL,
+    name_similarity real,
+    detected_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT request_conflicts_pkey PRIMARY KEY (request_id, conflicting_request_id),
+    CONSTRAINT request_conflicts_request_id_fkey FOREIGN KEY (request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_conflicts_conflicting_request_id_fkey FOREIGN KEY (conflicting_request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_conflicts_distinct_check CHECK (request_id <> conflicting_request_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_request_conflicts_conflicting_request
+    ON public.request_conflicts(conflicting_request_id);
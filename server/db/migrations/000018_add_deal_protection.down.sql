@@ -0,0 +1,7 @@
+-- Значение 'Истекло' остается в request_status_enum: удаление значений ENUM требует пересоздания типа.
+DROP INDEX IF EXISTS public.idx_request_extensions_pending_request;
+DROP TABLE IF EXISTS public.request_extensions;
+DROP INDEX IF EXISTS public.idx_requests_protected_until;
+ALTER TABLE public.requests
+    DROP COLUMN IF EXISTS expired_at,
+    DROP COLUMN IF EXISTS protected_until;
@@ -0,0 +1,52 @@
+-- Защита одобренной регистрации сделки: до protected_until (и не дольше ожидаемой даты закрытия)
+-- сделка закреплена за партнером, затем фоновая задача переводит заявку в статус "Истекло".
+ALTER TYPE request_status_enum ADD VALUE IF NOT EXISTS 'Истекло';
+
+ALTER TABLE public.requests
+    ADD COLUMN IF NOT EXISTS protected_until date,
+    ADD COLUMN IF NOT EXISTS expired_at timestamp with time zone;
+
+-- Уже одобренные заявки получают стандартный срок защиты (90 дней) от последнего изменения
+UPDATE public.requests
+SET protected_until = (updated_at + interval '90 days')::date
+WHERE status = 'Одобрено' AND protected_until IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_requests_protected_until
+    ON public.requests(protected_until)
+    WHERE protected_until IS NOT NULL;
+
+-- Запросы партнеров на продление защиты, рассматриваемые менеджером
+CREATE TABLE IF NOT EXISTS public.request_extensions
+(
+    id serial NOT NULL,
+    request_id integer NOT NULL,
+    requested_by integer,
+    requested_until date NOT NULL,
+    reason text COLLATE pg_catalog."default" NOT NULL,
+    status character varying(20) COLLATE pg_catalog."default" NOT NULL DEFAULT 'PENDING',
+    -- Дата, до которой менеджер продлил защиту (может отличаться от запрошенной)
+    approved_until date,
+    reviewer_id integer,
+    review_comment text COLLATE pg_catalog."default",
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    reviewed_at timestamp with time zone,
+    CONSTRAINT request_extensions_pkey PRIMARY KEY (id),
+    CONSTRAINT request_extensions_status_check CHECK (status IN ('PENDING', 'APPROVED', 'REJECTED')),
+    CONSTRAINT request_extensions_request_id_fkey FOREIGN KEY (request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT request_extensions_requested_by_fkey FOREIGN KEY (requested_by)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL,
+    CONSTRAINT request_extensions_reviewer_id_fkey FOREIGN KEY (reviewer_id)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL
+);
+
+-- По заявке может быть только один нерассмотренный запрос на продление
+CREATE UNIQUE INDEX IF NOT EXISTS idx_request_extensions_pending_request
+    ON public.request_extensions(request_id)
+    WHERE status = 'PENDING';
@@ -0,0 +1,11 @@
+ALTER TABLE public.requests
+    ADD COLUMN IF NOT EXISTS quantity integer,
+    ADD COLUMN IF NOT EXISTS unit_price numeric(12, 2);
+
+-- Возвращаем в заявку первую позицию; остальные позиции теряются
+UPDATE public.requests r
+SET quantity = i.quantity, unit_price = i.unit_price
+FROM public.request_items i
+WHERE i.request_id = r.id AND i."position" = 1;
+
+DROP TABLE IF EXISTS public.request_items;
@@ -0,0 +1,37 @@
+-- Товарные позиции заявки. В requests остается total_price — сумма итогов позиций,
+-- которую рассчитывает сервер (по ней сравниваются сделки при поиске пересечений).
+CREATE TABLE IF NOT EXISTS public.request_items
+(
+    id serial NOT NULL,
+    request_id integer NOT NULL,
+    "position" integer NOT NULL,
+    product_name character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    sku character varying(100) COLLATE pg_catalog."default",
+    quantity integer NOT NULL,
+    unit_price numeric(12, 2) NOT NULL,
+    discount_percent numeric(5, 2) NOT NULL DEFAULT 0,
+    line_total numeric(14, 2) NOT NULL,
+    CONSTRAINT request_items_pkey PRIMARY KEY (id),
+    CONSTRAINT request_items_request_position_key UNIQUE (request_id, "position"),
+    CONSTRAINT request_items_quantity_check CHECK (quantity > 0),
+    CONSTRAINT request_items_unit_price_check CHECK (unit_price >= 0),
+    CONSTRAINT request_items_discount_check CHECK (discount_percent >= 0 AND discount_percent <= 100),
+    CONSTRAINT request_items_request_id_fkey FOREIGN KEY (request_id)
+        REFERENCES public.requests (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE
+);
+
+-- Переносим единственную позицию из полей заявки; название товара берем из названия проекта
+INSERT INTO public.request_items (request_id, "position", product_name, quantity, unit_price, line_total)
+SELECT r.id, 1,
+       COALESCE(left(NULLIF(btrim(r.project_name), ''), 255), 'Позиция 1'),
+       r.quantity, r.unit_price,
+       COALESCE(r.total_price, r.quantity * r.unit_price)
+FROM public.requests r
+WHERE r.quantity > 0 AND r.unit_price >= 0
+  AND NOT EXISTS (SELECT 1 FROM public.request_items i WHERE i.request_id = r.id);
+
+ALTER TABLE public.requests
+    DROP COLUMN IF EXISTS quantity,
+    DROP COLUMN IF EXISTS unit_price;
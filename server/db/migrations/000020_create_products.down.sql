@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS public.idx_request_items_product;
+ALTER TABLE public.request_items
+    DROP CONSTRAINT IF EXISTS request_items_product_id_fkey,
+    DROP COLUMN IF EXISTS list_currency,
+    DROP COLUMN IF EXISTS list_price,
+    DROP COLUMN IF EXISTS product_id;
+DROP TABLE IF EXISTS public.products;
@@ -0,0 +1,35 @@
+-- Каталог продуктов (прайс-лист). Позиции заявок ссылаются на продукт по SKU
+-- и сохраняют прайсовую цену на момент регистрации, чтобы была видна глубина скидки партнера.
+CREATE TABLE IF NOT EXISTS public.products
+(
+    id serial NOT NULL,
+    sku character varying(100) COLLATE pg_catalog."default" NOT NULL,
+    name character varying(255) COLLATE pg_catalog."default" NOT NULL,
+    category character varying(100) COLLATE pg_catalog."default",
+    list_price numeric(12, 2) NOT NULL,
+    currency character(3) COLLATE pg_catalog."default" NOT NULL DEFAULT 'RUB',
+    is_active boolean NOT NULL DEFAULT true,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT products_pkey PRIMARY KEY (id),
+    CONSTRAINT products_sku_key UNIQUE (sku),
+    CONSTRAINT products_list_price_check CHECK (list_price >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_products_name_trgm
+    ON public.products USING gin (lower(name) gin_trgm_ops);
+
+CREATE INDEX IF NOT EXISTS idx_products_category
+    ON public.products(category);
+
+ALTER TABLE public.request_items
+    ADD COLUMN IF NOT EXISTS product_id integer,
+    ADD COLUMN IF NOT EXISTS list_price numeric(12, 2),
+    ADD COLUMN IF NOT EXISTS list_currency character(3) COLLATE pg_catalog."default",
+    ADD CONSTRAINT request_items_product_id_fkey FOREIGN KEY (product_id)
+        REFERENCES public.products (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_request_items_product
+    ON public.request_items(product_id);
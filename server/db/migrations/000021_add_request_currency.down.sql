@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS public.exchange_rates;
+ALTER TABLE public.requests
+    DROP CONSTRAINT IF EXISTS requests_exchange_rate_check,
+    DROP COLUMN IF EXISTS total_price_base,
+    DROP COLUMN IF EXISTS exchange_rate,
+    DROP COLUMN IF EXISTS currency;
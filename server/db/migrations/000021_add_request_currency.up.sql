@@ -0,0 +1,29 @@
+-- Валюта сумм заявки. Цены позиций и total_price указываются в валюте заявки; total_price_base —
+-- та же сумма в рублях по курсу на момент сохранения, по ней строятся списки и агрегаты.
+ALTER TABLE public.requests
+    ADD COLUMN IF NOT EXISTS currency character(3) COLLATE pg_catalog."default" NOT NULL DEFAULT 'RUB',
+    ADD COLUMN IF NOT EXISTS exchange_rate numeric(12, 6) NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS total_price_base numeric(20, 2),
+    ADD CONSTRAINT requests_exchange_rate_check CHECK (exchange_rate > 0);
+
+-- Все существующие суммы в рублях
+UPDATE public.requests SET total_price_base = total_price WHERE total_price_base IS NULL;
+
+-- Курсы валют к рублю, которые ведут менеджеры. Для RUB курс всегда 1 и не хранится.
+CREATE TABLE IF NOT EXISTS public.exchange_rates
+(
+    id serial NOT NULL,
+    currency character(3) COLLATE pg_catalog."default" NOT NULL,
+    -- Рублей за единицу валюты
+    rate numeric(12, 6) NOT NULL,
+    updated_by integer,
+    updated_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT exchange_rates_pkey PRIMARY KEY (id),
+    CONSTRAINT exchange_rates_currency_key UNIQUE (currency),
+    CONSTRAINT exchange_rates_rate_check CHECK (rate > 0),
+    CONSTRAINT exchange_rates_not_base_check CHECK (currency <> 'RUB'),
+    CONSTRAINT exchange_rates_updated_by_fkey FOREIGN KEY (updated_by)
+        REFERENCES public.users (id) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE SET NULL
+);
@@ -0,0 +1,7 @@
+-- Откат возможен, только пока все файлы хранятся в file_data: перенесенные во внешнее
+-- хранилище нужно сначала вернуть в базу, иначе SET NOT NULL завершится ошибкой.
+ALTER TABLE public.files
+    DROP CONSTRAINT IF EXISTS files_content_check,
+    DROP CONSTRAINT IF EXISTS files_storage_key_key,
+    DROP COLUMN IF EXISTS storage_key,
+    ALTER COLUMN file_data SET NOT NULL;
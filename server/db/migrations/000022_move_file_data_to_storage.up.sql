@@ -0,0 +1,8 @@
+-- Содержимое файлов переезжает из file_data во внешнее хранилище (каталог или S3), в таблице
+-- остается ключ объекта. Старые строки продолжают читаться из file_data, пока их не перенесет
+-- утилита cmd/migrate-files; после переноса столбец file_data удаляет миграция 000024.
+ALTER TABLE public.files
+    ADD COLUMN IF NOT EXISTS storage_key text COLLATE pg_catalog."default",
+    ALTER COLUMN file_data DROP NOT NULL,
+    ADD CONSTRAINT files_storage_key_key UNIQUE (storage_key),
+    ADD CONSTRAINT files_content_check CHECK (storage_key IS NOT NULL OR file_data IS NOT NULL);
@@ -0,0 +1 @@
+ALTER TABLE public.files DROP COLUMN IF EXISTS sha256;
@@ -0,0 +1,3 @@
+-- SHA-256 содержимого файла (hex), вычисляется при загрузке. У файлов, загруженных раньше, не заполнен.
+ALTER TABLE public.files
+    ADD COLUMN IF NOT EXISTS sha256 character(64) COLLATE pg_catalog."default";
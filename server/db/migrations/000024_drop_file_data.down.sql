@@ -0,0 +1,5 @@
+-- Возвращает столбец file_data пустым: содержимое файлов остается во внешнем хранилище.
+ALTER TABLE public.files
+    ADD COLUMN IF NOT EXISTS file_data bytea,
+    ALTER COLUMN storage_key DROP NOT NULL,
+    ADD CONSTRAINT files_content_check CHECK (storage_key IS NOT NULL OR file_data IS NOT NULL);
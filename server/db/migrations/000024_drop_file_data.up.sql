@@ -0,0 +1,17 @@
+-- Удаляет столбец file_data после переноса содержимого всех файлов во внешнее хранилище.
+-- Перед применением нужно перенести файлы утилитой cmd/migrate-files, иначе миграция
+-- завершится ошибкой и ничего не изменит.
+DO $$
+DECLARE
+    pending bigint;
+BEGIN
+    SELECT COUNT(*) INTO pending FROM public.files WHERE storage_key IS NULL;
+    IF pending > 0 THEN
+        RAISE EXCEPTION 'files.file_data still holds % file(s); run cmd/migrate-files first', pending;
+    END IF;
+END $$;
+
+ALTER TABLE public.files
+    DROP CONSTRAINT IF EXISTS files_content_check,
+    DROP COLUMN IF EXISTS file_data,
+    ALTER COLUMN storage_key SET NOT NULL;
@@ -3,7 +3,9 @@ package db
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/eeephemera/zvk-requests/server/audit"
 	"github.com/eeephemera/zvk-requests/server/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -19,69 +21,180 @@ func NewPartnerRepository(pool *pgxpool.Pool) *PartnerRepository {
 	return &PartnerRepository{pool: pool}
 }
 
+const partnerColumns = `id, name, address, inn, kpp, ogrn, org_type, partner_status, is_distributor, assigned_manager_id, created_at, updated_at`
+
+func scanPartner(row pgx.Row, partner *models.Partner) error {
+	return row.Scan(
+		&partner.ID, &partner.Name, &partner.Address, &partner.INN, &partner.KPP, &partner.OGRN, &partner.OrgType,
+		&partner.PartnerStatus, &partner.IsDistributor, &partner.AssignedManagerID, &partner.CreatedAt, &partner.UpdatedAt,
+	)
+}
+
+// PartnerFilter — условия выборки партнеров. Пустые поля не фильтруют.
+type PartnerFilter struct {
+	Search           string // Подстрока названия или начало ИНН
+	Status           string
+	DistributorsOnly bool
+}
+
 // CreatePartner вставляет нового партнера в таблицу partners.
 func (repo *PartnerRepository) CreatePartner(ctx context.Context, partner *models.Partner) error {
 	query := `
         INSERT INTO partners (
-            name, address, inn, partner_status, assigned_manager_id
-        ) VALUES ($1, $2, $3, $4, $5)
-        RETURNING id, created_at, updated_at
+            name, address, inn, kpp, ogrn, partner_status, is_distributor, assigned_manager_id
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, org_type, created_at, updated_at
     `
 	return repo.pool.QueryRow(ctx, query,
-		partner.Name, partner.Address, partner.INN, partner.PartnerStatus, partner.AssignedManagerID,
-	).Scan(&partner.ID, &partner.CreatedAt, &partner.UpdatedAt)
+		partner.Name, partner.Address, partner.INN, partner.KPP, partner.OGRN,
+		partner.PartnerStatus, partner.IsDistributor, partner.AssignedManagerID,
+	).Scan(&partner.ID, &partner.OrgType, &partner.CreatedAt, &partner.UpdatedAt)
 }
 
 // GetPartnerByID возвращает партнера по его ID.
 func (repo *PartnerRepository) GetPartnerByID(ctx context.Context, id int) (*models.Partner, error) {
-	query := `
-        SELECT id, name, address, inn, partner_status, assigned_manager_id, created_at, updated_at
-        FROM partners
-        WHERE id = $1
-    `
+	query := `SELECT ` + partnerColumns + ` FROM partners WHERE id = $1`
 	var partner models.Partner
-	err := repo.pool.QueryRow(ctx, query, id).Scan(
-		&partner.ID, &partner.Name, &partner.Address, &partner.INN,
-		&partner.PartnerStatus, &partner.AssignedManagerID, &partner.CreatedAt, &partner.UpdatedAt,
-	)
-	if err != nil {
+	if err := scanPartner(repo.pool.QueryRow(ctx, query, id), &partner); err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("partner not found: id=%d", id)
+			return nil, fmt.Errorf("%w: partner id=%d", ErrNotFound, id)
 		}
 		return nil, fmt.Errorf("failed to fetch partner: %w", err)
 	}
 	return &partner, nil
 }
 
-// GetAllPartners возвращает список всех партнеров.
-func (repo *PartnerRepository) GetAllPartners(ctx context.Context) ([]*models.Partner, error) {
-	query := `
-        SELECT id, name, address, inn, partner_status, assigned_manager_id, created_at, updated_at
+// likeEscaper экранирует спецсимволы шаблона LIKE, чтобы введенные пользователем % и _ искались как есть.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+// ListPartners возвращает партнеров по фильтру, отсортированных по названию, и их общее количество.
+func (repo *PartnerRepository) ListPartners(ctx context.Context, filter PartnerFilter, limit, offset int) ([]models.Partner, int64, error) {
+	whereClauses := []string{}
+	args := []interface{}{}
+	if filter.Search != "" {
+		search := escapeLike(filter.Search)
+		args = append(args, "%"+search+"%", search+"%")
+		whereClauses = append(whereClauses, fmt.Sprintf(`(name ILIKE $%d ESCAPE '\' OR inn LIKE $%d ESCAPE '\')`, len(args)-1, len(args)))
+	}
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		whereClauses = append(whereClauses, fmt.Sprintf("partner_status = $%d", len(args)))
+	}
+	if filter.DistributorsOnly {
+		whereClauses = append(whereClauses, "is_distributor")
+	}
+	whereQuery := ""
+	if len(whereClauses) > 0 {
+		whereQuery = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	var total int64
+	if err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) FROM partners "+whereQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count partners: %w", err)
+	}
+	if total == 0 {
+		return []models.Partner{}, 0, nil
+	}
+
+	query := fmt.Sprintf(`
+        SELECT %s
         FROM partners
-        ORDER BY name ASC
-    `
-	rows, err := repo.pool.Query(ctx, query)
+        %s
+        ORDER BY name ASC, id ASC
+        LIMIT $%d OFFSET $%d
+    `, partnerColumns, whereQuery, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := repo.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query partners: %w", err)
+		return nil, 0, fmt.Errorf("failed to query partners: %w", err)
 	}
 	defer rows.Close()
 
-	var partners []*models.Partner
+	partners := make([]models.Partner, 0, limit)
 	for rows.Next() {
 		var partner models.Partner
-		err := rows.Scan(
-			&partner.ID, &partner.Name, &partner.Address, &partner.INN,
-			&partner.PartnerStatus, &partner.AssignedManagerID, &partner.CreatedAt, &partner.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan partner row: %w", err)
+		if err := scanPartner(rows, &partner); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan partner row: %w", err)
 		}
-		partners = append(partners, &partner)
+		partners = append(partners, partner)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating partner rows: %w", err)
+		return nil, 0, fmt.Errorf("error iterating partner rows: %w", err)
 	}
-	return partners, nil
+	return partners, total, nil
 }
 
-// Другие методы по аналогии с RequestRepository...
+// UpdatePartner обновляет реквизиты, статус, признак дистрибьютора и ответственного менеджера партнера.
+func (repo *PartnerRepository) UpdatePartner(ctx context.Context, partner *models.Partner) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before := partnerSnapshot(ctx, tx, partner.ID)
+	query := `
+        UPDATE partners
+        SET name = $1, address = $2, inn = $3, kpp = $4, ogrn = $5, partner_status = $6, is_distributor = $7,
+            assigned_manager_id = $8, updated_at = NOW()
+        WHERE id = $9
+        RETURNING org_type, created_at, updated_at
+    `
+	err = tx.QueryRow(ctx, query,
+		partner.Name, partner.Address, partner.INN, partner.KPP, partner.OGRN, partner.PartnerStatus, partner.IsDistributor,
+		partner.AssignedManagerID, partner.ID,
+	).Scan(&partner.OrgType, &partner.CreatedAt, &partner.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update partner: %w", err)
+	}
+
+	after := partnerSnapshot(ctx, tx, partner.ID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "partner", partner.ID, before, after)
+	return nil
+}
+
+// DeletePartner удаляет партнера, у которого нет заявок (в том числе в роли дистрибьютора).
+// Пользователи партнера остаются без привязки.
+func (repo *PartnerRepository) DeletePartner(ctx context.Context, id int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var hasRequests bool
+	err = tx.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM requests WHERE partner_id = $1 OR distributor_id = $1)", id,
+	).Scan(&hasRequests)
+	if err != nil {
+		return fmt.Errorf("failed to check partner requests: %w", err)
+	}
+	if hasRequests {
+		return fmt.Errorf("%w: partner has requests", ErrInvalidState)
+	}
+
+	before := partnerSnapshot(ctx, tx, id)
+	tag, err := tx.Exec(ctx, "DELETE FROM partners WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete partner: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "partner", id, before, nil)
+	return nil
+}
@@ -0,0 +1,290 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ProductRepository предоставляет методы для работы с каталогом продуктов (таблица products).
+type ProductRepository struct {
+	pool *pgxpool.Pool
+}
+
+// NewProductRepository создаёт новый ProductRepository.
+func NewProductRepository(pool *pgxpool.Pool) *ProductRepository {
+	return &ProductRepository{pool: pool}
+}
+
+const productColumns = `id, sku, name, category, list_price, currency, is_active, created_at, updated_at`
+
+func scanProduct(row pgx.Row, product *models.Product) error {
+	return row.Scan(
+		&product.ID, &product.SKU, &product.Name, &product.Category, &product.ListPrice, &product.Currency,
+		&product.IsActive, &product.CreatedAt, &product.UpdatedAt,
+	)
+}
+
+// ProductFilter — условия выборки продуктов. Пустые поля не фильтруют.
+type ProductFilter struct {
+	Search   string // Подстрока названия или начало SKU
+	Category string
+	Active   *bool
+}
+
+// CreateProduct добавляет продукт в каталог.
+func (repo *ProductRepository) CreateProduct(ctx context.Context, product *models.Product) error {
+	query := `
+        INSERT INTO products (sku, name, category, list_price, currency, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, created_at, updated_at
+    `
+	return repo.pool.QueryRow(ctx, query,
+		product.SKU, product.Name, product.Category, product.ListPrice, product.Currency, product.IsActive,
+	).Scan(&product.ID, &product.CreatedAt, &product.UpdatedAt)
+}
+
+// GetProductByID возвращает продукт по его ID.
+func (repo *ProductRepository) GetProductByID(ctx context.Context, id int) (*models.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
+	var product models.Product
+	if err := scanProduct(repo.pool.QueryRow(ctx, query, id), &product); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: product id=%d", ErrNotFound, id)
+		}
+		return nil, fmt.Errorf("failed to fetch product: %w", err)
+	}
+	return &product, nil
+}
+
+// ListProducts возвращает продукты по фильтру, отсортированные по названию, и их общее количество.
+func (repo *ProductRepository) ListProducts(ctx context.Context, filter ProductFilter, limit, offset int) ([]models.Product, int64, error) {
+	whereClauses := []string{}
+	args := []interface{}{}
+	if filter.Search != "" {
+		args = append(args, "%"+strings.ToLower(filter.Search)+"%", filter.Search+"%")
+		whereClauses = append(whereClauses, fmt.Sprintf("(lower(name) LIKE $%d OR sku ILIKE $%d)", len(args)-1, len(args)))
+	}
+	if filter.Category != "" {
+		args = append(args, filter.Category)
+		whereClauses = append(whereClauses, fmt.Sprintf("category = $%d", len(args)))
+	}
+	if filter.Active != nil {
+		args = append(args, *filter.Active)
+		whereClauses = append(whereClauses, fmt.Sprintf("is_active = $%d", len(args)))
+	}
+	whereQuery := ""
+	if len(whereClauses) > 0 {
+		whereQuery = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	var total int64
+	if err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) FROM products "+whereQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count products: %w", err)
+	}
+	if total == 0 {
+		return []models.Product{}, 0, nil
+	}
+
+	query := fmt.Sprintf(`
+        SELECT %s
+        FROM products
+        %s
+        ORDER BY name ASC, id ASC
+        LIMIT $%d OFFSET $%d
+    `, productColumns, whereQuery, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := repo.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query products: %w", err)
+	}
+	defer rows.Close()
+
+	products := make([]models.Product, 0, limit)
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan product row: %w", err)
+		}
+		products = append(products, product)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating product rows: %w", err)
+	}
+	return products, total, nil
+}
+
+// FindProductsBySKU возвращает продукты каталога с указанными SKU, ключ — SKU.
+// Отсутствующие в каталоге SKU в результат не попадают.
+func (repo *ProductRepository) FindProductsBySKU(ctx context.Context, skus []string) (map[string]models.Product, error) {
+	products := make(map[string]models.Product, len(skus))
+	if len(skus) == 0 {
+		return products, nil
+	}
+	rows, err := repo.pool.Query(ctx, `SELECT `+productColumns+` FROM products WHERE sku = ANY($1)`, skus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query products by sku: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var product models.Product
+		if err := scanProduct(rows, &product); err != nil {
+			return nil, fmt.Errorf("failed to scan product row: %w", err)
+		}
+		products[product.SKU] = product
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating product rows: %w", err)
+	}
+	return products, nil
+}
+
+// UpdateProduct обновляет все поля продукта. Позиции уже созданных заявок сохраняют
+// прайсовую цену на момент регистрации.
+func (repo *ProductRepository) UpdateProduct(ctx context.Context, product *models.Product) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before := productSnapshot(ctx, tx, product.ID)
+	query := `
+        UPDATE products
+        SET sku = $1, name = $2, category = $3, list_price = $4, currency = $5, is_active = $6, updated_at = NOW()
+        WHERE id = $7
+        RETURNING created_at, updated_at
+    `
+	err = tx.QueryRow(ctx, query,
+		product.SKU, product.Name, product.Category, product.ListPrice, product.Currency, product.IsActive, product.ID,
+	).Scan(&product.CreatedAt, &product.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to update product: %w", err)
+	}
+
+	after := productSnapshot(ctx, tx, product.ID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "product", product.ID, before, after)
+	return nil
+}
+
+// DeleteProduct удаляет продукт из каталога. Позиции заявок теряют ссылку на продукт,
+// но сохраняют SKU и прайсовую цену.
+func (repo *ProductRepository) DeleteProduct(ctx context.Context, id int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before := productSnapshot(ctx, tx, id)
+	tag, err := tx.Exec(ctx, "DELETE FROM products WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete product: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "product", id, before, nil)
+	return nil
+}
+
+// ImportProducts добавляет или обновляет (по SKU) продукты прайс-листа в одной транзакции
+// и возвращает число созданных и обновленных продуктов. Продукты, отсутствующие в файле, не меняются.
+// В журнал аудита попадает одна запись импорта: число созданных и обновленных продуктов и снимки
+// "до" и "после" тех продуктов, которые действительно изменились.
+func (repo *ProductRepository) ImportProducts(ctx context.Context, products []models.Product) (created, updated int, err error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	previous, err := productSnapshotsBySKU(ctx, tx, products)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// xmax = 0 только у вставленной, а не обновленной строки
+	query := `
+        INSERT INTO products (sku, name, category, list_price, currency, is_active)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        ON CONFLICT (sku) DO UPDATE
+        SET name = EXCLUDED.name, category = EXCLUDED.category, list_price = EXCLUDED.list_price,
+            currency = EXCLUDED.currency, is_active = EXCLUDED.is_active, updated_at = NOW()
+        RETURNING (xmax = 0), to_jsonb(products.*)
+    `
+	changedBefore, changedAfter := map[string]any{}, map[string]any{}
+	for _, p := range products {
+		var inserted bool
+		var snapshot map[string]any
+		if err := tx.QueryRow(ctx, query, p.SKU, p.Name, p.Category, p.ListPrice, p.Currency, p.IsActive).Scan(&inserted, &snapshot); err != nil {
+			return 0, 0, fmt.Errorf("failed to import product %q: %w", p.SKU, err)
+		}
+		if inserted {
+			created++
+		} else {
+			updated++
+		}
+		// updated_at меняется при каждом импорте и сам по себе изменением не считается
+		delete(snapshot, "updated_at")
+		if before, ok := previous[p.SKU]; !ok || !reflect.DeepEqual(before, snapshot) {
+			changedBefore[p.SKU] = previous[p.SKU]
+			changedAfter[p.SKU] = snapshot
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordBulkChange(ctx, "product",
+		map[string]any{"products": changedBefore},
+		map[string]any{"created": created, "updated": updated, "products": changedAfter},
+	)
+	return created, updated, nil
+}
+
+// productSnapshotsBySKU возвращает снимки уже существующих продуктов с SKU из products
+// (без updated_at) для журнала аудита. Если аудит не ведется, возвращает пустой набор.
+func productSnapshotsBySKU(ctx context.Context, tx pgx.Tx, products []models.Product) (map[string]map[string]any, error) {
+	snapshots := map[string]map[string]any{}
+	if !audit.Enabled(ctx) {
+		return snapshots, nil
+	}
+	skus := make([]string, len(products))
+	for i, p := range products {
+		skus[i] = p.SKU
+	}
+	rows, err := tx.Query(ctx, "SELECT p.sku, to_jsonb(p) - 'updated_at' FROM products p WHERE p.sku = ANY($1)", skus)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch products for audit: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sku string
+		var snapshot map[string]any
+		if err := rows.Scan(&sku, &snapshot); err != nil {
+			return nil, fmt.Errorf("failed to scan product for audit: %w", err)
+		}
+		snapshots[sku] = snapshot
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating products for audit: %w", err)
+	}
+	return snapshots, nil
+}
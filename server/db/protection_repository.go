@@ -0,0 +1,367 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// expiryComment — комментарий события истории при автоматическом истечении защиты.
+const expiryComment = "Срок защиты сделки истек"
+
+// ExpireOverdueRequests переводит в статус "Истекло" одобренные заявки, у которых прошла дата
+// окончания защиты или ожидаемая дата закрытия сделки (что наступит раньше), и пишет событие
+// в историю статусов. Заявки с нерассмотренным запросом на продление не истекают.
+// Пересечения более поздних заявок с истекшими пересчитываются. Возвращает число истекших заявок.
+func (repo *RequestRepository) ExpireOverdueRequests(ctx context.Context) (int64, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		WITH overdue AS (
+			SELECT r.id, r.status
+			FROM requests r
+			WHERE r.status = $1
+			  AND r.withdrawn_at IS NULL
+			  AND r.deleted_at IS NULL
+			  AND LEAST(r.protected_until, r.estimated_close_date) < CURRENT_DATE
+			  AND NOT EXISTS (
+				SELECT 1 FROM request_extensions e WHERE e.request_id = r.id AND e.status = 'PENDING'
+			  )
+			FOR UPDATE SKIP LOCKED
+		), expired AS (
+			UPDATE requests r
+			SET status = $2, expired_at = NOW(), updated_at = NOW()
+			FROM overdue o
+			WHERE r.id = o.id
+			RETURNING r.id, o.status AS old_status
+		)
+		INSERT INTO request_status_events (request_id, old_status, new_status, manager_id, comment)
+		SELECT id, old_status, $2, NULL, $3 FROM expired
+		RETURNING request_id
+	`
+	rows, err := tx.Query(ctx, query, models.StatusApproved, models.StatusExpired, expiryComment)
+	if err != nil {
+		log.Printf("Error expiring overdue requests: %v", err)
+		return 0, fmt.Errorf("failed to expire overdue requests: %w", err)
+	}
+	expiredIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		log.Printf("Error expiring overdue requests: %v", err)
+		return 0, fmt.Errorf("failed to expire overdue requests: %w", err)
+	}
+	for _, id := range expiredIDs {
+		if err := refreshLaterConflicts(ctx, tx, id); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return int64(len(expiredIDs)), nil
+}
+
+const extensionColumns = `
+	e.id, e.request_id, e.requested_by, e.requested_until, e.reason, e.status,
+	e.approved_until, e.reviewer_id, e.review_comment, e.created_at, e.reviewed_at`
+
+func scanExtension(row pgx.Row, ext *models.RequestExtension) error {
+	return row.Scan(
+		&ext.ID, &ext.RequestID, &ext.RequestedBy, &ext.RequestedUntil, &ext.Reason, &ext.Status,
+		&ext.ApprovedUntil, &ext.ReviewerID, &ext.ReviewComment, &ext.CreatedAt, &ext.ReviewedAt,
+	)
+}
+
+// CreateExtension сохраняет запрос партнера на продление защиты своей одобренной заявки
+// и отмечает его в истории статусов. Возвращает ErrInvalidState, если заявка не одобрена,
+// отозвана, уже ждет рассмотрения продления или запрошенная дата не позже текущей.
+func (repo *RequestRepository) CreateExtension(ctx context.Context, ext *models.RequestExtension, userID int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, withdrawnAt, err := lockOwnRequest(ctx, tx, ext.RequestID, userID)
+	if err != nil {
+		return err
+	}
+	if withdrawnAt != nil {
+		return fmt.Errorf("%w: request is withdrawn", ErrInvalidState)
+	}
+	if status != models.StatusApproved {
+		return fmt.Errorf("%w: only requests in status %q can be extended", ErrInvalidState, models.StatusApproved)
+	}
+
+	var pending bool
+	err = tx.QueryRow(ctx,
+		"SELECT EXISTS (SELECT 1 FROM request_extensions WHERE request_id = $1 AND status = 'PENDING')", ext.RequestID,
+	).Scan(&pending)
+	if err != nil {
+		return fmt.Errorf("failed to check pending extensions: %w", err)
+	}
+	if pending {
+		return fmt.Errorf("%w: extension is already pending", ErrInvalidState)
+	}
+	deadline, err := fetchProtectionEnd(ctx, tx, ext.RequestID)
+	if err != nil {
+		return err
+	}
+	if !extendsProtection(ext.RequestedUntil, deadline) {
+		return fmt.Errorf("%w: requested date must be later than %s", ErrInvalidState, deadline.Format("2006-01-02"))
+	}
+
+	ext.RequestedBy = &userID
+	ext.Status = models.ExtensionPending
+	query := `
+		INSERT INTO request_extensions (request_id, requested_by, requested_until, reason, status)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRow(ctx, query, ext.RequestID, ext.RequestedBy, ext.RequestedUntil, ext.Reason, ext.Status).Scan(&ext.ID, &ext.CreatedAt)
+	if err != nil {
+		log.Printf("Error creating extension for request %d: %v", ext.RequestID, err)
+		return fmt.Errorf("failed to create extension: %w", err)
+	}
+
+	comment := fmt.Sprintf("Партнер запросил продление защиты сделки до %s: %s", ext.RequestedUntil.Format("02.01.2006"), ext.Reason)
+	if err := insertStatusEvent(ctx, tx, ext.RequestID, &status, status, nil, &comment); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request_extension", ext.ID, nil, extensionAuditView(ext))
+	return nil
+}
+
+// ListExtensions возвращает запросы на продление защиты заявки, новые сверху.
+func (repo *RequestRepository) ListExtensions(ctx context.Context, requestID int) ([]models.RequestExtension, error) {
+	query := `SELECT ` + extensionColumns + `
+		FROM request_extensions e
+		WHERE e.request_id = $1
+		ORDER BY e.created_at DESC, e.id DESC`
+	rows, err := repo.pool.Query(ctx, query, requestID)
+	if err != nil {
+		log.Printf("Error listing extensions for request %d: %v", requestID, err)
+		return nil, fmt.Errorf("failed to list extensions: %w", err)
+	}
+	defer rows.Close()
+
+	extensions := []models.RequestExtension{}
+	for rows.Next() {
+		var ext models.RequestExtension
+		if err := scanExtension(rows, &ext); err != nil {
+			return nil, fmt.Errorf("failed to scan extension row: %w", err)
+		}
+		extensions = append(extensions, ext)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating extension rows: %w", err)
+	}
+	return extensions, nil
+}
+
+// ApproveExtension продлевает защиту заявки до until (nil — до запрошенной партнером даты).
+// Ожидаемая дата закрытия сделки сдвигается, если она наступает раньше новой даты.
+// Возвращает ErrInvalidState, если запрос уже рассмотрен или заявка больше не одобрена,
+// и ErrProtectionNotExtended, если until не позже текущего окончания защиты (см. protectionEnd).
+func (repo *RequestRepository) ApproveExtension(ctx context.Context, requestID, extensionID, reviewerID int, until *time.Time, comment *string) (*models.RequestExtension, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, err := lockExtendableRequest(ctx, tx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := lockPendingExtension(ctx, tx, requestID, extensionID)
+	if err != nil {
+		return nil, err
+	}
+	if until == nil {
+		until = &ext.RequestedUntil
+	}
+	// Продление не должно сокращать защиту; граница та же, что при подаче запроса
+	deadline, err := fetchProtectionEnd(ctx, tx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if !extendsProtection(*until, deadline) {
+		return nil, fmt.Errorf("%w: protection already lasts until %s", ErrProtectionNotExtended, deadline.Format("2006-01-02"))
+	}
+	before := requestSnapshot(ctx, tx, requestID)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE requests
+		SET protected_until = $1,
+		    estimated_close_date = CASE WHEN estimated_close_date < $1 THEN $1 ELSE estimated_close_date END,
+		    updated_at = NOW()
+		WHERE id = $2`, *until, requestID)
+	if err != nil {
+		log.Printf("Error extending protection of request %d: %v", requestID, err)
+		return nil, fmt.Errorf("failed to extend request protection: %w", err)
+	}
+
+	ext.ApprovedUntil = until
+	if err := reviewExtension(ctx, tx, ext, models.ExtensionApproved, reviewerID, comment); err != nil {
+		return nil, err
+	}
+	eventComment := "Защита сделки продлена до " + until.Format("02.01.2006")
+	if comment != nil {
+		eventComment += ": " + *comment
+	}
+	if err := insertStatusEvent(ctx, tx, requestID, &status, status, &reviewerID, &eventComment); err != nil {
+		return nil, err
+	}
+
+	after := requestSnapshot(ctx, tx, requestID)
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request", requestID, before, after)
+	return ext, nil
+}
+
+// RejectExtension отклоняет запрос на продление с комментарием; защита заявки не меняется.
+// Возвращает ErrInvalidState, если запрос уже рассмотрен.
+func (repo *RequestRepository) RejectExtension(ctx context.Context, requestID, extensionID, reviewerID int, comment string) (*models.RequestExtension, error) {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ext, err := lockPendingExtension(ctx, tx, requestID, extensionID)
+	if err != nil {
+		return nil, err
+	}
+	before := extensionAuditView(ext)
+
+	if err := reviewExtension(ctx, tx, ext, models.ExtensionRejected, reviewerID, &comment); err != nil {
+		return nil, err
+	}
+	var status models.RequestStatus
+	if err := tx.QueryRow(ctx, "SELECT status FROM requests WHERE id = $1", requestID).Scan(&status); err != nil {
+		return nil, fmt.Errorf("failed to fetch request status: %w", err)
+	}
+	eventComment := "Запрос на продление защиты сделки отклонен: " + comment
+	if err := insertStatusEvent(ctx, tx, requestID, &status, status, &reviewerID, &eventComment); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request_extension", ext.ID, before, extensionAuditView(ext))
+	return ext, nil
+}
+
+// fetchProtectionEnd возвращает фактическое окончание защиты заявки (см. protectionEnd).
+func fetchProtectionEnd(ctx context.Context, tx pgx.Tx, requestID int) (*time.Time, error) {
+	var protectedUntil, estimatedCloseDate *time.Time
+	err := tx.QueryRow(ctx,
+		"SELECT protected_until, estimated_close_date FROM requests WHERE id = $1", requestID,
+	).Scan(&protectedUntil, &estimatedCloseDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch request protection: %w", err)
+	}
+	return protectionEnd(protectedUntil, estimatedCloseDate), nil
+}
+
+// protectionEnd — фактическое окончание защиты: дата окончания защиты или ожидаемая дата
+// закрытия сделки, что наступит раньше. Как и LEAST в ExpireOverdueRequests, пустые даты
+// не учитываются; nil — защита не ограничена.
+func protectionEnd(protectedUntil, estimatedCloseDate *time.Time) *time.Time {
+	if protectedUntil == nil || (estimatedCloseDate != nil && estimatedCloseDate.Before(*protectedUntil)) {
+		return estimatedCloseDate
+	}
+	return protectedUntil
+}
+
+// extendsProtection сообщает, продлевает ли дата until защиту, заканчивающуюся в end.
+func extendsProtection(until time.Time, end *time.Time) bool {
+	return end == nil || until.After(*end)
+}
+
+// lockExtendableRequest блокирует заявку и проверяет, что её защиту еще можно продлить.
+func lockExtendableRequest(ctx context.Context, tx pgx.Tx, requestID int) (models.RequestStatus, error) {
+	var status models.RequestStatus
+	var withdrawnAt, deletedAt *time.Time
+	err := tx.QueryRow(ctx,
+		"SELECT status, withdrawn_at, deleted_at FROM requests WHERE id = $1 FOR UPDATE", requestID,
+	).Scan(&status, &withdrawnAt, &deletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", ErrNotFound
+		}
+		log.Printf("Error locking request %d for extension: %v", requestID, err)
+		return "", fmt.Errorf("failed to lock request: %w", err)
+	}
+	if withdrawnAt != nil || deletedAt != nil {
+		return "", fmt.Errorf("%w: request is withdrawn or deleted", ErrInvalidState)
+	}
+	if status != models.StatusApproved {
+		return "", fmt.Errorf("%w: request is no longer %q", ErrInvalidState, models.StatusApproved)
+	}
+	return status, nil
+}
+
+func lockPendingExtension(ctx context.Context, tx pgx.Tx, requestID, extensionID int) (*models.RequestExtension, error) {
+	query := `SELECT ` + extensionColumns + `
+		FROM request_extensions e
+		WHERE e.id = $1 AND e.request_id = $2
+		FOR UPDATE`
+	var ext models.RequestExtension
+	if err := scanExtension(tx.QueryRow(ctx, query, extensionID, requestID), &ext); err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to lock extension: %w", err)
+	}
+	if ext.Status != models.ExtensionPending {
+		return nil, fmt.Errorf("%w: extension is already %s", ErrInvalidState, ext.Status)
+	}
+	return &ext, nil
+}
+
+func reviewExtension(ctx context.Context, tx pgx.Tx, ext *models.RequestExtension, status models.ExtensionStatus, reviewerID int, comment *string) error {
+	query := `
+		UPDATE request_extensions
+		SET status = $1, approved_until = $2, reviewer_id = $3, review_comment = $4, reviewed_at = NOW()
+		WHERE id = $5
+		RETURNING reviewed_at
+	`
+	if err := tx.QueryRow(ctx, query, status, ext.ApprovedUntil, reviewerID, comment, ext.ID).Scan(&ext.ReviewedAt); err != nil {
+		log.Printf("Error reviewing extension %d: %v", ext.ID, err)
+		return fmt.Errorf("failed to update extension: %w", err)
+	}
+	ext.Status = status
+	ext.ReviewerID = &reviewerID
+	ext.ReviewComment = comment
+	return nil
+}
+
+func extensionAuditView(ext *models.RequestExtension) map[string]any {
+	view := map[string]any{
+		"request_id":      ext.RequestID,
+		"status":          string(ext.Status),
+		"requested_until": ext.RequestedUntil.Format("2006-01-02"),
+		"reason":          ext.Reason,
+	}
+	if ext.ReviewComment != nil {
+		view["review_comment"] = *ext.ReviewComment
+	}
+	return view
+}
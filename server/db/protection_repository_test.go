@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProtectionEnd(t *testing.T) {
+	date := func(s string) *time.Time {
+		if s == "" {
+			return nil
+		}
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &d
+	}
+	tests := []struct {
+		name           string
+		protectedUntil string
+		closeDate      string
+		until          string
+		wantEnd        string // пустая строка — защита не ограничена
+		wantExtends    bool
+	}{
+		{"ProtectionBinding", "2024-03-01", "2024-06-01", "2024-04-01", "2024-03-01", true},
+		{"ProtectionBindingSameDay", "2024-03-01", "2024-06-01", "2024-03-01", "2024-03-01", false},
+		// Партнер может запросить дату между датой закрытия и окончанием защиты — ее должно быть можно одобрить
+		{"CloseDateBinding", "2024-06-01", "2024-03-01", "2024-04-01", "2024-03-01", true},
+		{"CloseDateBindingEarlier", "2024-06-01", "2024-03-01", "2024-02-01", "2024-03-01", false},
+		{"NoCloseDate", "2024-03-01", "", "2024-04-01", "2024-03-01", true},
+		{"NoProtection", "", "2024-03-01", "2024-02-01", "2024-03-01", false},
+		{"Unlimited", "", "", "2024-02-01", "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			end := protectionEnd(date(tc.protectedUntil), date(tc.closeDate))
+			switch {
+			case tc.wantEnd == "" && end != nil:
+				t.Errorf("protectionEnd() = %s, want nil", end.Format("2006-01-02"))
+			case tc.wantEnd != "" && (end == nil || !end.Equal(*date(tc.wantEnd))):
+				t.Errorf("protectionEnd() = %v, want %s", end, tc.wantEnd)
+			}
+			if got := extendsProtection(*date(tc.until), end); got != tc.wantExtends {
+				t.Errorf("extendsProtection(%s) = %v, want %v", tc.until, got, tc.wantExtends)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/eeephemera/zvk-requests/server/conflict"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/workflow"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// detectConflicts ищет незавершенные заявки других партнеров на того же конечного клиента,
+// зарегистрированные раньше заявки requestID, и сохраняет пересечения с похожим проектом.
+// Прежние пересечения заявки пересчитываются, флаг is_conflicting обновляется.
+func detectConflicts(ctx context.Context, tx pgx.Tx, requestID int) error {
+	// Параллельные регистрации на одного клиента проверяются по очереди, иначе обе не увидят друг друга
+	var endClientID *int
+	if err := tx.QueryRow(ctx, "SELECT end_client_id FROM requests WHERE id = $1", requestID).Scan(&endClientID); err != nil {
+		return fmt.Errorf("failed to fetch end client for conflict check: %w", err)
+	}
+	if endClientID != nil {
+		if err := lockEndClientConflicts(ctx, tx, *endClientID); err != nil {
+			return err
+		}
+	}
+
+	active := []string{}
+	for _, s := range workflow.ActiveStatuses() {
+		active = append(active, string(s))
+	}
+	rows, err := tx.Query(ctx, `
+		SELECT o.id, n.total_price_base, o.total_price_base,
+		       CASE WHEN NULLIF(btrim(n.project_name), '') IS NOT NULL AND NULLIF(btrim(o.project_name), '') IS NOT NULL
+		            THEN similarity(lower(n.project_name), lower(o.project_name))::float8
+		       END
+		FROM requests n
+		JOIN requests o ON o.end_client_id = n.end_client_id
+		WHERE n.id = $1
+		  AND o.id < n.id
+		  AND o.partner_id <> n.partner_id
+		  AND o.withdrawn_at IS NULL
+		  AND o.deleted_at IS NULL
+		  AND o.status::text = ANY($2)`, requestID, active)
+	if err != nil {
+		return fmt.Errorf("failed to find conflicting requests: %w", err)
+	}
+	type found struct {
+		otherID        int
+		reasons        []string
+		nameSimilarity *float64
+	}
+	var conflicts []found
+	for rows.Next() {
+		var otherID int
+		var newTotal, otherTotal decimal.NullDecimal
+		var nameSimilarity *float64
+		if err := rows.Scan(&otherID, &newTotal, &otherTotal, &nameSimilarity); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan conflicting request: %w", err)
+		}
+		reasons := conflict.Evaluate(
+			conflict.Deal{TotalPrice: nullDecimalPtr(newTotal)},
+			conflict.Deal{TotalPrice: nullDecimalPtr(otherTotal)},
+			nameSimilarity,
+		)
+		if reasons != nil {
+			conflicts = append(conflicts, found{otherID, reasons, nameSimilarity})
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating conflicting requests: %w", err)
+	}
+
+	// Сохранившиеся пересечения обновляем, а не пересоздаем, чтобы не терять время обнаружения
+	otherIDs := make([]int, 0, len(conflicts))
+	for _, c := range conflicts {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO request_conflicts (request_id, conflicting_request_id, reasons, name_similarity)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (request_id, conflicting_request_id)
+			DO UPDATE SET reasons = EXCLUDED.reasons, name_similarity = EXCLUDED.name_similarity`,
+			requestID, c.otherID, c.reasons, c.nameSimilarity)
+		if err != nil {
+			return fmt.Errorf("failed to save request conflict: %w", err)
+		}
+		otherIDs = append(otherIDs, c.otherID)
+	}
+	_, err = tx.Exec(ctx,
+		"DELETE FROM request_conflicts WHERE request_id = $1 AND NOT conflicting_request_id = ANY($2)", requestID, otherIDs)
+	if err != nil {
+		return fmt.Errorf("failed to clear request conflicts: %w", err)
+	}
+	isConflicting := len(conflicts) > 0
+	if _, err := tx.Exec(ctx, "UPDATE requests SET is_conflicting = $1 WHERE id = $2", isConflicting, requestID); err != nil {
+		return fmt.Errorf("failed to flag conflicting request: %w", err)
+	}
+	if isConflicting {
+		log.Printf("Request %d conflicts with %d earlier request(s) of other partners", requestID, len(conflicts))
+	}
+	return nil
+}
+
+// refreshLaterConflicts пересчитывает пересечения более поздних заявок других партнеров на того же
+// конечного клиента, а также заявок, уже помеченных как пересекающиеся с requestID. Вызывается, когда
+// заявка requestID меняет статус или данные, отзывается, удаляется в корзину или возвращается:
+// более поздние заявки могут перестать (или снова начать) с ней пересекаться.
+func refreshLaterConflicts(ctx context.Context, tx pgx.Tx, requestID int) error {
+	rows, err := tx.Query(ctx, `
+		SELECT l.id
+		FROM requests r
+		JOIN requests l ON l.id > r.id AND l.partner_id <> r.partner_id
+		WHERE r.id = $1
+		  AND (l.end_client_id = r.end_client_id
+		       OR l.id IN (SELECT c.request_id FROM request_conflicts c WHERE c.conflicting_request_id = r.id))
+		ORDER BY l.id`, requestID)
+	if err != nil {
+		return fmt.Errorf("failed to find later requests for conflict check: %w", err)
+	}
+	laterIDs, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return fmt.Errorf("failed to find later requests for conflict check: %w", err)
+	}
+	for _, id := range laterIDs {
+		if err := detectConflicts(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockEndClientConflicts до конца транзакции блокирует проверку пересечений заявок на клиента endClientID.
+func lockEndClientConflicts(ctx context.Context, tx pgx.Tx, endClientID int) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('request_conflicts'), $1)", endClientID); err != nil {
+		return fmt.Errorf("failed to lock end client for conflict check: %w", err)
+	}
+	return nil
+}
+
+func nullDecimalPtr(d decimal.NullDecimal) *decimal.Decimal {
+	if !d.Valid {
+		return nil
+	}
+	return &d.Decimal
+}
+
+// ListRequestConflicts возвращает заявки других партнеров, пересекающиеся с заявкой requestID:
+// более ранние (direction = earlier) и более поздние (direction = later). Заявки в корзине не показываются.
+func (repo *RequestRepository) ListRequestConflicts(ctx context.Context, requestID int) ([]models.RequestConflict, error) {
+	query := `
+		SELECT o.id,
+		       CASE WHEN c.request_id = $1 THEN 'earlier' ELSE 'later' END,
+		       c.reasons, c.name_similarity::float8, c.detected_at,
+		       p.id, p.name, o.project_name, o.total_price, o.currency, o.total_price_base,
+		       o.status, o.withdrawn_at IS NOT NULL, o.created_at
+		FROM request_conflicts c
+		JOIN requests o ON o.id = CASE WHEN c.request_id = $1 THEN c.conflicting_request_id ELSE c.request_id END
+		JOIN partners p ON p.id = o.partner_id
+		WHERE (c.request_id = $1 OR c.conflicting_request_id = $1)
+		  AND o.deleted_at IS NULL
+		ORDER BY o.created_at, o.id
+	`
+	rows, err := repo.pool.Query(ctx, query, requestID)
+	if err != nil {
+		log.Printf("Error listing conflicts for request %d: %v", requestID, err)
+		return nil, fmt.Errorf("failed to list request conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	conflicts := []models.RequestConflict{}
+	for rows.Next() {
+		var c models.RequestConflict
+		var totalPrice decimal.NullDecimal
+		err := rows.Scan(
+			&c.RequestID, &c.Direction, &c.Reasons, &c.NameSimilarity, &c.DetectedAt,
+			&c.PartnerID, &c.PartnerName, &c.ProjectName, &totalPrice, &c.Currency, &c.TotalPriceBase,
+			&c.Status, &c.Withdrawn, &c.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan request conflict: %w", err)
+		}
+		c.TotalPrice = nullDecimalPtr(totalPrice)
+		conflicts = append(conflicts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating request conflicts: %w", err)
+	}
+	return conflicts, nil
+}
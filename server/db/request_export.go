@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// ExportRequestsForManager передает в fn по очереди все заявки партнеров менеджера, подходящие
+// под фильтры и сортировку списка (ListRequestsForManager), без пагинации. Заявки содержат поля
+// сделки, партнера, пользователя, конечного клиента и дистрибьютора. Строки читаются по мере
+// обработки, поэтому fn не должна долго блокироваться; ошибка fn прерывает выгрузку.
+func (repo *RequestRepository) ExportRequestsForManager(
+	ctx context.Context,
+	managerID int,
+	statusFilter models.RequestStatus,
+	partnerNameFilter string,
+	clientFilter string,
+	sortBy string,
+	sortOrder string,
+	fn func(*models.Request) error,
+) error {
+	whereQuery, args := managerRequestsWhere(managerID, statusFilter, partnerNameFilter, clientFilter)
+	query := `
+		SELECT
+			r.id, r.created_at, r.updated_at, r.status, r.project_name,
+			r.end_client_details_override, r.partner_contact_override, r.fz_law_type, r.mpt_registry_type,
+			r.partner_activities, r.deal_state_description, r.estimated_close_date,
+			r.protected_until, r.manager_comment, r.withdrawn_at, r.is_conflicting,
+			r.currency, r.total_price, r.exchange_rate, r.total_price_base,
+			p.id, p.name, p.inn,
+			u.id, u.name, u.email, u.phone,
+			ec.id, ec.name, ec.inn, ec.city,
+			d.id, d.name
+	` + managerRequestsFrom + `
+		LEFT JOIN partners d ON r.distributor_id = d.id
+	` + whereQuery + managerRequestsOrder(sortBy, sortOrder)
+
+	rows, err := repo.pool.Query(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to export requests for manager: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var req models.Request
+		var partner models.Partner
+		var userID, clientID, distributorID *int
+		var userName, userEmail, userPhone, clientName, clientINN, clientCity, distributorName *string
+
+		err := rows.Scan(
+			&req.ID, &req.CreatedAt, &req.UpdatedAt, &req.Status, &req.ProjectName,
+			&req.EndClientDetailsOverride, &req.PartnerContactOverride, &req.FZLawType, &req.MPTRegistryType,
+			&req.PartnerActivities, &req.DealStateDescription, &req.EstimatedCloseDate,
+			&req.ProtectedUntil, &req.ManagerComment, &req.WithdrawnAt, &req.IsConflicting,
+			&req.Currency, &req.TotalPrice, &req.ExchangeRate, &req.TotalPriceBase,
+			&partner.ID, &partner.Name, &partner.INN,
+			&userID, &userName, &userEmail, &userPhone,
+			&clientID, &clientName, &clientINN, &clientCity,
+			&distributorID, &distributorName,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan exported request row: %w", err)
+		}
+
+		req.PartnerID = partner.ID
+		req.Partner = &partner
+		req.Withdrawn = req.WithdrawnAt != nil
+		if userID != nil {
+			req.PartnerUserID = *userID
+			req.User = &models.User{ID: *userID, Name: userName, Email: userEmail, Phone: userPhone}
+		}
+		if clientID != nil {
+			req.EndClientID = clientID
+			req.EndClient = &models.EndClient{ID: *clientID, Name: *clientName, INN: clientINN, City: clientCity}
+		}
+		if distributorID != nil {
+			req.DistributorID = distributorID
+			req.Distributor = &models.Partner{ID: *distributorID, Name: *distributorName}
+		}
+
+		if err := fn(&req); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating exported request rows: %w", err)
+	}
+	return nil
+}
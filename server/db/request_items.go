@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/pricing"
+	"github.com/jackc/pgx/v5"
+)
+
+// insertRequestItems сохраняет товарные позиции заявки в рамках переданной транзакции.
+// Позиции должны быть заранее проверены и рассчитаны (pricing.Prepare).
+func insertRequestItems(ctx context.Context, tx pgx.Tx, requestID int, items []models.RequestItem) error {
+	query := `
+		INSERT INTO request_items (
+			request_id, position, product_name, sku, quantity, unit_price, discount_percent, line_total,
+			product_id, list_price, list_currency
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	for _, item := range items {
+		_, err := tx.Exec(ctx, query,
+			requestID, item.Position, item.ProductName, item.SKU,
+			item.Quantity, item.UnitPrice, item.DiscountPercent, item.LineTotal,
+			item.ProductID, item.ListPrice, item.ListCurrency,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert request item within transaction: %w", err)
+		}
+	}
+	return nil
+}
+
+// getItemsForRequest возвращает товарные позиции заявки в порядке их номеров
+// со скидкой от прайсовой цены; currency — валюта цен заявки.
+func (repo *RequestRepository) getItemsForRequest(ctx context.Context, requestID int, currency string) ([]models.RequestItem, error) {
+	query := `
+		SELECT id, request_id, position, product_name, sku, quantity, unit_price, discount_percent, line_total,
+		       product_id, list_price, list_currency
+		FROM request_items
+		WHERE request_id = $1
+		ORDER BY position
+	`
+	rows, err := repo.pool.Query(ctx, query, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query items for request: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.RequestItem
+	for rows.Next() {
+		var item models.RequestItem
+		if err := rows.Scan(
+			&item.ID, &item.RequestID, &item.Position, &item.ProductName, &item.SKU,
+			&item.Quantity, &item.UnitPrice, &item.DiscountPercent, &item.LineTotal,
+			&item.ProductID, &item.ListPrice, &item.ListCurrency,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan request item row: %w", err)
+		}
+		item.ListDiscountPercent = pricing.ListDiscount(item, currency)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating request item rows: %w", err)
+	}
+	return items, nil
+}
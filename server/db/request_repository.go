@@ -1,21 +1,32 @@
 package db
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"time"
 
+	"github.com/eeephemera/zvk-requests/server/audit"
 	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/storage"
+	"github.com/eeephemera/zvk-requests/server/workflow"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/shopspring/decimal"
 )
 
 // RequestRepository предоставляет методы для работы с таблицей requests.
+// Содержимое файлов хранится в blobs, в таблице files — только метаданные и ключ объекта.
 type RequestRepository struct {
-	pool *pgxpool.Pool
+	pool  *pgxpool.Pool
+	blobs storage.BlobStore
 }
 
 func (repo *RequestRepository) GetRequestByIDWithoutUser(context context.Context, id int) (any, error) {
@@ -23,23 +34,99 @@ func (repo *RequestRepository) GetRequestByIDWithoutUser(context context.Context
 }
 
 // NewRequestRepository создаёт новый RequestRepository.
-func NewRequestRepository(pool *pgxpool.Pool) *RequestRepository {
-	return &RequestRepository{pool: pool}
+func NewRequestRepository(pool *pgxpool.Pool, blobs storage.BlobStore) *RequestRepository {
+	return &RequestRepository{pool: pool, blobs: blobs}
 }
 
-// CreateFile вставляет новый файл в базу данных и возвращает его ID.
-func (repo *RequestRepository) CreateFile(ctx context.Context, file *models.File) (int, error) {
+// CreateFile сохраняет содержимое файла в хранилище, вставляет его метаданные в базу данных
+// и возвращает ID. file.FileSize — ожидаемый размер или -1, если он неизвестен; после записи
+// в нем фактический размер, а в file.SHA256 — хеш содержимого, вычисленный при записи.
+// Если вставка не удалась, объект удаляется из хранилища.
+func (repo *RequestRepository) CreateFile(ctx context.Context, file *models.File, content io.Reader) (int, error) {
+	key := storage.NewKey(time.Now())
+	hash := sha256.New()
+	size, err := repo.blobs.Put(ctx, key, io.TeeReader(content, hash), file.FileSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store file content: %w", err)
+	}
+	file.FileSize = size
+	sum := hex.EncodeToString(hash.Sum(nil))
+	file.SHA256 = &sum
+
 	query := `
-		INSERT INTO files (file_name, mime_type, file_size, file_data)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id
+		INSERT INTO files (file_name, mime_type, file_size, sha256, storage_key)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
 	`
-	var fileID int
-	err := repo.pool.QueryRow(ctx, query, file.FileName, file.MimeType, file.FileSize, file.FileData).Scan(&fileID)
+	err = repo.pool.QueryRow(ctx, query, file.FileName, file.MimeType, file.FileSize, file.SHA256, key).Scan(&file.ID, &file.CreatedAt)
 	if err != nil {
+		repo.deleteBlobs(context.WithoutCancel(ctx), []string{key})
 		return 0, fmt.Errorf("failed to insert file: %w", err)
 	}
-	return fileID, nil
+	return file.ID, nil
+}
+
+// SetFileMimeType уточняет тип уже сохраненного файла, когда он известен только после
+// разбора содержимого (например, документ Office внутри ZIP-архива).
+func (repo *RequestRepository) SetFileMimeType(ctx context.Context, fileID int, mimeType string) error {
+	tag, err := repo.pool.Exec(ctx, "UPDATE files SET mime_type = $2 WHERE id = $1", fileID, mimeType)
+	if err != nil {
+		return fmt.Errorf("failed to update file type: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteUnlinkedFiles удаляет файлы из fileIDs, которые так и не были привязаны ни к заявке,
+// ни к сообщению (например, загрузка прошла, а сохранение заявки — нет), вместе с содержимым.
+// Привязанные файлы не трогает, поэтому вызывать можно и после успешного сохранения.
+func (repo *RequestRepository) DeleteUnlinkedFiles(ctx context.Context, fileIDs []int) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+	rows, err := repo.pool.Query(ctx, `
+		DELETE FROM files f
+		WHERE f.id = ANY($1)
+		  AND NOT EXISTS (SELECT 1 FROM `+requestFileLinks+` rf WHERE rf.file_id = f.id)
+		RETURNING f.storage_key
+	`, fileIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete unlinked files: %w", err)
+	}
+	keys, err := collectStorageKeys(rows)
+	if err != nil {
+		return fmt.Errorf("failed to delete unlinked files: %w", err)
+	}
+	repo.deleteBlobs(ctx, keys)
+	return nil
+}
+
+// deleteBlobs удаляет содержимое файлов, строки которых уже удалены из базы. Ошибки только
+// логируются: осиротевший объект занимает место, но ни на что не влияет.
+func (repo *RequestRepository) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := repo.blobs.Delete(ctx, key); err != nil {
+			log.Printf("Error deleting file content %s: %v", key, err)
+		}
+	}
+}
+
+// collectStorageKeys читает ключи объектов из DELETE ... RETURNING storage_key.
+// Файлы, еще хранящиеся в file_data, ключа не имеют и пропускаются.
+func collectStorageKeys(rows pgx.Rows) ([]string, error) {
+	keys, err := pgx.CollectRows(rows, pgx.RowTo[*string])
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key != nil {
+			result = append(result, *key)
+		}
+	}
+	return result, nil
 }
 
 // CreateRequest вставляет новую заявку в базу данных и связывает с ней файлы.
@@ -53,15 +140,18 @@ func (repo *RequestRepository) CreateRequest(ctx context.Context, req *models.Re
 	// Если Commit выполнится успешно, Rollback не сделает ничего.
 	defer tx.Rollback(ctx)
 
-	// Шаг 1: Вставляем основную запись заявки
+	// Шаг 1: Вставляем основную запись заявки с суммой в рублях по текущему курсу
+	if err := applyExchangeRate(ctx, tx, req); err != nil {
+		return err
+	}
 	requestQuery := `
 		INSERT INTO requests (
 			partner_user_id, partner_id, end_client_id, end_client_details_override,
 			distributor_id, partner_contact_override, fz_law_type, mpt_registry_type,
 			partner_activities, deal_state_description, estimated_close_date,
 			status, manager_comment,
-			project_name, quantity, unit_price, total_price
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			project_name, total_price, currency, exchange_rate, total_price_base
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id, created_at, updated_at
 	`
 	err = tx.QueryRow(ctx, requestQuery,
@@ -69,12 +159,15 @@ func (repo *RequestRepository) CreateRequest(ctx context.Context, req *models.Re
 		req.DistributorID, req.PartnerContactOverride, req.FZLawType, req.MPTRegistryType,
 		req.PartnerActivities, req.DealStateDescription, req.EstimatedCloseDate,
 		req.Status, req.ManagerComment,
-		req.ProjectName, req.Quantity, req.UnitPrice, req.TotalPrice,
+		req.ProjectName, req.TotalPrice, req.Currency, req.ExchangeRate, req.TotalPriceBase,
 	).Scan(&req.ID, &req.CreatedAt, &req.UpdatedAt)
 
 	if err != nil {
 		return fmt.Errorf("failed to insert request within transaction: %w", err)
 	}
+	if err := insertRequestItems(ctx, tx, req.ID, req.Items); err != nil {
+		return err
+	}
 
 	// Шаг 2: Связываем файлы с созданной заявкой
 	if len(fileIDs) > 0 {
@@ -96,10 +189,22 @@ func (repo *RequestRepository) CreateRequest(ctx context.Context, req *models.Re
 		}
 	}
 
-	// Шаг 3: Коммитим транзакцию
+	// Шаг 3: Фиксируем событие создания в истории статусов
+	if err := insertStatusEvent(ctx, tx, req.ID, nil, req.Status, nil, nil); err != nil {
+		return err
+	}
+
+	// Шаг 4: Проверяем, не зарегистрирована ли эта сделка раньше другим партнером
+	if err := detectConflicts(ctx, tx, req.ID); err != nil {
+		return err
+	}
+
+	// Шаг 5: Коммитим транзакцию
+	after := requestSnapshot(ctx, tx, req.ID)
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	audit.RecordChange(ctx, "request", req.ID, nil, after)
 
 	return nil
 }
@@ -112,7 +217,9 @@ func (repo *RequestRepository) GetRequestDetailsByID(ctx context.Context, reques
 			r.distributor_id, r.partner_contact_override, r.fz_law_type, r.mpt_registry_type,
 			r.partner_activities, r.deal_state_description, r.estimated_close_date,
 			r.status, r.manager_comment, r.created_at, r.updated_at,
-			r.project_name, r.quantity, r.unit_price, r.total_price,
+			r.project_name, r.total_price, r.currency, r.exchange_rate, r.total_price_base,
+			r.withdrawn_at, r.withdrawal_reason, r.deleted_at, r.deleted_by,
+			r.protected_until, r.expired_at,
 			-- Данные пользователя
 			u.id as user_id, u.login, u.role, u.partner_id as user_partner_id, u.name as user_name, u.email as user_email, u.phone as user_phone, u.created_at as user_created_at,
 			-- Данные партнера
@@ -130,8 +237,7 @@ func (repo *RequestRepository) GetRequestDetailsByID(ctx context.Context, reques
 	var endClientID, distributorID sql.NullInt64
 	var estimatedCloseDate sql.NullTime
 	var endClientDetailsOverride, partnerContactOverride, fzLawType, mptRegistryType, partnerActivities, dealStateDescription, managerComment, projectName *string
-	var quantity sql.NullInt32
-	var unitPrice, totalPrice decimal.NullDecimal
+	var totalPrice decimal.NullDecimal
 
 	// Nullable поля для пользователя (u.*)
 	var userName, userEmail, userPhone sql.NullString
@@ -144,7 +250,9 @@ func (repo *RequestRepository) GetRequestDetailsByID(ctx context.Context, reques
 		&distributorID, &partnerContactOverride, &fzLawType, &mptRegistryType,
 		&partnerActivities, &dealStateDescription, &estimatedCloseDate,
 		&req.Status, &managerComment, &req.CreatedAt, &req.UpdatedAt,
-		&projectName, &quantity, &unitPrice, &totalPrice,
+		&projectName, &totalPrice, &req.Currency, &req.ExchangeRate, &req.TotalPriceBase,
+		&req.WithdrawnAt, &req.WithdrawalReason, &req.DeletedAt, &req.DeletedBy,
+		&req.ProtectedUntil, &req.ExpiredAt,
 		// User
 		&user.ID, &user.Login, &user.Role, &user.PartnerID, &userName, &userEmail, &userPhone, &user.CreatedAt,
 		// Partner
@@ -167,14 +275,14 @@ func (repo *RequestRepository) GetRequestDetailsByID(ctx context.Context, reques
 	}
 	req.Files = files
 
+	items, err := repo.getItemsForRequest(ctx, requestID, req.Currency)
+	if err != nil {
+		log.Printf("Warning: failed to fetch items for request %d: %v", requestID, err)
+	}
+	req.Items = items
+
 	// Заполняем поля самой заявки
 	req.ProjectName = projectName
-	if quantity.Valid {
-		req.Quantity = pint(int(quantity.Int32))
-	}
-	if unitPrice.Valid {
-		req.UnitPrice = &unitPrice.Decimal
-	}
 	if totalPrice.Valid {
 		req.TotalPrice = &totalPrice.Decimal
 	}
@@ -192,6 +300,7 @@ func (repo *RequestRepository) GetRequestDetailsByID(ctx context.Context, reques
 		partner.AssignedManagerID = pint(int(partnerManagerID.Int64))
 	}
 	req.Partner = &partner
+	req.Withdrawn = req.WithdrawnAt != nil
 
 	if endClientID.Valid {
 		req.EndClientID = pint(int(endClientID.Int64))
@@ -244,7 +353,7 @@ func (repo *RequestRepository) GetRequestDetailsByID(ctx context.Context, reques
 // getFilesForRequest - вспомогательный метод для получения всех файлов, связанных с заявкой
 func (repo *RequestRepository) getFilesForRequest(ctx context.Context, requestID int) ([]*models.File, error) {
 	query := `
-		SELECT f.id, f.file_name, f.mime_type, f.file_size, f.created_at
+		SELECT f.id, f.file_name, f.mime_type, f.file_size, f.sha256, f.created_at
 		FROM files f
 		JOIN request_files rf ON f.id = rf.file_id
 		WHERE rf.request_id = $1
@@ -259,7 +368,7 @@ func (repo *RequestRepository) getFilesForRequest(ctx context.Context, requestID
 	var files []*models.File
 	for rows.Next() {
 		var file models.File
-		if err := rows.Scan(&file.ID, &file.FileName, &file.MimeType, &file.FileSize, &file.CreatedAt); err != nil {
+		if err := rows.Scan(&file.ID, &file.FileName, &file.MimeType, &file.FileSize, &file.SHA256, &file.CreatedAt); err != nil {
 			return nil, fmt.Errorf("failed to scan file row: %w", err)
 		}
 		files = append(files, &file)
@@ -274,9 +383,9 @@ func (repo *RequestRepository) getFilesForRequest(ctx context.Context, requestID
 
 // GetFileByID возвращает метаданные файла (без содержимого) по его ID.
 func (repo *RequestRepository) GetFileByID(ctx context.Context, fileID int) (*models.File, error) {
-	query := `SELECT id, file_name, mime_type, file_size, created_at FROM files WHERE id = $1`
+	query := `SELECT id, file_name, mime_type, file_size, sha256, created_at FROM files WHERE id = $1`
 	var file models.File
-	err := repo.pool.QueryRow(ctx, query, fileID).Scan(&file.ID, &file.FileName, &file.MimeType, &file.FileSize, &file.CreatedAt)
+	err := repo.pool.QueryRow(ctx, query, fileID).Scan(&file.ID, &file.FileName, &file.MimeType, &file.FileSize, &file.SHA256, &file.CreatedAt)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
@@ -286,31 +395,179 @@ func (repo *RequestRepository) GetFileByID(ctx context.Context, fileID int) (*mo
 	return &file, nil
 }
 
-// GetFileDataByID возвращает только бинарные данные файла по его ID.
-func (repo *RequestRepository) GetFileDataByID(ctx context.Context, fileID int) ([]byte, error) {
-	query := `SELECT file_data FROM files WHERE id = $1`
+// OpenFile открывает содержимое файла для чтения. Файлы, загруженные до переноса содержимого
+// во внешнее хранилище и еще не перенесенные cmd/migrate-files, читаются из files.file_data.
+func (repo *RequestRepository) OpenFile(ctx context.Context, fileID int) (storage.Blob, error) {
+	var key *string
+	err := repo.pool.QueryRow(ctx, `SELECT storage_key FROM files WHERE id = $1`, fileID).Scan(&key)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get file storage key: %w", err)
+	}
+	if key != nil {
+		blob, err := repo.blobs.Open(ctx, *key)
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return blob, err
+	}
+
 	var data []byte
-	err := repo.pool.QueryRow(ctx, query, fileID).Scan(&data)
+	err = repo.pool.QueryRow(ctx, `SELECT file_data FROM files WHERE id = $1`, fileID).Scan(&data)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("failed to get file data by id: %w", err)
 	}
-	return data, nil
+	return legacyBlob{bytes.NewReader(data)}, nil
+}
+
+// legacyBlob — содержимое файла, прочитанное из files.file_data.
+type legacyBlob struct {
+	*bytes.Reader
 }
 
-// UpdateRequest - пример. НЕ ИСПОЛЬЗУЕТСЯ.
-// Здесь может быть логика для общего обновления полей заявки.
-func (repo *RequestRepository) UpdateRequest(ctx context.Context, req *models.Request) error {
-	// ...
+func (b legacyBlob) Close() error {
+	return nil
+}
+
+// UpdateRequest сохраняет правку заявки партнером: обновляет поля сделки, прикрепляет
+// и открепляет файлы и возвращает заявку на рассмотрение. Всё выполняется в одной
+// транзакции вместе с записью события в историю статусов.
+// Если из текущего статуса партнер не может вернуть заявку на рассмотрение,
+// возвращает *workflow.TransitionError.
+func (repo *RequestRepository) UpdateRequest(ctx context.Context, req *models.Request, addFileIDs, removeFileIDs []int, comment *string) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Шаг 1: Блокируем заявку владельца и проверяем переход статуса
+	oldStatus, withdrawnAt, err := lockOwnRequest(ctx, tx, req.ID, req.PartnerUserID)
+	if err != nil {
+		return err
+	}
+	if withdrawnAt != nil {
+		return fmt.Errorf("%w: request is withdrawn", ErrInvalidState)
+	}
+	if normalized, ok := workflow.Parse(string(oldStatus)); ok {
+		oldStatus = normalized
+	}
+	if err := workflow.CheckTransition(oldStatus, models.StatusPending, models.RoleUser); err != nil {
+		return err
+	}
+	before := requestSnapshot(ctx, tx, req.ID)
+
+	// Шаг 2: Обновляем поля сделки (сумма в рублях — по текущему курсу) и возвращаем заявку на рассмотрение
+	req.Status = models.StatusPending
+	if err := applyExchangeRate(ctx, tx, req); err != nil {
+		return err
+	}
+	updateQuery := `
+		UPDATE requests SET
+			end_client_id = $1, end_client_details_override = $2,
+			distributor_id = $3, partner_contact_override = $4, fz_law_type = $5, mpt_registry_type = $6,
+			partner_activities = $7, deal_state_description = $8, estimated_close_date = $9,
+			project_name = $10, total_price = $11, currency = $12, exchange_rate = $13, total_price_base = $14,
+			status = $15, updated_at = NOW()
+		WHERE id = $16
+		RETURNING created_at, updated_at
+	`
+	err = tx.QueryRow(ctx, updateQuery,
+		req.EndClientID, req.EndClientDetailsOverride,
+		req.DistributorID, req.PartnerContactOverride, req.FZLawType, req.MPTRegistryType,
+		req.PartnerActivities, req.DealStateDescription, req.EstimatedCloseDate,
+		req.ProjectName, req.TotalPrice, req.Currency, req.ExchangeRate, req.TotalPriceBase,
+		req.Status, req.ID,
+	).Scan(&req.CreatedAt, &req.UpdatedAt)
+	if err != nil {
+		log.Printf("Error updating request %d: %v", req.ID, err)
+		return fmt.Errorf("failed to update request within transaction: %w", err)
+	}
+	// Позиции заменяются целиком, как и остальные поля сделки
+	if _, err := tx.Exec(ctx, "DELETE FROM request_items WHERE request_id = $1", req.ID); err != nil {
+		return fmt.Errorf("failed to clear request items within transaction: %w", err)
+	}
+	if err := insertRequestItems(ctx, tx, req.ID, req.Items); err != nil {
+		return err
+	}
+
+	// Шаг 3: Открепляем файлы и удаляем те, что больше ни к чему не привязаны.
+	// Содержимое удаляется из хранилища только после фиксации транзакции.
+	var deletedKeys []string
+	if len(removeFileIDs) > 0 {
+		_, err = tx.Exec(ctx, "DELETE FROM request_files WHERE request_id = $1 AND file_id = ANY($2)", req.ID, removeFileIDs)
+		if err != nil {
+			return fmt.Errorf("failed to unlink files from request within transaction: %w", err)
+		}
+		rows, err := tx.Query(ctx, `
+			DELETE FROM files f
+			WHERE f.id = ANY($1)
+			  AND NOT EXISTS (SELECT 1 FROM `+requestFileLinks+` rf WHERE rf.file_id = f.id)
+			RETURNING f.storage_key
+		`, removeFileIDs)
+		if err == nil {
+			deletedKeys, err = collectStorageKeys(rows)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to delete orphaned files within transaction: %w", err)
+		}
+	}
+
+	// Шаг 4: Прикрепляем новые файлы
+	if len(addFileIDs) > 0 {
+		rows := make([][]interface{}, len(addFileIDs))
+		for i, fileID := range addFileIDs {
+			rows[i] = []interface{}{req.ID, fileID}
+		}
+		_, err = tx.CopyFrom(
+			ctx,
+			pgx.Identifier{"request_files"},
+			[]string{"request_id", "file_id"},
+			pgx.CopyFromRows(rows),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to link files to request within transaction: %w", err)
+		}
+	}
+
+	// Шаг 5: Фиксируем повторную отправку в истории статусов
+	if err := insertStatusEvent(ctx, tx, req.ID, &oldStatus, req.Status, nil, comment); err != nil {
+		return err
+	}
+
+	// Шаг 6: Конечный клиент или проект могли измениться — пересечения проверяются заново,
+	// в том числе у более поздних заявок
+	if err := detectConflicts(ctx, tx, req.ID); err != nil {
+		return err
+	}
+	if err := refreshLaterConflicts(ctx, tx, req.ID); err != nil {
+		return err
+	}
+
+	after := requestSnapshot(ctx, tx, req.ID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	repo.deleteBlobs(ctx, deletedKeys)
+	audit.RecordChange(ctx, "request", req.ID, before, after)
 	return nil
 }
 
 // ListRequestsByUser возвращает список заявок для конкретного пользователя с пагинацией.
-func (r *RequestRepository) ListRequestsByUser(ctx context.Context, userID int, limit, offset int) ([]models.Request, int64, error) {
+// Отозванные заявки возвращаются только при includeWithdrawn = true.
+func (r *RequestRepository) ListRequestsByUser(ctx context.Context, userID int, limit, offset int, includeWithdrawn bool) ([]models.Request, int64, error) {
+	whereQuery := "WHERE r.partner_user_id = $1 AND r.deleted_at IS NULL"
+	if !includeWithdrawn {
+		whereQuery += " AND r.withdrawn_at IS NULL"
+	}
+
 	// Сначала считаем общее количество заявок для пользователя
-	countQuery := "SELECT COUNT(*) FROM requests WHERE partner_user_id = $1"
+	countQuery := "SELECT COUNT(*) FROM requests r " + whereQuery
 	var total int64
 	err := r.pool.QueryRow(ctx, countQuery, userID).Scan(&total)
 	if err != nil {
@@ -335,11 +592,15 @@ func (r *RequestRepository) ListRequestsByUser(ctx context.Context, userID int,
 			ec.id as client_id,
 			ec.name as client_name,
 			r.end_client_details_override,
-			r.manager_comment
+			r.manager_comment,
+			r.withdrawn_at,
+			r.is_conflicting,
+			r.currency, r.total_price, r.total_price_base,
+			` + unreadCommentsColumn + `
 		FROM requests r
 		LEFT JOIN partners p ON r.partner_id = p.id
 		LEFT JOIN end_clients ec ON r.end_client_id = ec.id
-		WHERE r.partner_user_id = $1
+		` + whereQuery + `
 		ORDER BY r.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -371,6 +632,10 @@ func (r *RequestRepository) ListRequestsByUser(ctx context.Context, userID int,
 			&clientName,
 			&endClientDetailsOverride,
 			&managerComment,
+			&req.WithdrawnAt,
+			&req.IsConflicting,
+			&req.Currency, &req.TotalPrice, &req.TotalPriceBase,
+			&req.UnreadComments,
 		)
 		if err != nil {
 			// Логируем ошибку, но не прерываем весь процесс
@@ -379,6 +644,7 @@ func (r *RequestRepository) ListRequestsByUser(ctx context.Context, userID int,
 		}
 
 		req.Partner = &partner
+		req.Withdrawn = req.WithdrawnAt != nil
 		if clientID.Valid {
 			client.ID = int(clientID.Int64)
 			// Если имя не NULL, присваиваем его
@@ -407,26 +673,71 @@ func (r *RequestRepository) ListRequestsByUser(ctx context.Context, userID int,
 	return requests, total, nil
 }
 
-// UpdateRequestStatus обновляет статус заявки и добавляет комментарий менеджера.
-func (repo *RequestRepository) UpdateRequestStatus(ctx context.Context, requestID int, newStatus models.RequestStatus, managerComment *string) error {
+// UpdateRequestStatus обновляет статус заявки, добавляет комментарий менеджера
+// и в той же транзакции записывает событие в историю статусов.
+// Если переход не разрешён графом статусов, возвращает *workflow.TransitionError.
+// protectedUntil задает окончание защиты сделки (при одобрении), nil оставляет его прежним.
+func (repo *RequestRepository) UpdateRequestStatus(ctx context.Context, requestID int, newStatus models.RequestStatus, managerComment *string, managerID int, protectedUntil *time.Time) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Блокируем строку, чтобы старый статус в истории соответствовал действительности
+	var oldStatus models.RequestStatus
+	var withdrawnAt, deletedAt *time.Time
+	err = tx.QueryRow(ctx, "SELECT status, withdrawn_at, deleted_at FROM requests WHERE id = $1 FOR UPDATE", requestID).Scan(&oldStatus, &withdrawnAt, &deletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound // Заявка с таким ID не найдена
+		}
+		log.Printf("Error locking request %d for status update: %v", requestID, err)
+		return fmt.Errorf("failed to fetch current request status: %w", err)
+	}
+	if withdrawnAt != nil {
+		return fmt.Errorf("%w: request is withdrawn by partner", ErrInvalidState)
+	}
+	if deletedAt != nil {
+		return fmt.Errorf("%w: request is deleted", ErrInvalidState)
+	}
+	if normalized, ok := workflow.Parse(string(oldStatus)); ok {
+		oldStatus = normalized
+	}
+
+	// Проверяем переход под блокировкой, чтобы параллельные изменения не обошли граф статусов
+	if err := workflow.CheckTransition(oldStatus, newStatus, models.RoleManager); err != nil {
+		return err
+	}
+	before := requestSnapshot(ctx, tx, requestID)
+
 	query := `
 		UPDATE requests
 		SET
 			status = $1,
 			manager_comment = $2,
+			protected_until = COALESCE($3, protected_until),
 			updated_at = NOW()
-		WHERE id = $3
+		WHERE id = $4
 	`
-	tag, err := repo.pool.Exec(ctx, query, newStatus, managerComment, requestID)
-	if err != nil {
+	if _, err := tx.Exec(ctx, query, newStatus, managerComment, protectedUntil, requestID); err != nil {
 		log.Printf("Error updating status for request %d: %v", requestID, err)
 		return fmt.Errorf("failed to update request status: %w", err)
 	}
 
-	if tag.RowsAffected() == 0 {
-		return ErrNotFound // Заявка с таким ID не найдена
+	if err := insertStatusEvent(ctx, tx, requestID, &oldStatus, newStatus, &managerID, managerComment); err != nil {
+		return err
+	}
+	// Отклоненная или завершенная заявка больше не защищает клиента от более поздних
+	if err := refreshLaterConflicts(ctx, tx, requestID); err != nil {
+		return err
 	}
 
+	after := requestSnapshot(ctx, tx, requestID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request", requestID, before, after)
 	return nil
 }
 
@@ -451,7 +762,7 @@ func (repo *RequestRepository) CheckUserAccessToFile(ctx context.Context, userID
 		query := `
 			SELECT EXISTS (
 				SELECT 1
-				FROM request_files rf
+				FROM ` + requestFileLinks + ` rf
 				JOIN requests r ON rf.request_id = r.id
 				JOIN partners p ON r.partner_id = p.id
 				WHERE rf.file_id = $1 AND p.assigned_manager_id = $2
@@ -470,9 +781,9 @@ func (repo *RequestRepository) CheckUserAccessToFile(ctx context.Context, userID
 	query := `
 		SELECT EXISTS (
 			SELECT 1
-			FROM request_files rf
+			FROM ` + requestFileLinks + ` rf
 			JOIN requests r ON rf.request_id = r.id
-			WHERE rf.file_id = $1 AND r.partner_user_id = $2
+			WHERE rf.file_id = $1 AND r.partner_user_id = $2 AND r.deleted_at IS NULL
 		)
 	`
 	var hasAccess bool
@@ -490,18 +801,45 @@ func (repo *RequestRepository) ListFilesForRequest(ctx context.Context, requestI
 	return repo.getFilesForRequest(ctx, requestID)
 }
 
-// DeleteRequest удаляет заявку по ID.
-// Благодаря ON DELETE CASCADE в БД, связанные записи в request_files также удалятся.
-func (repo *RequestRepository) DeleteRequest(ctx context.Context, requestID int) error {
-	query := "DELETE FROM requests WHERE id = $1"
-	tag, err := repo.pool.Exec(ctx, query, requestID)
+// DeleteRequest перемещает заявку в корзину: помечает удаленной, не трогая связанные файлы.
+// Окончательное удаление выполняет PurgeDeletedRequests по истечении срока хранения.
+// Удаление фиксируется в истории статусов.
+func (repo *RequestRepository) DeleteRequest(ctx context.Context, requestID int, managerID int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	before := requestSnapshot(ctx, tx, requestID)
+
+	var status models.RequestStatus
+	query := `
+		UPDATE requests SET deleted_at = NOW(), deleted_by = $2
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING status
+	`
+	err = tx.QueryRow(ctx, query, requestID, managerID).Scan(&status)
 	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
 		log.Printf("Error deleting request %d: %v", requestID, err)
 		return fmt.Errorf("failed to delete request: %w", err)
 	}
-	if tag.RowsAffected() == 0 {
-		return ErrNotFound
+
+	comment := "Заявка удалена в корзину"
+	if err := insertStatusEvent(ctx, tx, requestID, &status, status, &managerID, &comment); err != nil {
+		return err
+	}
+	if err := refreshLaterConflicts(ctx, tx, requestID); err != nil {
+		return err
+	}
+
+	after := requestSnapshot(ctx, tx, requestID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	audit.RecordChange(ctx, "request", requestID, before, after)
 	return nil
 }
 
@@ -535,8 +873,8 @@ func (repo *RequestRepository) ListAllRequests(
 		LEFT JOIN partners p ON r.partner_id = p.id
 		LEFT JOIN end_clients ec ON r.end_client_id = ec.id
 	`
-	// Конструктор для WHERE
-	whereClauses := []string{}
+	// Конструктор для WHERE (удаленные в корзину заявки не показываем)
+	whereClauses := []string{"r.deleted_at IS NULL"}
 	args := []interface{}{}
 	argID := 1
 
@@ -560,10 +898,7 @@ func (repo *RequestRepository) ListAllRequests(
 		argID++
 	}
 
-	whereQuery := ""
-	if len(whereClauses) > 0 {
-		whereQuery = "WHERE " + strings.Join(whereClauses, " AND ")
-	}
+	whereQuery := "WHERE " + strings.Join(whereClauses, " AND ")
 
 	// Запрос для получения общего количества записей с учетом фильтров
 	countQuery := "SELECT COUNT(*) " + baseQuery + whereQuery
@@ -585,7 +920,10 @@ func (repo *RequestRepository) ListAllRequests(
 			u.id as user_id, u.name as user_name,
 			ec.id as client_id, ec.name as client_name,
 			r.end_client_details_override,
-			r.manager_comment
+			r.manager_comment,
+			r.withdrawn_at,
+			r.is_conflicting,
+			r.currency, r.total_price, r.total_price_base
 	` + baseQuery + whereQuery
 
 	// Сортировка
@@ -597,6 +935,7 @@ func (repo *RequestRepository) ListAllRequests(
 			"partner":    "p.name",
 			"client":     "ec.name",
 			"project":    "r.project_name",
+			"total":      "COALESCE(r.total_price_base, 0)",
 		}
 		if dbSortBy, ok := allowedSortBy[sortBy]; ok {
 			// Проверка направления сортировки
@@ -638,6 +977,9 @@ func (repo *RequestRepository) ListAllRequests(
 			&clientID, &clientName,
 			&endClientDetailsOverride,
 			&managerComment,
+			&req.WithdrawnAt,
+			&req.IsConflicting,
+			&req.Currency, &req.TotalPrice, &req.TotalPriceBase,
 		)
 		if err != nil {
 			log.Printf("Error scanning request row: %v", err)
@@ -645,6 +987,7 @@ func (repo *RequestRepository) ListAllRequests(
 		}
 
 		req.Partner = &partner
+		req.Withdrawn = req.WithdrawnAt != nil
 		req.User = &user
 
 		if clientID.Valid {
@@ -692,26 +1035,32 @@ func (repo *RequestRepository) CheckManagerAccess(ctx context.Context, managerID
 	return hasAccess, nil
 }
 
-// ListRequestsForManager возвращает список заявок для партнеров, назначенных указанному менеджеру.
-// Заменяет ListAllRequests.
-func (repo *RequestRepository) ListRequestsForManager(
-	ctx context.Context,
-	managerID int,
-	limit, offset int,
-	statusFilter models.RequestStatus,
-	partnerNameFilter string,
-	clientFilter string,
-	sortBy string,
-	sortOrder string,
-) ([]models.Request, int64, error) {
-	baseQuery := `
+// CheckUserAccess проверяет, является ли пользователь создателем заявки.
+// Удаленные менеджером заявки партнеру недоступны.
+func (repo *RequestRepository) CheckUserAccess(ctx context.Context, userID int, requestID int) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM requests WHERE id = $1 AND partner_user_id = $2 AND deleted_at IS NULL)`
+	var hasAccess bool
+	err := repo.pool.QueryRow(ctx, query, requestID, userID).Scan(&hasAccess)
+	if err != nil {
+		log.Printf("Error checking user access for user %d, request %d: %v", userID, requestID, err)
+		return false, fmt.Errorf("failed to check user access: %w", err)
+	}
+	return hasAccess, nil
+}
+
+// managerRequestsFrom — источник строк для списка и выгрузки заявок менеджера.
+const managerRequestsFrom = `
 			FROM requests r
 		LEFT JOIN users u ON r.partner_user_id = u.id
 		JOIN partners p ON r.partner_id = p.id
 		LEFT JOIN end_clients ec ON r.end_client_id = ec.id
 	`
-	// Основное условие - фильтрация по ответственному менеджеру
-	whereClauses := []string{"p.assigned_manager_id = $1"}
+
+// managerRequestsWhere строит условие выборки заявок партнеров менеджера по фильтрам списка.
+// managerID всегда передается первым аргументом ($1).
+func managerRequestsWhere(managerID int, statusFilter models.RequestStatus, partnerNameFilter, clientFilter string) (string, []interface{}) {
+	// Основное условие - фильтрация по ответственному менеджеру; удаленные в корзину заявки не показываем
+	whereClauses := []string{"p.assigned_manager_id = $1", "r.deleted_at IS NULL"}
 	args := []interface{}{managerID}
 	argID := 2 // Начинаем нумерацию аргументов со 2
 
@@ -732,10 +1081,50 @@ func (repo *RequestRepository) ListRequestsForManager(
 		// Ищем как в названии клиента, так и в поле оверрайда
 		whereClauses = append(whereClauses, fmt.Sprintf("(ec.name ILIKE $%d OR r.end_client_details_override ILIKE $%d)", argID, argID))
 		args = append(args, "%"+clientFilter+"%")
-		argID++
 	}
 
-	whereQuery := "WHERE " + strings.Join(whereClauses, " AND ")
+	return "WHERE " + strings.Join(whereClauses, " AND "), args
+}
+
+// managerRequestsOrder возвращает ORDER BY для списка заявок менеджера. Неизвестное поле
+// сортировки игнорируется, без sortBy — сначала новые.
+func managerRequestsOrder(sortBy, sortOrder string) string {
+	if sortBy == "" {
+		return " ORDER BY r.created_at DESC"
+	}
+	allowedSortBy := map[string]string{
+		"created_at": "r.created_at",
+		"status":     "r.status",
+		"partner":    "p.name",
+		"client":     "ec.name",
+		"project":    "r.project_name",
+		"total":      "COALESCE(r.total_price_base, 0)",
+	}
+	dbSortBy, ok := allowedSortBy[sortBy]
+	if !ok {
+		return ""
+	}
+	if strings.ToUpper(sortOrder) != "DESC" {
+		sortOrder = "ASC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s", dbSortBy, sortOrder)
+}
+
+// ListRequestsForManager возвращает список заявок для партнеров, назначенных указанному менеджеру.
+// Заменяет ListAllRequests.
+func (repo *RequestRepository) ListRequestsForManager(
+	ctx context.Context,
+	managerID int,
+	limit, offset int,
+	statusFilter models.RequestStatus,
+	partnerNameFilter string,
+	clientFilter string,
+	sortBy string,
+	sortOrder string,
+) ([]models.Request, int64, error) {
+	baseQuery := managerRequestsFrom
+	whereQuery, args := managerRequestsWhere(managerID, statusFilter, partnerNameFilter, clientFilter)
+	argID := len(args) + 1
 
 	countQuery := "SELECT COUNT(*) " + baseQuery + whereQuery
 	var total int64
@@ -755,26 +1144,14 @@ func (repo *RequestRepository) ListRequestsForManager(
 			u.id as user_id, u.name as user_name,
 			ec.id as client_id, ec.name as client_name,
 			r.end_client_details_override,
-			r.manager_comment
+			r.manager_comment,
+			r.withdrawn_at,
+			r.is_conflicting,
+			r.currency, r.total_price, r.total_price_base,
+			` + unreadCommentsColumn + `
 	` + baseQuery + whereQuery
 
-	if sortBy != "" {
-		allowedSortBy := map[string]string{
-			"created_at": "r.created_at",
-			"status":     "r.status",
-			"partner":    "p.name",
-			"client":     "ec.name",
-			"project":    "r.project_name",
-		}
-		if dbSortBy, ok := allowedSortBy[sortBy]; ok {
-			if strings.ToUpper(sortOrder) != "DESC" {
-				sortOrder = "ASC"
-			}
-			dataQuery += fmt.Sprintf(" ORDER BY %s %s", dbSortBy, sortOrder)
-		}
-	} else {
-		dataQuery += " ORDER BY r.created_at DESC"
-	}
+	dataQuery += managerRequestsOrder(sortBy, sortOrder)
 
 	dataQuery += fmt.Sprintf(" LIMIT $%d OFFSET $%d", argID, argID+1)
 	args = append(args, limit, offset)
@@ -803,6 +1180,10 @@ func (repo *RequestRepository) ListRequestsForManager(
 			&clientID, &clientName,
 			&endClientDetailsOverride,
 			&managerComment,
+			&req.WithdrawnAt,
+			&req.IsConflicting,
+			&req.Currency, &req.TotalPrice, &req.TotalPriceBase,
+			&req.UnreadComments,
 		)
 		if err != nil {
 			log.Printf("Error scanning request row for manager %d: %v", managerID, err)
@@ -810,6 +1191,7 @@ func (repo *RequestRepository) ListRequestsForManager(
 		}
 
 		req.Partner = &partner
+		req.Withdrawn = req.WithdrawnAt != nil
 		req.User = &user
 
 		if clientID.Valid {
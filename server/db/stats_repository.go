@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/workflow"
+	"github.com/jackc/pgx/v5"
+	"github.com/shopspring/decimal"
+)
+
+// scopedRequestsCTE — заявки партнеров менеджера $1, созданные с $2 по $3 включительно (даты UTC),
+// кроме удаленных в корзину.
+const scopedRequestsCTE = `
+	scoped AS (
+		SELECT r.id, r.partner_id, r.end_client_id, r.status, r.created_at, r.withdrawn_at,
+		       COALESCE(r.total_price_base, 0) AS value
+		FROM requests r
+		JOIN partners p ON p.id = r.partner_id
+		WHERE p.assigned_manager_id = $1
+		  AND r.deleted_at IS NULL
+		  AND (r.created_at AT TIME ZONE 'UTC')::date BETWEEN $2::date AND $3::date
+	)`
+
+// decisionsCTE — первое решение (одобрение $4 или отклонение $5) по каждой заявке из scoped.
+const decisionsCTE = `
+	decisions AS (
+		SELECT DISTINCT ON (e.request_id)
+		       e.request_id, e.new_status::text = ANY($4) AS approved, e.changed_at, e.changed_at - s.created_at AS took
+		FROM request_status_events e
+		JOIN scoped s ON s.id = e.request_id
+		WHERE e.new_status::text = ANY($4) OR e.new_status::text = ANY($5)
+		ORDER BY e.request_id, e.changed_at, e.id
+	)`
+
+// GetManagerStats собирает сводку по заявкам партнеров менеджера, созданным с from по to включительно.
+// top — размер рейтингов партнеров и конечных клиентов. Все запросы читают один снимок данных.
+func (repo *RequestRepository) GetManagerStats(ctx context.Context, managerID int, from, to time.Time, top int) (*models.ManagerStats, error) {
+	tx, err := repo.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	stats := &models.ManagerStats{From: from, To: to}
+	approved, rejected := workflow.Spellings(models.StatusApproved), workflow.Spellings(models.StatusRejected)
+	args := []any{managerID, from, to, approved, rejected}
+
+	if err := statsByStatus(ctx, tx, args[:3], stats); err != nil {
+		return nil, err
+	}
+
+	// Решения и медиана времени до решения
+	err = tx.QueryRow(ctx, `
+		WITH `+scopedRequestsCTE+`, `+decisionsCTE+`
+		SELECT COUNT(*) FILTER (WHERE approved), COUNT(*) FILTER (WHERE NOT approved),
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM took)) / 3600
+		FROM decisions`, args...,
+	).Scan(&stats.Decisions.Approved, &stats.Decisions.Rejected, &stats.Decisions.MedianDecisionHours)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query decision stats: %w", err)
+	}
+	if total := stats.Decisions.Approved + stats.Decisions.Rejected; total > 0 {
+		rate := float64(stats.Decisions.Approved) / float64(total)
+		stats.Decisions.ApprovalRate = &rate
+	}
+
+	if stats.TopPartners, err = rankedValues(ctx, tx, `
+		WITH `+scopedRequestsCTE+`
+		SELECT p.id, p.name, COUNT(*), SUM(s.value)
+		FROM scoped s
+		JOIN partners p ON p.id = s.partner_id
+		WHERE s.withdrawn_at IS NULL
+		GROUP BY p.id, p.name
+		ORDER BY SUM(s.value) DESC, COUNT(*) DESC, p.id
+		LIMIT $4`, managerID, from, to, top); err != nil {
+		return nil, fmt.Errorf("failed to query top partners: %w", err)
+	}
+	if stats.TopEndClients, err = rankedValues(ctx, tx, `
+		WITH `+scopedRequestsCTE+`
+		SELECT ec.id, ec.name, COUNT(*), SUM(s.value)
+		FROM scoped s
+		JOIN end_clients ec ON ec.id = s.end_client_id
+		WHERE s.withdrawn_at IS NULL
+		GROUP BY ec.id, ec.name
+		ORDER BY SUM(s.value) DESC, COUNT(*) DESC, ec.id
+		LIMIT $4`, managerID, from, to, top); err != nil {
+		return nil, fmt.Errorf("failed to query top end clients: %w", err)
+	}
+
+	if stats.Weekly, err = weeklyStats(ctx, tx, args); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// statsByStatus заполняет число заявок и суммы по статусам (все статусы, включая пустые)
+// и итоги по неотозванным заявкам. Устаревшие синонимы статусов учитываются в основном статусе.
+func statsByStatus(ctx context.Context, tx pgx.Tx, args []any, stats *models.ManagerStats) error {
+	rows, err := tx.Query(ctx, `
+		WITH `+scopedRequestsCTE+`
+		SELECT status::text, withdrawn_at IS NOT NULL, COUNT(*), SUM(value)
+		FROM scoped
+		GROUP BY 1, 2`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query stats by status: %w", err)
+	}
+	defer rows.Close()
+
+	byStatus := map[models.RequestStatus]*models.StatusStats{}
+	for _, status := range workflow.Statuses() {
+		stats.ByStatus = append(stats.ByStatus, models.StatusStats{Status: status, Value: decimal.Zero})
+	}
+	for i := range stats.ByStatus {
+		byStatus[stats.ByStatus[i].Status] = &stats.ByStatus[i]
+	}
+	for rows.Next() {
+		var raw string
+		var withdrawn bool
+		var count int64
+		var value decimal.Decimal
+		if err := rows.Scan(&raw, &withdrawn, &count, &value); err != nil {
+			return fmt.Errorf("failed to scan stats by status: %w", err)
+		}
+		if withdrawn {
+			stats.Withdrawn += count
+			continue
+		}
+		status, ok := workflow.Parse(raw)
+		if !ok {
+			continue
+		}
+		byStatus[status].Count += count
+		byStatus[status].Value = byStatus[status].Value.Add(value)
+		stats.TotalRequests += count
+		stats.PipelineValue = stats.PipelineValue.Add(value)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating stats by status: %w", err)
+	}
+	return nil
+}
+
+func rankedValues(ctx context.Context, tx pgx.Tx, query string, args ...any) ([]models.RankedValue, error) {
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranked := []models.RankedValue{}
+	for rows.Next() {
+		var v models.RankedValue
+		if err := rows.Scan(&v.ID, &v.Name, &v.Count, &v.Value); err != nil {
+			return nil, err
+		}
+		ranked = append(ranked, v)
+	}
+	return ranked, rows.Err()
+}
+
+// weeklyStats возвращает по каждой неделе периода новые заявки с их суммой и первые решения,
+// принятые в эту неделю по заявкам периода.
+func weeklyStats(ctx context.Context, tx pgx.Tx, args []any) ([]models.WeeklyStats, error) {
+	rows, err := tx.Query(ctx, `
+		WITH `+scopedRequestsCTE+`, `+decisionsCTE+`,
+		weeks AS (
+			SELECT generate_series(date_trunc('week', $2::date), date_trunc('week', $3::date), interval '1 week')::date AS week_start
+		)
+		SELECT w.week_start,
+		       (SELECT COUNT(*) FROM scoped s
+		         WHERE date_trunc('week', s.created_at AT TIME ZONE 'UTC')::date = w.week_start),
+		       (SELECT COALESCE(SUM(s.value), 0) FROM scoped s
+		         WHERE s.withdrawn_at IS NULL AND date_trunc('week', s.created_at AT TIME ZONE 'UTC')::date = w.week_start),
+		       (SELECT COUNT(*) FROM decisions d
+		         WHERE d.approved AND date_trunc('week', d.changed_at AT TIME ZONE 'UTC')::date = w.week_start),
+		       (SELECT COUNT(*) FROM decisions d
+		         WHERE NOT d.approved AND date_trunc('week', d.changed_at AT TIME ZONE 'UTC')::date = w.week_start)
+		FROM weeks w
+		ORDER BY w.week_start`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query weekly stats: %w", err)
+	}
+	defer rows.Close()
+
+	weekly := []models.WeeklyStats{}
+	for rows.Next() {
+		var week models.WeeklyStats
+		if err := rows.Scan(&week.WeekStart, &week.Created, &week.Value, &week.Approved, &week.Rejected); err != nil {
+			return nil, fmt.Errorf("failed to scan weekly stats: %w", err)
+		}
+		weekly = append(weekly, week)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating weekly stats: %w", err)
+	}
+	return weekly, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// insertStatusEvent записывает событие в историю статусов в рамках переданной транзакции.
+// oldStatus == nil означает событие создания заявки, managerID == nil — изменение не менеджером.
+func insertStatusEvent(
+	ctx context.Context,
+	tx pgx.Tx,
+	requestID int,
+	oldStatus *models.RequestStatus,
+	newStatus models.RequestStatus,
+	managerID *int,
+	comment *string,
+) error {
+	query := `
+		INSERT INTO request_status_events (request_id, old_status, new_status, manager_id, comment)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := tx.Exec(ctx, query, requestID, oldStatus, newStatus, managerID, comment); err != nil {
+		log.Printf("Error inserting status event for request %d: %v", requestID, err)
+		return fmt.Errorf("failed to insert status event: %w", err)
+	}
+	return nil
+}
+
+// ListStatusEvents возвращает историю изменения статусов заявки в хронологическом порядке.
+func (repo *RequestRepository) ListStatusEvents(ctx context.Context, requestID int) ([]models.StatusEvent, error) {
+	query := `
+		SELECT
+			e.id, e.request_id, e.old_status, e.new_status, e.changed_at, e.comment,
+			e.manager_id, u.name as manager_name
+		FROM request_status_events e
+		LEFT JOIN users u ON e.manager_id = u.id
+		WHERE e.request_id = $1
+		ORDER BY e.changed_at ASC, e.id ASC
+	`
+	rows, err := repo.pool.Query(ctx, query, requestID)
+	if err != nil {
+		log.Printf("Error listing status events for request %d: %v", requestID, err)
+		return nil, fmt.Errorf("failed to list status events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.StatusEvent{}
+	for rows.Next() {
+		var event models.StatusEvent
+		if err := rows.Scan(
+			&event.ID, &event.RequestID, &event.OldStatus, &event.Status, &event.ChangedAt, &event.Comment,
+			&event.ManagerID, &event.ManagerName,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan status event row: %w", err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating status event rows: %w", err)
+	}
+	return events, nil
+}
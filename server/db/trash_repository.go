@@ -0,0 +1,182 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// ListDeletedRequestsForManager возвращает заявки из корзины для партнеров, закрепленных за менеджером.
+// Сортировка по времени удаления, свежие сверху.
+func (repo *RequestRepository) ListDeletedRequestsForManager(ctx context.Context, managerID int, limit, offset int) ([]models.Request, int64, error) {
+	baseQuery := `
+		FROM requests r
+		LEFT JOIN users u ON r.partner_user_id = u.id
+		JOIN partners p ON r.partner_id = p.id
+		LEFT JOIN end_clients ec ON r.end_client_id = ec.id
+		WHERE p.assigned_manager_id = $1 AND r.deleted_at IS NOT NULL
+	`
+
+	var total int64
+	err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) "+baseQuery, managerID).Scan(&total)
+	if err != nil {
+		log.Printf("Error counting deleted requests for manager %d: %v", managerID, err)
+		return nil, 0, fmt.Errorf("failed to count deleted requests: %w", err)
+	}
+	if total == 0 {
+		return []models.Request{}, 0, nil
+	}
+
+	dataQuery := `
+		SELECT
+			r.id, r.created_at, r.status, r.project_name,
+			p.id as partner_id, p.name as partner_name,
+			u.id as user_id, u.name as user_name,
+			ec.id as client_id, ec.name as client_name,
+			r.end_client_details_override,
+			r.deleted_at, r.deleted_by
+	` + baseQuery + `
+		ORDER BY r.deleted_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := repo.pool.Query(ctx, dataQuery, managerID, limit, offset)
+	if err != nil {
+		log.Printf("Error listing deleted requests for manager %d: %v", managerID, err)
+		return nil, 0, fmt.Errorf("failed to list deleted requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := make([]models.Request, 0, limit)
+	for rows.Next() {
+		var req models.Request
+		var partner models.Partner
+		var user models.User
+		var clientName, endClientDetailsOverride sql.NullString
+		var clientID sql.NullInt64
+
+		err := rows.Scan(
+			&req.ID, &req.CreatedAt, &req.Status, &req.ProjectName,
+			&partner.ID, &partner.Name,
+			&user.ID, &user.Name,
+			&clientID, &clientName,
+			&endClientDetailsOverride,
+			&req.DeletedAt, &req.DeletedBy,
+		)
+		if err != nil {
+			log.Printf("Error scanning deleted request row for manager %d: %v", managerID, err)
+			continue
+		}
+
+		req.Partner = &partner
+		req.User = &user
+		if clientID.Valid {
+			req.EndClient = &models.EndClient{ID: int(clientID.Int64), Name: clientName.String}
+		}
+		if endClientDetailsOverride.Valid {
+			req.EndClientDetailsOverride = &endClientDetailsOverride.String
+		}
+
+		requests = append(requests, req)
+	}
+
+	if err = rows.Err(); err != nil {
+		log.Printf("Error iterating over deleted request rows for manager %d: %v", managerID, err)
+		return nil, 0, fmt.Errorf("failed to process deleted request rows: %w", err)
+	}
+
+	return requests, total, nil
+}
+
+// RestoreDeletedRequest возвращает заявку из корзины. Восстановление фиксируется в истории статусов.
+func (repo *RequestRepository) RestoreDeletedRequest(ctx context.Context, requestID int, managerID int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var status models.RequestStatus
+	var deletedAt *time.Time
+	err = tx.QueryRow(ctx, "SELECT status, deleted_at FROM requests WHERE id = $1 FOR UPDATE", requestID).Scan(&status, &deletedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock request: %w", err)
+	}
+	if deletedAt == nil {
+		return fmt.Errorf("%w: request is not deleted", ErrInvalidState)
+	}
+	before := requestSnapshot(ctx, tx, requestID)
+
+	_, err = tx.Exec(ctx, "UPDATE requests SET deleted_at = NULL, deleted_by = NULL WHERE id = $1", requestID)
+	if err != nil {
+		log.Printf("Error restoring deleted request %d: %v", requestID, err)
+		return fmt.Errorf("failed to restore request: %w", err)
+	}
+
+	comment := "Заявка восстановлена из корзины"
+	if err := insertStatusEvent(ctx, tx, requestID, &status, status, &managerID, &comment); err != nil {
+		return err
+	}
+	if err := refreshLaterConflicts(ctx, tx, requestID); err != nil {
+		return err
+	}
+
+	after := requestSnapshot(ctx, tx, requestID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request", requestID, before, after)
+	return nil
+}
+
+// PurgeDeletedRequests окончательно удаляет заявки, пролежавшие в корзине дольше retention,
+// и файлы, которые после этого ни к чему не привязаны. Возвращает число удаленных заявок и файлов.
+func (repo *RequestRepository) PurgeDeletedRequests(ctx context.Context, retention time.Duration) (int64, int64, error) {
+	cutoff := time.Now().Add(-retention)
+
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Связи в request_files и история статусов удаляются каскадно
+	tag, err := tx.Exec(ctx, "DELETE FROM requests WHERE deleted_at IS NOT NULL AND deleted_at < $1", cutoff)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to purge deleted requests: %w", err)
+	}
+	purgedRequests := tag.RowsAffected()
+
+	// Удаляем осиротевшие файлы. Свежие файлы не трогаем: они могли быть
+	// загружены только что и еще не привязаны к заявке.
+	rows, err := tx.Query(ctx, `
+		DELETE FROM files f
+		WHERE f.created_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM `+requestFileLinks+` rf WHERE rf.file_id = f.id)
+		RETURNING f.storage_key
+	`, cutoff)
+	var deletedKeys []string
+	if err == nil {
+		deletedKeys, err = collectStorageKeys(rows)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to purge orphaned files: %w", err)
+	}
+	purgedFiles := rows.CommandTag().RowsAffected()
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	// Содержимое удаляем после фиксации: при откате транзакции файлы должны остаться читаемыми
+	repo.deleteBlobs(ctx, deletedKeys)
+	return purgedRequests, purgedFiles, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 
+	"github.com/eeephemera/zvk-requests/server/audit"
 	"github.com/eeephemera/zvk-requests/server/models"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -23,6 +25,15 @@ func NewUserRepository(pool *pgxpool.Pool) *UserRepository {
 // CreateUser вставляет нового пользователя в таблицу users.
 // Важно: Хеширование пароля должно происходить *перед* вызовом этой функции.
 func (repo *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	return insertUser(ctx, repo.pool, user)
+}
+
+// rowQuerier — общий интерфейс пула и транзакции для запросов, возвращающих одну строку.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+func insertUser(ctx context.Context, q rowQuerier, user *models.User) error {
 	query := `
 		INSERT INTO users (
 			login, password_hash, role, partner_id, name, email, phone
@@ -30,7 +41,7 @@ func (repo *UserRepository) CreateUser(ctx context.Context, user *models.User) e
 		RETURNING id, created_at
 	`
 	// pgx/v5 автоматически обработает кастомный строковый тип user.Role
-	err := repo.pool.QueryRow(ctx, query,
+	err := q.QueryRow(ctx, query,
 		user.Login, user.PasswordHash, user.Role, user.PartnerID,
 		user.Name, user.Email, user.Phone,
 	).Scan(&user.ID, &user.CreatedAt)
@@ -112,6 +123,180 @@ func (repo *UserRepository) UpdatePasswordHash(ctx context.Context, userID int,
 	return nil
 }
 
-// TODO: Добавить методы ListUsers, UpdateUser, DeleteUser по необходимости.
-// TODO: Возможно, добавить метод для получения пользователя вместе с деталями партнера (JOIN).
-// func (repo *UserRepository) GetUserWithPartnerDetails(ctx context.Context, id int) (*models.User, error) { ... }
+// UserFilter — условия выборки пользователей для администрирования. Пустые поля не фильтруют.
+type UserFilter struct {
+	Role      models.UserRole
+	PartnerID *int
+	Search    string // Подстрока логина, имени или email
+}
+
+// ListUsers возвращает пользователей по фильтру с пагинацией и их общее количество.
+func (repo *UserRepository) ListUsers(ctx context.Context, filter UserFilter, limit, offset int) ([]models.User, int64, error) {
+	whereClauses := []string{}
+	args := []interface{}{}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		whereClauses = append(whereClauses, fmt.Sprintf("role = $%d", len(args)))
+	}
+	if filter.PartnerID != nil {
+		args = append(args, *filter.PartnerID)
+		whereClauses = append(whereClauses, fmt.Sprintf("partner_id = $%d", len(args)))
+	}
+	if filter.Search != "" {
+		args = append(args, "%"+filter.Search+"%")
+		whereClauses = append(whereClauses, fmt.Sprintf("(login ILIKE $%d OR name ILIKE $%d OR email ILIKE $%d)", len(args), len(args), len(args)))
+	}
+	whereQuery := ""
+	if len(whereClauses) > 0 {
+		whereQuery = "WHERE " + strings.Join(whereClauses, " AND ")
+	}
+
+	var total int64
+	if err := repo.pool.QueryRow(ctx, "SELECT COUNT(*) FROM users "+whereQuery, args...).Scan(&total); err != nil {
+		log.Printf("Error counting users: %v", err)
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+	if total == 0 {
+		return []models.User{}, 0, nil
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, login, role, partner_id, name, email, phone, created_at
+		FROM users
+		%s
+		ORDER BY login ASC
+		LIMIT $%d OFFSET $%d
+	`, whereQuery, len(args)+1, len(args)+2)
+	args = append(args, limit, offset)
+
+	rows, err := repo.pool.Query(ctx, query, args...)
+	if err != nil {
+		log.Printf("Error listing users: %v", err)
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0, limit)
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(
+			&user.ID, &user.Login, &user.Role, &user.PartnerID,
+			&user.Name, &user.Email, &user.Phone, &user.CreatedAt,
+		); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user row: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating user rows: %w", err)
+	}
+	return users, total, nil
+}
+
+// UpdateUser обновляет профиль, роль и привязку пользователя к партнеру, а если задан
+// user.PasswordHash — и пароль, в одной транзакции и одной записью аудита.
+// Снять роль MANAGER нельзя, пока менеджер назначен ответственным за партнеров.
+func (repo *UserRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var currentRole models.UserRole
+	err = tx.QueryRow(ctx, "SELECT role FROM users WHERE id = $1 FOR UPDATE", user.ID).Scan(&currentRole)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if currentRole == models.RoleManager && user.Role != models.RoleManager {
+		var assigned bool
+		err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM partners WHERE assigned_manager_id = $1)", user.ID).Scan(&assigned)
+		if err != nil {
+			return fmt.Errorf("failed to check manager assignments: %w", err)
+		}
+		if assigned {
+			return fmt.Errorf("%w: manager is assigned to partners", ErrInvalidState)
+		}
+	}
+
+	before := userSnapshot(ctx, tx, user.ID)
+
+	query := `
+		UPDATE users
+		SET role = $1, partner_id = $2, name = $3, email = $4, phone = $5,
+		    password_hash = COALESCE(NULLIF($6, ''), password_hash)
+		WHERE id = $7
+		RETURNING login, created_at
+	`
+	err = tx.QueryRow(ctx, query,
+		user.Role, user.PartnerID, user.Name, user.Email, user.Phone, user.PasswordHash, user.ID,
+	).Scan(&user.Login, &user.CreatedAt)
+	if err != nil {
+		log.Printf("Error updating user %d: %v", user.ID, err)
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	after := userSnapshot(ctx, tx, user.ID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	// Хеш в снимок не попадает, поэтому смену пароля отмечаем явно
+	if after != nil && user.PasswordHash != "" {
+		after["password_changed"] = true
+	}
+	audit.RecordChange(ctx, "user", user.ID, before, after)
+	return nil
+}
+
+// DeleteUser удаляет пользователя. Удаление каскадно уничтожило бы его заявки,
+// поэтому пользователя с заявками удалить нельзя.
+func (repo *UserRepository) DeleteUser(ctx context.Context, id int) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var hasRequests bool
+	err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM requests WHERE partner_user_id = $1)", id).Scan(&hasRequests)
+	if err != nil {
+		return fmt.Errorf("failed to check user requests: %w", err)
+	}
+	if hasRequests {
+		return fmt.Errorf("%w: user has requests", ErrInvalidState)
+	}
+
+	before := userSnapshot(ctx, tx, id)
+	tag, err := tx.Exec(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		log.Printf("Error deleting user %d: %v", id, err)
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "user", id, before, nil)
+	return nil
+}
+
+// PromoteToAdminIfNone назначает роль ADMIN пользователю с указанным логином,
+// если в системе еще нет ни одного администратора. Возвращает true, если роль назначена.
+func (repo *UserRepository) PromoteToAdminIfNone(ctx context.Context, login string) (bool, error) {
+	query := `
+		UPDATE users SET role = 'ADMIN'
+		WHERE login = $1
+		  AND NOT EXISTS (SELECT 1 FROM users WHERE role = 'ADMIN')
+	`
+	tag, err := repo.pool.Exec(ctx, query, login)
+	if err != nil {
+		return false, fmt.Errorf("failed to promote user to admin: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/jackc/pgx/v5"
+)
+
+// WithdrawRequest помечает заявку отозванной её создателем (мягкое удаление).
+// Отозвать можно только активную заявку в статусе "На рассмотрении".
+// Отзыв фиксируется в истории статусов.
+func (repo *RequestRepository) WithdrawRequest(ctx context.Context, requestID, userID int, reason string) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, withdrawnAt, err := lockOwnRequest(ctx, tx, requestID, userID)
+	if err != nil {
+		return err
+	}
+	if withdrawnAt != nil {
+		return fmt.Errorf("%w: request is already withdrawn", ErrInvalidState)
+	}
+	if status != models.StatusPending {
+		return fmt.Errorf("%w: only requests in status %q can be withdrawn", ErrInvalidState, models.StatusPending)
+	}
+	before := requestSnapshot(ctx, tx, requestID)
+
+	_, err = tx.Exec(ctx,
+		"UPDATE requests SET withdrawn_at = NOW(), withdrawal_reason = $1, updated_at = NOW() WHERE id = $2",
+		reason, requestID,
+	)
+	if err != nil {
+		log.Printf("Error withdrawing request %d: %v", requestID, err)
+		return fmt.Errorf("failed to withdraw request: %w", err)
+	}
+
+	comment := "Заявка отозвана партнером: " + reason
+	if err := insertStatusEvent(ctx, tx, requestID, &status, status, nil, &comment); err != nil {
+		return err
+	}
+	if err := refreshLaterConflicts(ctx, tx, requestID); err != nil {
+		return err
+	}
+
+	after := requestSnapshot(ctx, tx, requestID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request", requestID, before, after)
+	return nil
+}
+
+// RestoreWithdrawnRequest снимает отметку об отзыве, если с момента отзыва
+// прошло не больше gracePeriod. Восстановление фиксируется в истории статусов.
+func (repo *RequestRepository) RestoreWithdrawnRequest(ctx context.Context, requestID, userID int, gracePeriod time.Duration) error {
+	tx, err := repo.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status, withdrawnAt, err := lockOwnRequest(ctx, tx, requestID, userID)
+	if err != nil {
+		return err
+	}
+	if withdrawnAt == nil {
+		return fmt.Errorf("%w: request is not withdrawn", ErrInvalidState)
+	}
+	if time.Since(*withdrawnAt) > gracePeriod {
+		return fmt.Errorf("%w: restore period has expired", ErrInvalidState)
+	}
+	before := requestSnapshot(ctx, tx, requestID)
+
+	_, err = tx.Exec(ctx,
+		"UPDATE requests SET withdrawn_at = NULL, withdrawal_reason = NULL, updated_at = NOW() WHERE id = $1",
+		requestID,
+	)
+	if err != nil {
+		log.Printf("Error restoring withdrawn request %d: %v", requestID, err)
+		return fmt.Errorf("failed to restore request: %w", err)
+	}
+
+	comment := "Отзыв заявки отменен партнером"
+	if err := insertStatusEvent(ctx, tx, requestID, &status, status, nil, &comment); err != nil {
+		return err
+	}
+	if err := refreshLaterConflicts(ctx, tx, requestID); err != nil {
+		return err
+	}
+
+	after := requestSnapshot(ctx, tx, requestID)
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	audit.RecordChange(ctx, "request", requestID, before, after)
+	return nil
+}
+
+// lockOwnRequest блокирует заявку пользователя и возвращает её статус и момент отзыва.
+// Удаленные менеджером заявки считаются ненайденными.
+func lockOwnRequest(ctx context.Context, tx pgx.Tx, requestID, userID int) (models.RequestStatus, *time.Time, error) {
+	var status models.RequestStatus
+	var withdrawnAt *time.Time
+	err := tx.QueryRow(ctx,
+		"SELECT status, withdrawn_at FROM requests WHERE id = $1 AND partner_user_id = $2 AND deleted_at IS NULL FOR UPDATE",
+		requestID, userID,
+	).Scan(&status, &withdrawnAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return "", nil, ErrNotFound
+		}
+		log.Printf("Error locking request %d for user %d: %v", requestID, userID, err)
+		return "", nil, fmt.Errorf("failed to lock request: %w", err)
+	}
+	return status, withdrawnAt, nil
+}
@@ -0,0 +1,142 @@
+// Package export записывает табличные выгрузки в CSV и XLSX построчно, не накапливая
+// строки в памяти: таблицы заявок менеджера могут быть большими.
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Format — формат файла выгрузки.
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// ParseFormat разбирает значение параметра format; пустая строка означает CSV.
+func ParseFormat(s string) (Format, bool) {
+	switch Format(strings.ToLower(strings.TrimSpace(s))) {
+	case "", FormatCSV:
+		return FormatCSV, true
+	case FormatXLSX:
+		return FormatXLSX, true
+	}
+	return "", false
+}
+
+// ContentType возвращает MIME-тип файла выгрузки.
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+type cellKind int
+
+const (
+	kindText cellKind = iota
+	kindNumber
+	kindDate
+)
+
+// Cell — значение ячейки. Пустая ячейка — нулевое значение Cell.
+type Cell struct {
+	kind   cellKind
+	text   string
+	number decimal.Decimal
+	date   time.Time
+}
+
+// Text возвращает текстовую ячейку.
+func Text(s string) Cell {
+	return Cell{kind: kindText, text: s}
+}
+
+// Number возвращает числовую ячейку.
+func Number(d decimal.Decimal) Cell {
+	return Cell{kind: kindNumber, number: d}
+}
+
+// Date возвращает ячейку с датой (без времени).
+func Date(t time.Time) Cell {
+	return Cell{kind: kindDate, date: t}
+}
+
+func (c Cell) empty() bool {
+	return c.kind == kindText && c.text == ""
+}
+
+// Writer записывает строки таблицы. Close дописывает окончание файла и должен быть вызван
+// после последней строки; закрывать нижележащий io.Writer он не будет.
+type Writer interface {
+	WriteRow(cells []Cell) error
+	Close() error
+}
+
+// NewWriter возвращает Writer для формата f с листом sheet (используется только в XLSX).
+func NewWriter(f Format, w io.Writer, sheet string) (Writer, error) {
+	if f == FormatXLSX {
+		return newXLSXWriter(w, sheet)
+	}
+	return newCSVWriter(w)
+}
+
+// csvWriter пишет CSV так, как его открывает Excel с русской локалью: UTF-8 с BOM
+// (иначе кириллица читается как cp1251), разделитель — точка с запятой, десятичная запятая.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	if _, err := io.WriteString(w, "\ufeff"); err != nil {
+		return nil, err
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = ';'
+	cw.UseCRLF = true
+	return &csvWriter{w: cw}, nil
+}
+
+func (c *csvWriter) WriteRow(cells []Cell) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch cell.kind {
+		case kindNumber:
+			record[i] = strings.Replace(cell.number.String(), ".", ",", 1)
+		case kindDate:
+			record[i] = cell.date.Format("02.01.2006")
+		default:
+			record[i] = neutralizeFormula(cell.text)
+		}
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// neutralizeFormula экранирует апострофом текст, который Excel принял бы за формулу
+// (CSV injection). Телефоны и числа со знаком не трогаем.
+func neutralizeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '@', '\t', '\r':
+		return "'" + s
+	case '+', '-':
+		if strings.Trim(s[1:], "0123456789 ()-.,") != "" {
+			return "'" + s
+		}
+	}
+	return s
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Format
+		ok   bool
+	}{
+		{"", FormatCSV, true},
+		{"csv", FormatCSV, true},
+		{" XLSX ", FormatXLSX, true},
+		{"pdf", "", false},
+	}
+	for _, tc := range tests {
+		got, ok := ParseFormat(tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatCSV, &buf, "")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	rows := [][]Cell{
+		{Text("Клиент"), Text("Сумма"), Text("Дата")},
+		{Text("ООО \"Ромашка\"; филиал"), Number(decimal.RequireFromString("1500.50")), Date(time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC))},
+		{Text("=HYPERLINK(\"x\")"), Text("+7 (495) 123-45-67"), Cell{}},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("WriteRow: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want := "\ufeffКлиент;Сумма;Дата\r\n" +
+		"\"ООО \"\"Ромашка\"\"; филиал\";1500,5;05.03.2026\r\n" +
+		"\"'=HYPERLINK(\"\"x\"\")\";+7 (495) 123-45-67;\r\n"
+	if got := buf.String(); got != want {
+		t.Errorf("CSV output:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(FormatXLSX, &buf, "Заявки")
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteRow([]Cell{Text("Клиент"), Text("Сумма"), Text("Дата")}); err != nil {
+		t.Fatalf("WriteRow: %v", err)
+	}
+	if err := w.WriteRow([]Cell{Text("Завод <№1> & Ко\x01"), Number(decimal.RequireFromString("1500.50")), Date(time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC))}); err != nil {
+		t.Fatalf("WriteRow: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("result is not a zip archive: %v", err)
+	}
+	parts := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		parts[f.Name] = string(data)
+		// Каждая часть книги должна быть корректным XML
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			if _, err := dec.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s is not valid XML: %v", f.Name, err)
+			}
+		}
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("missing part %s", name)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Заявки"`) {
+		t.Errorf("sheet name not set: %s", parts["xl/workbook.xml"])
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A1" s="2" t="inlineStr"><is><t xml:space="preserve">Клиент</t></is></c>`,
+		`<c r="A2" t="inlineStr"><is><t xml:space="preserve">Завод &lt;№1&gt; &amp; Ко</t></is></c>`,
+		`<c r="B2"><v>1500.5</v></c>`,
+		`<c r="C2" s="1"><v>46086</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet does not contain %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestRequestRow(t *testing.T) {
+	project := "Поставка серверов"
+	inn := "7707083893"
+	total := decimal.RequireFromString("1200000")
+	req := &models.Request{
+		ID:          12,
+		Status:      models.StatusApproved,
+		ProjectName: &project,
+		Currency:    "RUB",
+		TotalPrice:  &total,
+		Partner:     &models.Partner{Name: "Интегратор", INN: &inn},
+		EndClient:   &models.EndClient{Name: "Завод"},
+	}
+	row := RequestRow(req)
+	if len(row) != len(RequestColumns) {
+		t.Fatalf("RequestRow has %d cells, header has %d", len(row), len(RequestColumns))
+	}
+	cell := func(column string) Cell {
+		for i, name := range RequestColumns {
+			if name == column {
+				return row[i]
+			}
+		}
+		t.Fatalf("unknown column %q", column)
+		return Cell{}
+	}
+	if c := cell("ID"); c.kind != kindNumber || c.number.IntPart() != 12 {
+		t.Errorf("ID = %+v", c)
+	}
+	if c := cell("Партнер"); c.text != "Интегратор" {
+		t.Errorf("Партнер = %+v", c)
+	}
+	if c := cell("ИНН партнера"); c.text != inn {
+		t.Errorf("ИНН партнера = %+v", c)
+	}
+	if c := cell("Сумма"); c.kind != kindNumber || !c.number.Equal(total) {
+		t.Errorf("Сумма = %+v", c)
+	}
+	if c := cell("Сумма, RUB"); !c.empty() {
+		t.Errorf("Сумма, RUB should be empty, got %+v", c)
+	}
+	if c := cell("Дистрибьютор"); !c.empty() {
+		t.Errorf("Дистрибьютор should be empty, got %+v", c)
+	}
+}
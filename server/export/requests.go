@@ -0,0 +1,85 @@
+package export
+
+import (
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+// RequestColumns — заголовок выгрузки заявок; порядок совпадает с RequestRow.
+var RequestColumns = []string{
+	"ID", "Дата создания", "Дата изменения", "Статус",
+	"Партнер", "ИНН партнера", "Сотрудник партнера", "Email", "Телефон", "Контакт партнера",
+	"Конечный клиент", "ИНН клиента", "Город клиента", "Данные клиента (вручную)", "Дистрибьютор",
+	"Проект", "ФЗ", "Реестр Минпромторга", "Активности партнера", "Состояние сделки",
+	"Ожидаемая дата закрытия", "Защита до", "Комментарий менеджера", "Отозвана", "Конфликт",
+	"Валюта", "Сумма", "Курс", "Сумма, RUB",
+}
+
+// RequestHeader возвращает строку заголовка выгрузки заявок.
+func RequestHeader() []Cell {
+	cells := make([]Cell, len(RequestColumns))
+	for i, name := range RequestColumns {
+		cells[i] = Text(name)
+	}
+	return cells
+}
+
+// RequestRow возвращает строку выгрузки для заявки со связанными партнером, сотрудником,
+// конечным клиентом и дистрибьютором (если они загружены).
+func RequestRow(req *models.Request) []Cell {
+	var partnerName, partnerINN string
+	if req.Partner != nil {
+		partnerName, partnerINN = req.Partner.Name, str(req.Partner.INN)
+	}
+	var userName, userEmail, userPhone string
+	if req.User != nil {
+		userName, userEmail, userPhone = str(req.User.Name), str(req.User.Email), str(req.User.Phone)
+	}
+	var clientName, clientINN, clientCity string
+	if req.EndClient != nil {
+		clientName, clientINN, clientCity = req.EndClient.Name, str(req.EndClient.INN), str(req.EndClient.City)
+	}
+	var distributorName string
+	if req.Distributor != nil {
+		distributorName = req.Distributor.Name
+	}
+
+	return []Cell{
+		Number(decimal.NewFromInt(int64(req.ID))), Date(req.CreatedAt), Date(req.UpdatedAt), Text(string(req.Status)),
+		Text(partnerName), Text(partnerINN), Text(userName), Text(userEmail), Text(userPhone), Text(str(req.PartnerContactOverride)),
+		Text(clientName), Text(clientINN), Text(clientCity), Text(str(req.EndClientDetailsOverride)), Text(distributorName),
+		Text(str(req.ProjectName)), Text(str(req.FZLawType)), Text(str(req.MPTRegistryType)), Text(str(req.PartnerActivities)), Text(str(req.DealStateDescription)),
+		optDate(req.EstimatedCloseDate), optDate(req.ProtectedUntil), Text(str(req.ManagerComment)), yesNo(req.Withdrawn), yesNo(req.IsConflicting),
+		Text(req.Currency), optNumber(req.TotalPrice), optNumber(req.ExchangeRate), optNumber(req.TotalPriceBase),
+	}
+}
+
+func str(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optDate(t *time.Time) Cell {
+	if t == nil {
+		return Cell{}
+	}
+	return Date(*t)
+}
+
+func optNumber(d *decimal.Decimal) Cell {
+	if d == nil {
+		return Cell{}
+	}
+	return Number(*d)
+}
+
+func yesNo(b bool) Cell {
+	if b {
+		return Text("Да")
+	}
+	return Text("Нет")
+}
@@ -5,8 +5,10 @@ go 1.24.0
 toolchain go1.24.2
 
 require (
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/jackc/pgx/v5 v5.7.6
 	github.com/shopspring/decimal v1.4.0
+	golang.org/x/image v0.32.0
 )
 
 require (
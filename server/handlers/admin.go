@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/utils"
+	"github.com/gorilla/mux"
+)
+
+// AdminHandler - обработчик запросов администратора: пользователи, партнеры и назначения менеджеров
+type AdminHandler struct {
+	UserRepo    *db.UserRepository
+	PartnerRepo *db.PartnerRepository
+}
+
+// NewAdminHandler создает новый экземпляр AdminHandler
+func NewAdminHandler(userRepo *db.UserRepository, partnerRepo *db.PartnerRepository) *AdminHandler {
+	return &AdminHandler{
+		UserRepo:    userRepo,
+		PartnerRepo: partnerRepo,
+	}
+}
+
+// adminUserRequest - тело запросов создания и изменения пользователя.
+// Пароль при изменении необязателен: пустое значение оставляет текущий.
+type adminUserRequest struct {
+	Login     string          `json:"login"`
+	Password  string          `json:"password"`
+	Role      models.UserRole `json:"role"`
+	PartnerID *int            `json:"partner_id"`
+	Name      *string         `json:"name"`
+	Email     *string         `json:"email"`
+	Phone     *string         `json:"phone"`
+}
+
+// adminPartnerRequest - тело запросов администратора: реквизиты партнера и ответственный менеджер
+type adminPartnerRequest struct {
+	partnerRequest
+	AssignedManagerID *int `json:"assigned_manager_id"`
+}
+
+// ListUsersHandler обрабатывает запрос GET /api/admin/users
+// Фильтры: role, partner_id, search (логин, имя или email).
+func (h *AdminHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+
+	filter := db.UserFilter{
+		Role:   models.UserRole(strings.ToUpper(query.Get("role"))),
+		Search: strings.TrimSpace(query.Get("search")),
+	}
+	if filter.Role != "" && !filter.Role.IsValid() {
+		RespondWithError(w, http.StatusBadRequest, "Invalid role")
+		return
+	}
+	if partnerStr := query.Get("partner_id"); partnerStr != "" {
+		partnerID, err := strconv.Atoi(partnerStr)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid partner_id")
+			return
+		}
+		filter.PartnerID = &partnerID
+	}
+
+	users, total, err := h.UserRepo.ListUsers(r.Context(), filter, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListUsersHandler: Error listing users: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch users")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: users,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetUserHandler обрабатывает запрос GET /api/admin/users/{id}
+func (h *AdminHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.UserRepo.GetUserByID(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "User not found")
+			return
+		}
+		log.Printf("GetUserHandler: Error fetching user %d: %v", userID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch user")
+		return
+	}
+	user.PasswordHash = ""
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// CreateUserHandler обрабатывает запрос POST /api/admin/users
+// В отличие от самостоятельной регистрации позволяет сразу задать роль и партнера.
+func (h *AdminHandler) CreateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req adminUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.Login = strings.TrimSpace(req.Login)
+	if len(req.Login) < 3 {
+		RespondWithError(w, http.StatusBadRequest, "Login must be at least 3 characters long")
+		return
+	}
+	if err := utils.ValidatePassword(req.Password); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.validateUserRequest(w, r, &req) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		log.Printf("CreateUserHandler: Password hashing failed: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+
+	user := &models.User{
+		Login:        req.Login,
+		PasswordHash: hashedPassword,
+		Role:         req.Role,
+		PartnerID:    req.PartnerID,
+		Name:         req.Name,
+		Email:        req.Email,
+		Phone:        req.Phone,
+	}
+	if err := h.UserRepo.CreateUser(r.Context(), user); err != nil {
+		if db.IsUniqueConstraintViolation(err, "users_login_key") {
+			RespondWithError(w, http.StatusConflict, "User with this login already exists")
+			return
+		}
+		log.Printf("CreateUserHandler: Error creating user %s: %v", req.Login, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to create user")
+		return
+	}
+	audit.SetTarget(r.Context(), "user", user.ID)
+
+	user.PasswordHash = ""
+	RespondWithJSON(w, http.StatusCreated, user)
+}
+
+// UpdateUserHandler обрабатывает запрос PUT /api/admin/users/{id}
+// Заменяет роль, партнера и контактные данные; логин не меняется.
+func (h *AdminHandler) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req adminUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !h.validateUserRequest(w, r, &req) {
+		return
+	}
+	// Администратор не может лишить себя роли и потерять доступ к администрированию
+	if adminID, _ := r.Context().Value(middleware.UserIDKey).(int); adminID == userID && req.Role != models.RoleAdmin {
+		RespondWithError(w, http.StatusConflict, "You cannot change your own role")
+		return
+	}
+	if req.Password != "" {
+		if err := utils.ValidatePassword(req.Password); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	user := &models.User{
+		ID:        userID,
+		Role:      req.Role,
+		PartnerID: req.PartnerID,
+		Name:      req.Name,
+		Email:     req.Email,
+		Phone:     req.Phone,
+	}
+	// Профиль и пароль сохраняются вместе: при ошибке не остается наполовину примененных изменений
+	if req.Password != "" {
+		hashedPassword, err := utils.HashPassword(req.Password)
+		if err != nil {
+			log.Printf("UpdateUserHandler: Password hashing failed: %v", err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to update password")
+			return
+		}
+		user.PasswordHash = hashedPassword
+	}
+	if err := h.UserRepo.UpdateUser(r.Context(), user); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			RespondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, db.ErrInvalidState):
+			RespondWithError(w, http.StatusConflict, "Manager is assigned to partners; reassign them first")
+		default:
+			log.Printf("UpdateUserHandler: Error updating user %d: %v", userID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to update user")
+		}
+		return
+	}
+
+	user.PasswordHash = ""
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// DeleteUserHandler обрабатывает запрос DELETE /api/admin/users/{id}
+func (h *AdminHandler) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if adminID, _ := r.Context().Value(middleware.UserIDKey).(int); adminID == userID {
+		RespondWithError(w, http.StatusConflict, "You cannot delete yourself")
+		return
+	}
+
+	if err := h.UserRepo.DeleteUser(r.Context(), userID); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			RespondWithError(w, http.StatusNotFound, "User not found")
+		case errors.Is(err, db.ErrInvalidState):
+			RespondWithError(w, http.StatusConflict, "User has requests and cannot be deleted")
+		default:
+			log.Printf("DeleteUserHandler: Error deleting user %d: %v", userID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to delete user")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreatePartnerHandler обрабатывает запрос POST /api/admin/partners
+func (h *AdminHandler) CreatePartnerHandler(w http.ResponseWriter, r *http.Request) {
+	var req adminPartnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !h.validatePartnerRequest(w, r, &req) {
+		return
+	}
+
+	partner := req.apply(&models.Partner{})
+	partner.AssignedManagerID = req.AssignedManagerID
+	if err := h.PartnerRepo.CreatePartner(r.Context(), partner); err != nil {
+		respondPartnerSaveError(w, "Admin CreatePartnerHandler", err)
+		return
+	}
+	audit.SetTarget(r.Context(), "partner", partner.ID)
+	RespondWithJSON(w, http.StatusCreated, partner)
+}
+
+// UpdatePartnerHandler обрабатывает запрос PUT /api/admin/partners/{id}
+// В том числе назначает (assigned_manager_id) или снимает (null) ответственного менеджера.
+func (h *AdminHandler) UpdatePartnerHandler(w http.ResponseWriter, r *http.Request) {
+	partnerID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req adminPartnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !h.validatePartnerRequest(w, r, &req) {
+		return
+	}
+
+	partner, err := h.PartnerRepo.GetPartnerByID(r.Context(), partnerID)
+	if err != nil {
+		respondPartnerSaveError(w, "Admin UpdatePartnerHandler", err)
+		return
+	}
+	req.apply(partner)
+	partner.AssignedManagerID = req.AssignedManagerID
+	if err := h.PartnerRepo.UpdatePartner(r.Context(), partner); err != nil {
+		respondPartnerSaveError(w, "Admin UpdatePartnerHandler", err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, partner)
+}
+
+// DeletePartnerHandler обрабатывает запрос DELETE /api/admin/partners/{id}
+func (h *AdminHandler) DeletePartnerHandler(w http.ResponseWriter, r *http.Request) {
+	partnerID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.PartnerRepo.DeletePartner(r.Context(), partnerID); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			RespondWithError(w, http.StatusNotFound, "Partner not found")
+		case errors.Is(err, db.ErrInvalidState):
+			RespondWithError(w, http.StatusConflict, "Partner has requests and cannot be deleted")
+		default:
+			log.Printf("DeletePartnerHandler: Error deleting partner %d: %v", partnerID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to delete partner")
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateUserRequest проверяет роль и существование партнера.
+// Возвращает false, если ответ с ошибкой уже отправлен.
+func (h *AdminHandler) validateUserRequest(w http.ResponseWriter, r *http.Request, req *adminUserRequest) bool {
+	req.Role = models.UserRole(strings.ToUpper(string(req.Role)))
+	if !req.Role.IsValid() {
+		RespondWithError(w, http.StatusBadRequest, "Invalid role (expected USER, MANAGER or ADMIN)")
+		return false
+	}
+	if req.Email != nil && *req.Email != "" && !utils.IsValidEmail(*req.Email) {
+		RespondWithError(w, http.StatusBadRequest, "Invalid email")
+		return false
+	}
+	if req.PartnerID != nil {
+		if _, err := h.PartnerRepo.GetPartnerByID(r.Context(), *req.PartnerID); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				RespondWithError(w, http.StatusBadRequest, "Partner not found")
+				return false
+			}
+			log.Printf("validateUserRequest: Error fetching partner %d: %v", *req.PartnerID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to validate partner")
+			return false
+		}
+	}
+	return true
+}
+
+// validatePartnerRequest проверяет реквизиты (ИНН необязателен) и что назначаемый менеджер имеет роль MANAGER.
+// Возвращает false, если ответ с ошибкой уже отправлен.
+func (h *AdminHandler) validatePartnerRequest(w http.ResponseWriter, r *http.Request, req *adminPartnerRequest) bool {
+	if errs := validatePartnerRequest(&req.partnerRequest, false); len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return false
+	}
+	if req.AssignedManagerID != nil {
+		manager, err := h.UserRepo.GetUserByID(r.Context(), *req.AssignedManagerID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				RespondWithError(w, http.StatusBadRequest, "Assigned manager not found")
+				return false
+			}
+			log.Printf("validatePartnerRequest: Error fetching user %d: %v", *req.AssignedManagerID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to validate manager")
+			return false
+		}
+		if manager.Role != models.RoleManager {
+			RespondWithError(w, http.StatusBadRequest, "Assigned user is not a manager")
+			return false
+		}
+	}
+	return true
+}
+
+// pathID разбирает {id} из пути. Возвращает false, если ответ с ошибкой уже отправлен.
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// parsePagination возвращает номер страницы и размер страницы (по умолчанию 50, максимум 100).
+func parsePagination(pageStr, limitStr string) (int, int) {
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	return page, limit
+}
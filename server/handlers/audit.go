@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// AuditHandler - обработчик запросов к журналу аудита
+type AuditHandler struct {
+	AuditRepo *db.AuditRepository
+}
+
+// NewAuditHandler создает новый экземпляр AuditHandler
+func NewAuditHandler(auditRepo *db.AuditRepository) *AuditHandler {
+	return &AuditHandler{
+		AuditRepo: auditRepo,
+	}
+}
+
+// ListAuditEventsHandler обрабатывает запрос GET /api/admin/audit
+// Фильтры: actor_id, action, entity_type, entity_id, from, to (RFC3339 или YYYY-MM-DD).
+func (h *AuditHandler) ListAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+
+	filter := db.AuditFilter{
+		Action:     query.Get("action"),
+		EntityType: query.Get("entity_type"),
+		EntityID:   query.Get("entity_id"),
+	}
+	if actorStr := query.Get("actor_id"); actorStr != "" {
+		actorID, err := strconv.Atoi(actorStr)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid actor_id")
+			return
+		}
+		filter.ActorID = &actorID
+	}
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from"), false); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid 'from' value (use RFC3339 or YYYY-MM-DD)")
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to"), true); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid 'to' value (use RFC3339 or YYYY-MM-DD)")
+		return
+	}
+
+	events, total, err := h.AuditRepo.ListAuditEvents(r.Context(), filter, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListAuditEventsHandler: Error listing audit events: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch audit events")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: events,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// parseTimeParam разбирает момент времени в формате RFC3339 или дату YYYY-MM-DD.
+// Для верхней границы (endOfDay) дата включает весь день.
+func parseTimeParam(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -3,16 +3,20 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/eeephemera/zvk-requests/server/audit"
 	"github.com/eeephemera/zvk-requests/server/db"
 	"github.com/eeephemera/zvk-requests/server/middleware"
 	"github.com/eeephemera/zvk-requests/server/models"
 	"github.com/eeephemera/zvk-requests/server/utils"
+	"github.com/eeephemera/zvk-requests/server/validation"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -94,13 +98,14 @@ type PartnerRepositoryInterface interface {
 // --- Предполагаемая структура обработчика ---
 // (Замените на вашу реальную структуру)
 type AuthHandler struct {
-	UserRepo    *db.UserRepository
-	PartnerRepo *db.PartnerRepository
+	UserRepo        *db.UserRepository
+	PartnerRepo     *db.PartnerRepository
+	JoinRequestRepo *db.JoinRequestRepository
 }
 
 // NewAuthHandler создает новый экземпляр AuthHandler.
-func NewAuthHandler(userRepo *db.UserRepository, partnerRepo *db.PartnerRepository) *AuthHandler {
-	return &AuthHandler{UserRepo: userRepo, PartnerRepo: partnerRepo}
+func NewAuthHandler(userRepo *db.UserRepository, partnerRepo *db.PartnerRepository, joinRequestRepo *db.JoinRequestRepository) *AuthHandler {
+	return &AuthHandler{UserRepo: userRepo, PartnerRepo: partnerRepo, JoinRequestRepo: joinRequestRepo}
 }
 
 // RegisterUser обрабатывает регистрацию новых пользователей.
@@ -117,6 +122,9 @@ func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		Name      string `json:"name,omitempty"`
 		Email     string `json:"email,omitempty"`
 		Phone     string `json:"phone,omitempty"`
+		// Организация, к которой пользователь просит его присоединить
+		PartnerINN  string `json:"partner_inn"`
+		PartnerName string `json:"partner_name"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -124,6 +132,8 @@ func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
+	req.PartnerINN = strings.TrimSpace(req.PartnerINN)
+	req.PartnerName = strings.TrimSpace(req.PartnerName)
 
 	// Валидация, что пароли совпадают
 	if req.Password != req.PasswordConfirmation {
@@ -143,6 +153,16 @@ func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		RespondWithError(w, http.StatusBadRequest, "Login must be at least 3 characters long")
 		return
 	}
+	if err := validation.ValidateINN(req.PartnerINN); err != nil {
+		logger.Warn("Partner INN validation failed", "error", err)
+		RespondWithError(w, http.StatusBadRequest, "ИНН организации: "+err.Error())
+		return
+	}
+	if err := validation.ValidateOrgName(req.PartnerName); err != nil {
+		logger.Warn("Partner name validation failed", "error", err)
+		RespondWithError(w, http.StatusBadRequest, "Название организации: "+err.Error())
+		return
+	}
 
 	// Хеширование пароля
 	hashedPassword, err := utils.HashPassword(req.Password)
@@ -169,7 +189,13 @@ func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 		user.Email = &req.Email
 	}
 
-	if err := h.UserRepo.CreateUser(r.Context(), user); err != nil {
+	// Партнера назначает менеджер или администратор, одобряя заявку на присоединение
+	joinRequest := &models.JoinRequest{
+		PartnerINN:  req.PartnerINN,
+		PartnerName: req.PartnerName,
+	}
+
+	if err := h.JoinRequestRepo.RegisterUser(r.Context(), user, joinRequest); err != nil {
 		// Проверяем, является ли ошибка ошибкой нарушения уникальности
 		if db.IsUniqueConstraintViolation(err, "users_login_key") {
 			logger.Warn("User registration failed - login already exists", "login", req.Login)
@@ -183,6 +209,8 @@ func (h *AuthHandler) RegisterUser(w http.ResponseWriter, r *http.Request) {
 
 	// Не возвращаем хеш пароля
 	user.PasswordHash = ""
+	user.Onboarding = joinRequest
+	audit.SetActor(r.Context(), user.ID, string(user.Role))
 
 	logger.Info("User registered successfully", "user_id", user.ID, "login", user.Login, "role", user.Role)
 	RespondWithJSON(w, http.StatusCreated, user)
@@ -221,6 +249,8 @@ func (h *AuthHandler) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	audit.SetActor(r.Context(), user.ID, string(user.Role))
+
 	// Ре-хеш, если нужно (миграция cost)
 	if utils.NeedsRehash(user.PasswordHash) {
 		if newHash, err := utils.HashPassword(req.Password); err == nil {
@@ -450,6 +480,14 @@ func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Статус онбординга: последняя заявка на присоединение к партнеру
+	joinRequest, err := h.JoinRequestRepo.GetLatestJoinRequestForUser(r.Context(), userID)
+	if err == nil {
+		user.Onboarding = joinRequest
+	} else if !errors.Is(err, db.ErrNotFound) {
+		logger.Error("Error fetching join request", "user_id", userID, "error", err)
+	}
+
 	// Убираем хеш перед отправкой
 	user.PasswordHash = ""
 
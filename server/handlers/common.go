@@ -4,6 +4,9 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/validation"
 )
 
 // ErrorResponse — структура для отправки ошибок в формате JSON
@@ -34,3 +37,29 @@ func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 		log.Printf("Error writing JSON response: %v", err)
 	}
 }
+
+// RespondWithValidationErrors отправляет 400 с ошибками по полям в формате middleware.ValidationResponse
+func RespondWithValidationErrors(w http.ResponseWriter, errs []middleware.ValidationError) {
+	RespondWithJSON(w, http.StatusBadRequest, middleware.ValidationResponse{Errors: errs})
+}
+
+// ValidateRequisites проверяет ИНН (с контрольными цифрами) и необязательные КПП и ОГРН организации.
+// Ошибки возвращаются по полям prefix+"inn", prefix+"kpp", prefix+"ogrn".
+func ValidateRequisites(prefix, inn string, kpp, ogrn *string) []middleware.ValidationError {
+	var errs []middleware.ValidationError
+	orgType, err := validation.DetectOrgType(inn)
+	if err != nil {
+		errs = append(errs, middleware.ValidationError{Field: prefix + "inn", Message: err.Error()})
+	}
+	if kpp != nil {
+		if err := validation.ValidateKPP(*kpp, orgType); err != nil {
+			errs = append(errs, middleware.ValidationError{Field: prefix + "kpp", Message: err.Error()})
+		}
+	}
+	if ogrn != nil {
+		if err := validation.ValidateOGRN(*ogrn, orgType); err != nil {
+			errs = append(errs, middleware.ValidationError{Field: prefix + "ogrn", Message: err.Error()})
+		}
+	}
+	return errs
+}
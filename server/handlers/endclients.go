@@ -1,33 +1,48 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/registry"
 )
 
 // EndClientHandler - обработчик запросов для работы с конечными клиентами
 type EndClientHandler struct {
 	EndClientRepo *db.EndClientRepository
+	Registry      registry.CompanyRegistry // Внешний реестр организаций; nil — не используется
 }
 
-// NewEndClientHandler создает новый экземпляр EndClientHandler
-func NewEndClientHandler(endClientRepo *db.EndClientRepository) *EndClientHandler {
+// NewEndClientHandler создает новый экземпляр EndClientHandler.
+// companyRegistry может быть nil.
+func NewEndClientHandler(endClientRepo *db.EndClientRepository, companyRegistry registry.CompanyRegistry) *EndClientHandler {
 	return &EndClientHandler{
 		EndClientRepo: endClientRepo,
+		Registry:      companyRegistry,
 	}
 }
 
 // SearchByINNHandler обрабатывает запрос GET /api/end-clients/search?inn=...
-// и возвращает информацию о конечном клиенте по его ИНН
+// и возвращает информацию о конечном клиенте по его ИНН. Если в справочнике клиента нет,
+// возвращает данные из внешнего реестра с external = true (клиент еще не сохранен).
 func (h *EndClientHandler) SearchByINNHandler(w http.ResponseWriter, r *http.Request) {
 	// Получаем параметр ИНН из запроса
-	inn := r.URL.Query().Get("inn")
+	inn := strings.TrimSpace(r.URL.Query().Get("inn"))
 	if inn == "" {
 		RespondWithError(w, http.StatusBadRequest, "Не указан параметр ИНН")
 		return
 	}
+	// ИНН с неверной контрольной цифрой заведомо не найдется — сообщаем об опечатке
+	if errs := ValidateRequisites("", inn, nil, nil); len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return
+	}
 
 	// Ищем клиента по ИНН в базе данных
 	endClient, err := h.EndClientRepo.FindEndClientByINN(r.Context(), inn)
@@ -37,6 +52,15 @@ func (h *EndClientHandler) SearchByINNHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	// Если клиента нет в справочнике, пробуем заполнить его данные из реестра
+	if endClient == nil && h.Registry != nil {
+		endClient, err = h.Registry.LookupByINN(r.Context(), inn)
+		if err != nil && !errors.Is(err, registry.ErrNotFound) {
+			// Недоступный или медленный реестр не мешает заполнить заявку вручную
+			log.Printf("Error looking up INN %s in company registry: %v", inn, err)
+		}
+	}
+
 	// Если клиент не найден, возвращаем 404
 	if endClient == nil {
 		RespondWithError(w, http.StatusNotFound, "Клиент с указанным ИНН не найден")
@@ -46,3 +70,214 @@ func (h *EndClientHandler) SearchByINNHandler(w http.ResponseWriter, r *http.Req
 	// Возвращаем успешный ответ с информацией о найденном клиенте
 	RespondWithJSON(w, http.StatusOK, endClient)
 }
+
+// endClientRequest - реквизиты конечного клиента в запросе изменения
+type endClientRequest struct {
+	Name                 string  `json:"name"`
+	City                 *string `json:"city"`
+	INN                  *string `json:"inn"`
+	KPP                  *string `json:"kpp"`
+	OGRN                 *string `json:"ogrn"`
+	FullAddress          *string `json:"full_address"`
+	ContactPersonDetails *string `json:"contact_person_details"`
+}
+
+// SearchEndClientsHandler обрабатывает запрос GET /api/end-clients (MANAGER, ADMIN)
+// Нечеткий поиск: q — название (с опечатками) или начало ИНН, city — город.
+func (h *EndClientHandler) SearchEndClientsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+	filter := db.EndClientFilter{
+		Query: strings.TrimSpace(query.Get("q")),
+		City:  strings.TrimSpace(query.Get("city")),
+	}
+
+	clients, total, err := h.EndClientRepo.SearchEndClients(r.Context(), filter, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error searching end clients: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Ошибка при поиске клиентов")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: clients,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// partnerEndClient — конечный клиент в ответе партнеру: без адреса и контактов,
+// которые вносили другие партнеры.
+type partnerEndClient struct {
+	ID      int             `json:"id"`
+	Name    string          `json:"name"`
+	City    *string         `json:"city,omitempty"`
+	INN     *string         `json:"inn,omitempty"`
+	KPP     *string         `json:"kpp,omitempty"`
+	OGRN    *string         `json:"ogrn,omitempty"`
+	OrgType *models.OrgType `json:"org_type,omitempty"`
+}
+
+// ListMyEndClientsHandler обрабатывает запрос GET /api/requests/my/end-clients
+// Поиск, как в SearchEndClientsHandler, но только среди клиентов из заявок партнера пользователя.
+func (h *EndClientHandler) ListMyEndClientsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	query := r.URL.Query()
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+	filter := db.EndClientFilter{
+		Query:         strings.TrimSpace(query.Get("q")),
+		City:          strings.TrimSpace(query.Get("city")),
+		PartnerUserID: userID,
+	}
+
+	clients, total, err := h.EndClientRepo.SearchEndClients(r.Context(), filter, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListMyEndClientsHandler: Error searching end clients for user %d: %v", userID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Ошибка при поиске клиентов")
+		return
+	}
+
+	items := make([]partnerEndClient, 0, len(clients))
+	for _, c := range clients {
+		items = append(items, partnerEndClient{
+			ID: c.ID, Name: c.Name, City: c.City, INN: c.INN, KPP: c.KPP, OGRN: c.OGRN, OrgType: c.OrgType,
+		})
+	}
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: items,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetEndClientHandler обрабатывает запрос GET /api/end-clients/{id} (MANAGER, ADMIN)
+func (h *EndClientHandler) GetEndClientHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	client, err := h.EndClientRepo.GetEndClientByID(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "End client not found")
+			return
+		}
+		log.Printf("GetEndClientHandler: Error fetching end client %d: %v", id, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch end client")
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, client)
+}
+
+// UpdateEndClientHandler обрабатывает запрос PUT /api/end-clients/{id}
+// Заменяет реквизиты конечного клиента (MANAGER, ADMIN).
+func (h *EndClientHandler) UpdateEndClientHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req endClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	client := &models.EndClient{
+		ID:                   id,
+		Name:                 strings.TrimSpace(req.Name),
+		City:                 trimmedOrNil(req.City),
+		INN:                  trimmedOrNil(req.INN),
+		KPP:                  trimmedOrNil(req.KPP),
+		OGRN:                 trimmedOrNil(req.OGRN),
+		FullAddress:          trimmedOrNil(req.FullAddress),
+		ContactPersonDetails: trimmedOrNil(req.ContactPersonDetails),
+	}
+	if client.Name == "" {
+		RespondWithError(w, http.StatusBadRequest, "End client name is required")
+		return
+	}
+	if client.INN != nil {
+		if errs := ValidateRequisites("", *client.INN, client.KPP, client.OGRN); len(errs) > 0 {
+			RespondWithValidationErrors(w, errs)
+			return
+		}
+	} else if client.KPP != nil || client.OGRN != nil {
+		RespondWithValidationErrors(w, []middleware.ValidationError{
+			{Field: "inn", Message: "КПП и ОГРН указываются вместе с ИНН"},
+		})
+		return
+	}
+
+	if err := h.EndClientRepo.UpdateEndClient(r.Context(), client); err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			RespondWithError(w, http.StatusNotFound, "End client not found")
+		case errors.Is(err, db.ErrInvalidState):
+			RespondWithError(w, http.StatusConflict, "End client is merged into another client")
+		case db.IsUniqueConstraintViolation(err, "end_clients_inn_key"):
+			RespondWithError(w, http.StatusConflict, "End client with this INN already exists; merge them instead")
+		default:
+			log.Printf("UpdateEndClientHandler: Error updating end client %d: %v", id, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to update end client")
+		}
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, client)
+}
+
+// MergeEndClientsHandler обрабатывает запрос POST /api/end-clients/{id}/merge
+// Тело: {"duplicate_ids": [2, 3]}. Заявки дубликатов переводятся на клиента {id} (MANAGER, ADMIN).
+func (h *EndClientHandler) MergeEndClientsHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		DuplicateIDs []int `json:"duplicate_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	duplicateIDs := make([]int, 0, len(req.DuplicateIDs))
+	seen := map[int]bool{}
+	for _, dup := range req.DuplicateIDs {
+		if dup == id {
+			RespondWithError(w, http.StatusBadRequest, "End client cannot be merged into itself")
+			return
+		}
+		if !seen[dup] {
+			seen[dup] = true
+			duplicateIDs = append(duplicateIDs, dup)
+		}
+	}
+	if len(duplicateIDs) == 0 {
+		RespondWithError(w, http.StatusBadRequest, "duplicate_ids must not be empty")
+		return
+	}
+
+	survivor, movedIDs, err := h.EndClientRepo.MergeEndClients(r.Context(), id, duplicateIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			RespondWithError(w, http.StatusNotFound, "End client or duplicate not found")
+		case errors.Is(err, db.ErrInvalidState):
+			RespondWithError(w, http.StatusConflict, "End client or duplicate is already merged")
+		default:
+			log.Printf("MergeEndClientsHandler: Error merging end clients %v into %d: %v", duplicateIDs, id, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to merge end clients")
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"end_client":        survivor,
+		"merged_ids":        duplicateIDs,
+		"moved_request_ids": movedIDs,
+	})
+}
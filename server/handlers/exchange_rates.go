@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/eeephemera/zvk-requests/server/currency"
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/gorilla/mux"
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRateHandler - обработчик запросов для работы с курсами валют
+type ExchangeRateHandler struct {
+	RateRepo *db.ExchangeRateRepository
+}
+
+// NewExchangeRateHandler создает новый экземпляр ExchangeRateHandler
+func NewExchangeRateHandler(rateRepo *db.ExchangeRateRepository) *ExchangeRateHandler {
+	return &ExchangeRateHandler{
+		RateRepo: rateRepo,
+	}
+}
+
+// ListExchangeRatesHandler обрабатывает запрос GET /api/exchange-rates
+// Базовая валюта (RUB) в списке не возвращается: ее курс всегда 1.
+func (h *ExchangeRateHandler) ListExchangeRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.RateRepo.ListExchangeRates(r.Context())
+	if err != nil {
+		log.Printf("ListExchangeRatesHandler: Error fetching exchange rates: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch exchange rates")
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, rates)
+}
+
+// SetExchangeRateHandler обрабатывает запрос PUT /api/exchange-rates/{currency}
+// Тело: {"rate": "92.5"} — рублей за единицу валюты. Уже сохраненные заявки не пересчитываются.
+func (h *ExchangeRateHandler) SetExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	code, ok := currencyFromPath(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Rate decimal.Decimal `json:"rate"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := currency.ValidateRate(req.Rate); err != nil {
+		RespondWithValidationErrors(w, []middleware.ValidationError{{Field: "rate", Message: err.Error()}})
+		return
+	}
+
+	rate, err := h.RateRepo.SetExchangeRate(r.Context(), code, req.Rate, userID)
+	if err != nil {
+		log.Printf("SetExchangeRateHandler: Error saving exchange rate %s: %v", code, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to save exchange rate")
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, rate)
+}
+
+// DeleteExchangeRateHandler обрабатывает запрос DELETE /api/exchange-rates/{currency}
+func (h *ExchangeRateHandler) DeleteExchangeRateHandler(w http.ResponseWriter, r *http.Request) {
+	code, ok := currencyFromPath(w, r)
+	if !ok {
+		return
+	}
+	if err := h.RateRepo.DeleteExchangeRate(r.Context(), code); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Exchange rate not found")
+			return
+		}
+		log.Printf("DeleteExchangeRateHandler: Error deleting exchange rate %s: %v", code, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to delete exchange rate")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// currencyFromPath возвращает код валюты из URL. Курс базовой валюты не задается.
+func currencyFromPath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	code := currency.Normalize(mux.Vars(r)["currency"])
+	if err := currency.Validate(code); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return "", false
+	}
+	if code == currency.Base {
+		RespondWithError(w, http.StatusBadRequest, "Exchange rate of the base currency is always 1")
+		return "", false
+	}
+	return code, true
+}
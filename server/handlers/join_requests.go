@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/validation"
+)
+
+// JoinRequestHandler - обработчик заявок пользователей на присоединение к партнеру
+type JoinRequestHandler struct {
+	JoinRequestRepo *db.JoinRequestRepository
+	PartnerRepo     *db.PartnerRepository
+}
+
+// NewJoinRequestHandler создает новый экземпляр JoinRequestHandler
+func NewJoinRequestHandler(joinRequestRepo *db.JoinRequestRepository, partnerRepo *db.PartnerRepository) *JoinRequestHandler {
+	return &JoinRequestHandler{
+		JoinRequestRepo: joinRequestRepo,
+		PartnerRepo:     partnerRepo,
+	}
+}
+
+// ListJoinRequestsHandler обрабатывает запрос GET /api/join-requests
+// По умолчанию возвращает заявки, ожидающие рассмотрения; status=ALL — все.
+// Менеджеру не показываются заявки к партнерам, закрепленным за другими менеджерами.
+func (h *JoinRequestHandler) ListJoinRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+
+	status := models.JoinRequestStatus(strings.ToUpper(query.Get("status")))
+	switch status {
+	case "":
+		status = models.JoinRequestPending
+	case "ALL":
+		status = ""
+	case models.JoinRequestPending, models.JoinRequestApproved, models.JoinRequestRejected:
+	default:
+		RespondWithError(w, http.StatusBadRequest, "Invalid status (expected PENDING, APPROVED, REJECTED or ALL)")
+		return
+	}
+
+	// Менеджер видит только заявки к своим партнерам и заявки новых организаций
+	var managerID *int
+	if role, _ := r.Context().Value(middleware.RoleKey).(string); role != string(models.RoleAdmin) {
+		id, _ := r.Context().Value(middleware.UserIDKey).(int)
+		managerID = &id
+	}
+
+	list, total, err := h.JoinRequestRepo.ListJoinRequests(r.Context(), status, managerID, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("ListJoinRequestsHandler: Error listing join requests: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch join requests")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: list,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// ApproveJoinRequestHandler обрабатывает запрос POST /api/join-requests/{id}/approve
+// Тело (необязательно): {"partner_id": 1, "comment": "..."}. Без partner_id используется партнер
+// с ИНН из заявки, а если его нет — он создается. Менеджер может привязать пользователя
+// только к партнеру, за которым закреплен; администратор — к любому.
+func (h *JoinRequestHandler) ApproveJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		PartnerID *int    `json:"partner_id"`
+		Comment   *string `json:"comment"`
+	}
+	if !decodeOptionalBody(w, r, &req) {
+		return
+	}
+	if req.PartnerID != nil {
+		if _, err := h.PartnerRepo.GetPartnerByID(r.Context(), *req.PartnerID); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				RespondWithError(w, http.StatusBadRequest, "Partner not found")
+				return
+			}
+			log.Printf("ApproveJoinRequestHandler: Error fetching partner %d: %v", *req.PartnerID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to validate partner")
+			return
+		}
+	}
+
+	reviewerID, _ := r.Context().Value(middleware.UserIDKey).(int)
+	role, _ := r.Context().Value(middleware.RoleKey).(string)
+	asManager := role != string(models.RoleAdmin)
+	jr, err := h.JoinRequestRepo.ApproveJoinRequest(r.Context(), id, reviewerID, asManager, req.PartnerID, trimmedOrNil(req.Comment))
+	if err != nil {
+		respondJoinRequestError(w, "ApproveJoinRequestHandler", id, err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, jr)
+}
+
+// RejectJoinRequestHandler обрабатывает запрос POST /api/join-requests/{id}/reject
+// Тело: {"comment": "причина"} — причина обязательна, ее увидит пользователь в /api/me.
+// Менеджер не может отклонить заявку к партнеру, закрепленному за другим менеджером.
+func (h *JoinRequestHandler) RejectJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Comment *string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	comment := trimmedOrNil(req.Comment)
+	if comment == nil {
+		RespondWithError(w, http.StatusBadRequest, "Rejection comment is required")
+		return
+	}
+
+	reviewerID, _ := r.Context().Value(middleware.UserIDKey).(int)
+	role, _ := r.Context().Value(middleware.RoleKey).(string)
+	asManager := role != string(models.RoleAdmin)
+	jr, err := h.JoinRequestRepo.RejectJoinRequest(r.Context(), id, reviewerID, asManager, comment)
+	if err != nil {
+		respondJoinRequestError(w, "RejectJoinRequestHandler", id, err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, jr)
+}
+
+// ResubmitJoinRequestHandler обрабатывает запрос POST /api/me/join-request
+// Позволяет пользователю без партнера подать новую заявку, например после отклонения.
+func (h *JoinRequestHandler) ResubmitJoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	var req struct {
+		PartnerINN  string `json:"partner_inn"`
+		PartnerName string `json:"partner_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	jr := &models.JoinRequest{
+		UserID:      userID,
+		PartnerINN:  strings.TrimSpace(req.PartnerINN),
+		PartnerName: strings.TrimSpace(req.PartnerName),
+	}
+	if err := validation.ValidateINN(jr.PartnerINN); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "ИНН организации: "+err.Error())
+		return
+	}
+	if err := validation.ValidateOrgName(jr.PartnerName); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Название организации: "+err.Error())
+		return
+	}
+
+	if err := h.JoinRequestRepo.CreateJoinRequest(r.Context(), jr); err != nil {
+		switch {
+		case errors.Is(err, db.ErrInvalidState):
+			RespondWithError(w, http.StatusConflict, "User already belongs to a partner or has a pending join request")
+		case errors.Is(err, db.ErrNotFound):
+			RespondWithError(w, http.StatusNotFound, "User not found")
+		default:
+			log.Printf("ResubmitJoinRequestHandler: Error creating join request for user %d: %v", userID, err)
+			RespondWithError(w, http.StatusInternalServerError, "Failed to create join request")
+		}
+		return
+	}
+	RespondWithJSON(w, http.StatusCreated, jr)
+}
+
+func respondJoinRequestError(w http.ResponseWriter, handler string, id int, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		RespondWithError(w, http.StatusNotFound, "Join request not found")
+	case errors.Is(err, db.ErrInvalidState):
+		RespondWithError(w, http.StatusConflict, "Join request is already reviewed")
+	case errors.Is(err, db.ErrForbidden):
+		RespondWithError(w, http.StatusForbidden, "Manager is not assigned to this partner")
+	default:
+		log.Printf("%s: Error reviewing join request %d: %v", handler, id, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to review join request")
+	}
+}
+
+// decodeOptionalBody разбирает JSON-тело, допуская его отсутствие.
+// Возвращает false, если ответ с ошибкой уже отправлен.
+func decodeOptionalBody(w http.ResponseWriter, r *http.Request, dst any) bool {
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// trimmedOrNil возвращает nil для пустой строки, иначе строку без пробелов по краям.
+func trimmedOrNil(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*s)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/validation"
 )
 
 // PartnerHandler - обработчик запросов для работы с партнерами
@@ -19,17 +25,230 @@ func NewPartnerHandler(partnerRepo *db.PartnerRepository) *PartnerHandler {
 	}
 }
 
+// partnerRequest - реквизиты партнера в запросах создания и изменения
+type partnerRequest struct {
+	Name          string  `json:"name"`
+	Address       *string `json:"address"`
+	INN           string  `json:"inn"`
+	KPP           *string `json:"kpp"`
+	OGRN          *string `json:"ogrn"`
+	PartnerStatus *string `json:"partner_status"`
+	IsDistributor bool    `json:"is_distributor"`
+}
+
 // ListPartnersHandler обрабатывает запрос GET /api/partners
-// и возвращает список всех партнеров
+// Фильтры: search (название или начало ИНН), status, distributor=true (только дистрибьюторы).
 func (h *PartnerHandler) ListPartnersHandler(w http.ResponseWriter, r *http.Request) {
-	// Получаем список партнеров из базы данных
-	partners, err := h.PartnerRepo.GetAllPartners(r.Context())
+	query := r.URL.Query()
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+
+	filter := db.PartnerFilter{
+		Search:           strings.TrimSpace(query.Get("search")),
+		Status:           query.Get("status"),
+		DistributorsOnly: query.Get("distributor") == "true",
+	}
+	if filter.Status != "" {
+		if err := validation.ValidatePartnerStatus(filter.Status); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	partners, total, err := h.PartnerRepo.ListPartners(r.Context(), filter, limit, (page-1)*limit)
 	if err != nil {
 		log.Printf("Error getting partners from database: %v", err)
 		RespondWithError(w, http.StatusInternalServerError, "Ошибка при получении списка партнеров")
 		return
 	}
 
-	// Возвращаем успешный ответ со списком партнеров
-	RespondWithJSON(w, http.StatusOK, partners)
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: partners,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetPartnerHandler обрабатывает запрос GET /api/partners/{id}
+func (h *PartnerHandler) GetPartnerHandler(w http.ResponseWriter, r *http.Request) {
+	partnerID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	partner, err := h.PartnerRepo.GetPartnerByID(r.Context(), partnerID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Partner not found")
+			return
+		}
+		log.Printf("GetPartnerHandler: Error fetching partner %d: %v", partnerID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch partner")
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, partner)
+}
+
+// CreatePartnerHandler обрабатывает запрос POST /api/partners
+// Статус по умолчанию — active.
+func (h *PartnerHandler) CreatePartnerHandler(w http.ResponseWriter, r *http.Request) {
+	var req partnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := validatePartnerRequest(&req, true); len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	partner := req.apply(&models.Partner{})
+	if err := h.PartnerRepo.CreatePartner(r.Context(), partner); err != nil {
+		respondPartnerSaveError(w, "CreatePartnerHandler", err)
+		return
+	}
+	RespondWithJSON(w, http.StatusCreated, partner)
+}
+
+// UpdatePartnerHandler обрабатывает запрос PUT /api/partners/{id}
+// Заменяет реквизиты и признак дистрибьютора. Ответственного менеджера назначает администратор,
+// статус без partner_status не меняется.
+func (h *PartnerHandler) UpdatePartnerHandler(w http.ResponseWriter, r *http.Request) {
+	partnerID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req partnerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if errs := validatePartnerRequest(&req, true); len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	partner, ok := h.loadPartner(w, r, partnerID)
+	if !ok {
+		return
+	}
+	if err := h.PartnerRepo.UpdatePartner(r.Context(), req.apply(partner)); err != nil {
+		respondPartnerSaveError(w, "UpdatePartnerHandler", err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, partner)
+}
+
+// UpdatePartnerStatusHandler обрабатывает запрос PUT /api/partners/{id}/status
+// Тело: {"partner_status": "active" | "pending" | "inactive"}.
+func (h *PartnerHandler) UpdatePartnerStatusHandler(w http.ResponseWriter, r *http.Request) {
+	partnerID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		PartnerStatus string `json:"partner_status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := validation.ValidatePartnerStatus(req.PartnerStatus); err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	partner, ok := h.loadPartner(w, r, partnerID)
+	if !ok {
+		return
+	}
+	partner.PartnerStatus = &req.PartnerStatus
+	if err := h.PartnerRepo.UpdatePartner(r.Context(), partner); err != nil {
+		respondPartnerSaveError(w, "UpdatePartnerStatusHandler", err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, partner)
+}
+
+// loadPartner возвращает партнера для изменения. Возвращает false, если ответ с ошибкой уже отправлен.
+func (h *PartnerHandler) loadPartner(w http.ResponseWriter, r *http.Request, partnerID int) (*models.Partner, bool) {
+	partner, err := h.PartnerRepo.GetPartnerByID(r.Context(), partnerID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Partner not found")
+			return nil, false
+		}
+		log.Printf("loadPartner: Error fetching partner %d: %v", partnerID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch partner")
+		return nil, false
+	}
+	return partner, true
+}
+
+// validatePartnerRequest нормализует и проверяет реквизиты партнера. Возвращает ошибки по полям.
+// Без innRequired ИНН можно не указывать: администратор заводит партнера, реквизиты которого еще неизвестны.
+func validatePartnerRequest(req *partnerRequest, innRequired bool) []middleware.ValidationError {
+	req.Name = strings.TrimSpace(req.Name)
+	req.INN = strings.TrimSpace(req.INN)
+	req.Address = trimmedOrNil(req.Address)
+	req.KPP = trimmedOrNil(req.KPP)
+	req.OGRN = trimmedOrNil(req.OGRN)
+
+	var errs []middleware.ValidationError
+	if err := validation.ValidateOrgName(req.Name); err != nil {
+		errs = append(errs, middleware.ValidationError{Field: "name", Message: err.Error()})
+	}
+	if req.INN != "" || innRequired {
+		errs = append(errs, ValidateRequisites("", req.INN, req.KPP, req.OGRN)...)
+	} else {
+		// Без ИНН тип организации неизвестен: проверяем только формат КПП и ОГРН
+		if req.KPP != nil {
+			if err := validation.ValidateKPP(*req.KPP, ""); err != nil {
+				errs = append(errs, middleware.ValidationError{Field: "kpp", Message: err.Error()})
+			}
+		}
+		if req.OGRN != nil {
+			if err := validation.ValidateOGRN(*req.OGRN, ""); err != nil {
+				errs = append(errs, middleware.ValidationError{Field: "ogrn", Message: err.Error()})
+			}
+		}
+	}
+	if req.PartnerStatus != nil {
+		if err := validation.ValidatePartnerStatus(*req.PartnerStatus); err != nil {
+			errs = append(errs, middleware.ValidationError{Field: "partner_status", Message: err.Error()})
+		}
+	}
+	return errs
+}
+
+// apply переносит реквизиты из запроса в партнера. Статус меняется, только если он передан;
+// у нового партнера по умолчанию active.
+func (req *partnerRequest) apply(partner *models.Partner) *models.Partner {
+	partner.Name = req.Name
+	partner.Address = req.Address
+	partner.INN = nil
+	if req.INN != "" {
+		partner.INN = &req.INN
+	}
+	partner.KPP = req.KPP
+	partner.OGRN = req.OGRN
+	partner.IsDistributor = req.IsDistributor
+	if req.PartnerStatus != nil {
+		partner.PartnerStatus = req.PartnerStatus
+	} else if partner.PartnerStatus == nil {
+		status := models.PartnerStatusActive
+		partner.PartnerStatus = &status
+	}
+	return partner
+}
+
+func respondPartnerSaveError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		RespondWithError(w, http.StatusNotFound, "Partner not found")
+	case db.IsUniqueConstraintViolation(err, "partners_inn_key"):
+		RespondWithError(w, http.StatusConflict, "Partner with this INN already exists")
+	default:
+		log.Printf("%s: Error saving partner: %v", handler, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to save partner")
+	}
 }
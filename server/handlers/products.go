@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/catalog"
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+// maxImportSize — максимальный размер файла прайс-листа.
+const maxImportSize = 5 << 20 // 5MB
+
+// ProductHandler - обработчик запросов для работы с каталогом продуктов
+type ProductHandler struct {
+	ProductRepo *db.ProductRepository
+}
+
+// NewProductHandler создает новый экземпляр ProductHandler
+func NewProductHandler(productRepo *db.ProductRepository) *ProductHandler {
+	return &ProductHandler{
+		ProductRepo: productRepo,
+	}
+}
+
+// productRequest - поля продукта в запросах создания и изменения
+type productRequest struct {
+	SKU       string          `json:"sku"`
+	Name      string          `json:"name"`
+	Category  *string         `json:"category"`
+	ListPrice decimal.Decimal `json:"list_price"`
+	Currency  string          `json:"currency"`
+	IsActive  *bool           `json:"is_active"`
+}
+
+// ListProductsHandler обрабатывает запрос GET /api/products
+// Фильтры: search (подстрока названия или начало SKU), category, active=true|false.
+func (h *ProductHandler) ListProductsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	page, limit := parsePagination(query.Get("page"), query.Get("limit"))
+
+	filter := db.ProductFilter{
+		Search:   strings.TrimSpace(query.Get("search")),
+		Category: strings.TrimSpace(query.Get("category")),
+	}
+	switch query.Get("active") {
+	case "":
+	case "true", "false":
+		active := query.Get("active") == "true"
+		filter.Active = &active
+	default:
+		RespondWithError(w, http.StatusBadRequest, "Invalid active filter: use true or false")
+		return
+	}
+
+	products, total, err := h.ProductRepo.ListProducts(r.Context(), filter, limit, (page-1)*limit)
+	if err != nil {
+		log.Printf("Error getting products from database: %v", err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch products")
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: products,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// GetProductHandler обрабатывает запрос GET /api/products/{id}
+func (h *ProductHandler) GetProductHandler(w http.ResponseWriter, r *http.Request) {
+	productID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	product, ok := h.loadProduct(w, r, productID)
+	if !ok {
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, product)
+}
+
+// CreateProductHandler обрабатывает запрос POST /api/products
+// Валюта по умолчанию — RUB, новый продукт по умолчанию активен.
+func (h *ProductHandler) CreateProductHandler(w http.ResponseWriter, r *http.Request) {
+	var req productRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	product := req.apply(&models.Product{IsActive: true})
+	if errs := validateProduct(product); len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return
+	}
+	if err := h.ProductRepo.CreateProduct(r.Context(), product); err != nil {
+		respondProductSaveError(w, "CreateProductHandler", err)
+		return
+	}
+	RespondWithJSON(w, http.StatusCreated, product)
+}
+
+// UpdateProductHandler обрабатывает запрос PUT /api/products/{id}
+// Заменяет поля продукта; признак активности без is_active не меняется.
+func (h *ProductHandler) UpdateProductHandler(w http.ResponseWriter, r *http.Request) {
+	productID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req productRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	product, ok := h.loadProduct(w, r, productID)
+	if !ok {
+		return
+	}
+	req.apply(product)
+	if errs := validateProduct(product); len(errs) > 0 {
+		RespondWithValidationErrors(w, errs)
+		return
+	}
+	if err := h.ProductRepo.UpdateProduct(r.Context(), product); err != nil {
+		respondProductSaveError(w, "UpdateProductHandler", err)
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, product)
+}
+
+// DeleteProductHandler обрабатывает запрос DELETE /api/products/{id}
+// Уже созданные заявки сохраняют SKU и прайсовую цену позиции. Чтобы только запретить продукт
+// в новых заявках, его достаточно сделать неактивным.
+func (h *ProductHandler) DeleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	productID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := h.ProductRepo.DeleteProduct(r.Context(), productID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Product not found")
+			return
+		}
+		log.Printf("DeleteProductHandler: Error deleting product %d: %v", productID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to delete product")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ImportProductsHandler обрабатывает запрос POST /api/products/import
+// Принимает прайс-лист в CSV: файл в поле file формы multipart/form-data или тело text/csv.
+// Продукты добавляются или обновляются по SKU; при ошибке в любой строке не импортируется ничего.
+func (h *ProductHandler) ImportProductsHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize+1<<20)
+
+	var src io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, "CSV file is required in the file field")
+			return
+		}
+		defer file.Close()
+		if header.Size > maxImportSize {
+			RespondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		src = file
+	}
+
+	products, rowErrs, err := catalog.ParseCSV(src)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			RespondWithError(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return
+		}
+		RespondWithError(w, http.StatusBadRequest, "Invalid CSV: "+err.Error())
+		return
+	}
+	if len(rowErrs) > 0 {
+		errs := make([]middleware.ValidationError, 0, len(rowErrs))
+		for _, e := range rowErrs {
+			errs = append(errs, middleware.ValidationError{Field: fmt.Sprintf("rows[%d].%s", e.Row, e.Field), Message: e.Message})
+		}
+		RespondWithValidationErrors(w, errs)
+		return
+	}
+
+	created, updated, err := h.ProductRepo.ImportProducts(r.Context(), products)
+	if err != nil {
+		log.Printf("ImportProductsHandler: Error importing %d products: %v", len(products), err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to import products")
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, map[string]int{"created": created, "updated": updated})
+}
+
+// loadProduct возвращает продукт по ID. Возвращает false, если ответ с ошибкой уже отправлен.
+func (h *ProductHandler) loadProduct(w http.ResponseWriter, r *http.Request, productID int) (*models.Product, bool) {
+	product, err := h.ProductRepo.GetProductByID(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			RespondWithError(w, http.StatusNotFound, "Product not found")
+			return nil, false
+		}
+		log.Printf("loadProduct: Error fetching product %d: %v", productID, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to fetch product")
+		return nil, false
+	}
+	return product, true
+}
+
+// apply переносит поля из запроса в продукт. Признак активности меняется, только если он передан.
+func (req *productRequest) apply(product *models.Product) *models.Product {
+	product.SKU = req.SKU
+	product.Name = req.Name
+	product.Category = req.Category
+	product.ListPrice = req.ListPrice
+	product.Currency = req.Currency
+	if req.IsActive != nil {
+		product.IsActive = *req.IsActive
+	}
+	return product
+}
+
+// validateProduct нормализует продукт и возвращает ошибки по полям.
+func validateProduct(product *models.Product) []middleware.ValidationError {
+	var errs []middleware.ValidationError
+	for _, e := range catalog.Normalize(product) {
+		errs = append(errs, middleware.ValidationError{Field: e.Field, Message: e.Message})
+	}
+	return errs
+}
+
+func respondProductSaveError(w http.ResponseWriter, handler string, err error) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		RespondWithError(w, http.StatusNotFound, "Product not found")
+	case db.IsUniqueConstraintViolation(err, "products_sku_key"):
+		RespondWithError(w, http.StatusConflict, "Product with this SKU already exists")
+	default:
+		log.Printf("%s: Error saving product: %v", handler, err)
+		RespondWithError(w, http.StatusInternalServerError, "Failed to save product")
+	}
+}
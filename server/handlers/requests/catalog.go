@@ -0,0 +1,64 @@
+package requests
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// linkCatalogItems связывает позиции с SKU с продуктами каталога и записывает в них текущую
+// прайсовую цену. SKU, которого нет в каталоге или который снят с продажи, — ошибка по полю
+// items[i].sku. Продукт, уже указанный в редактируемой заявке (previous), остается допустимым
+// и после снятия с продажи. При ошибке сам отправляет ответ и возвращает false.
+func (h *RequestHandler) linkCatalogItems(w http.ResponseWriter, r *http.Request, items, previous []models.RequestItem) bool {
+	var skus []string
+	for _, item := range items {
+		if item.SKU != nil {
+			skus = append(skus, *item.SKU)
+		}
+	}
+	if len(skus) == 0 {
+		return true
+	}
+	products, err := h.ProductRepo.FindProductsBySKU(r.Context(), skus)
+	if err != nil {
+		log.Printf("linkCatalogItems: Error fetching products by SKU: %v", err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check product catalog")
+		return false
+	}
+	kept := make(map[int]bool, len(previous))
+	for _, item := range previous {
+		if item.ProductID != nil {
+			kept[*item.ProductID] = true
+		}
+	}
+
+	var errs []middleware.ValidationError
+	for i := range items {
+		item := &items[i]
+		if item.SKU == nil {
+			continue
+		}
+		field := fmt.Sprintf("items[%d].sku", i)
+		product, found := products[*item.SKU]
+		switch {
+		case !found:
+			errs = append(errs, middleware.ValidationError{Field: field, Message: "Product with this SKU is not in the catalog"})
+		case !product.IsActive && !kept[product.ID]:
+			errs = append(errs, middleware.ValidationError{Field: field, Message: "Product with this SKU is discontinued"})
+		default:
+			item.ProductID = &product.ID
+			item.ListPrice = &product.ListPrice
+			item.ListCurrency = &product.Currency
+		}
+	}
+	if len(errs) > 0 {
+		handlers.RespondWithValidationErrors(w, errs)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/certificate"
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/workflow"
+	"github.com/gorilla/mux"
+)
+
+// errNotCertifiable — по заявке нельзя выдать свидетельство: сделка не зарегистрирована.
+var errNotCertifiable = errors.New("request is not approved")
+
+// GetMyCertificateHandler - PDF-свидетельство о регистрации одобренной сделки для её создателя
+func (h *RequestHandler) GetMyCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	_, requestID, ok := h.authorizeUserRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithCertificate(w, r, requestID)
+}
+
+// GetManagerCertificateHandler - PDF-свидетельство о регистрации сделки для ответственного менеджера
+func (h *RequestHandler) GetManagerCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	_, requestID, ok := h.authorizeManagerRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithCertificate(w, r, requestID)
+}
+
+// respondWithCertificate формирует свидетельство по заявке (доступ уже проверен вызывающим).
+// Свидетельство выдается только по одобренным или выполненным заявкам, не отозванным и не удаленным.
+func (h *RequestHandler) respondWithCertificate(w http.ResponseWriter, r *http.Request, requestID int) {
+	data, err := h.loadCertificate(r.Context(), requestID)
+	if err == nil && (data.Request.Withdrawn || !workflow.IsRegistered(data.Request.Status)) {
+		err = errNotCertifiable
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+		case errors.Is(err, errNotCertifiable):
+			handlers.RespondWithError(w, http.StatusConflict, "Certificate is only available for approved requests")
+		default:
+			log.Printf("respondWithCertificate: Error loading certificate data for request %d: %v", requestID, err)
+			handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch request details")
+		}
+		return
+	}
+	data.Code = h.Certificates.Code(requestID, data.ApprovedAt)
+	if base := certificateVerifyURL(); base != "" {
+		data.VerifyURL = base + data.Code
+	}
+
+	// Рендерим в буфер, чтобы при ошибке отдать JSON, а не обрезанный PDF
+	var buf bytes.Buffer
+	if err := certificate.Render(&buf, *data); err != nil {
+		log.Printf("respondWithCertificate: Error rendering certificate for request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to generate certificate")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"certificate_%d.pdf\"", requestID))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
+
+// VerifyCertificateHandler - публичная проверка свидетельства по коду (GET /api/verify/{code}).
+// Неверный, устаревший (после повторного одобрения) или чужой код неотличимы: всегда 404.
+func (h *RequestHandler) VerifyCertificateHandler(w http.ResponseWriter, r *http.Request) {
+	requestID, approvedAt, ok := h.Certificates.Parse(mux.Vars(r)["code"])
+	if !ok {
+		handlers.RespondWithError(w, http.StatusNotFound, "Certificate not found")
+		return
+	}
+
+	data, err := h.loadCertificate(r.Context(), requestID)
+	if err == nil && data.ApprovedAt.Unix() != approvedAt.Unix() {
+		err = errNotCertifiable
+	}
+	if err != nil {
+		if !errors.Is(err, db.ErrNotFound) && !errors.Is(err, errNotCertifiable) {
+			log.Printf("VerifyCertificateHandler: Error loading certificate data for request %d: %v", requestID, err)
+			handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to verify certificate")
+			return
+		}
+		handlers.RespondWithError(w, http.StatusNotFound, "Certificate not found")
+		return
+	}
+
+	req := data.Request
+	status, ok := workflow.Parse(string(req.Status))
+	if !ok {
+		status = req.Status
+	}
+	result := models.CertificateVerification{
+		CertificateNumber: req.ID,
+		Status:            status,
+		Withdrawn:         req.Withdrawn,
+		ValidFrom:         data.ApprovedAt,
+		ValidUntil:        req.ProtectedUntil,
+	}
+	if req.Partner != nil {
+		result.PartnerName = req.Partner.Name
+	}
+	if req.EndClient != nil {
+		result.EndClientName = req.EndClient.Name
+	}
+	today := protectionDate(time.Now())
+	result.Valid = workflow.IsRegistered(status) && !req.Withdrawn &&
+		(req.ProtectedUntil == nil || !today.After(*req.ProtectedUntil))
+
+	w.Header().Set("Cache-Control", "no-store")
+	handlers.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// loadCertificate загружает заявку и её одобрение: дату и менеджера последнего перехода
+// в зарегистрированный статус. Удаленные заявки считаются ненайденными; заявка без одобрения
+// в истории — errNotCertifiable.
+func (h *RequestHandler) loadCertificate(ctx context.Context, requestID int) (*certificate.Data, error) {
+	req, err := h.Repo.GetRequestDetailsByID(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req.DeletedAt != nil {
+		return nil, db.ErrNotFound
+	}
+	events, err := h.Repo.ListStatusEvents(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &certificate.Data{Request: req}
+	approved := false
+	for _, event := range events {
+		// События продления защиты и удаления пишутся без смены статуса, а "Одобрено" -> "Выполнено"
+		// не меняет регистрацию, поэтому учитываем только переход из незарегистрированного статуса
+		if !workflow.IsRegistered(models.RequestStatus(event.Status)) ||
+			(event.OldStatus != nil && workflow.IsRegistered(models.RequestStatus(*event.OldStatus))) {
+			continue
+		}
+		approved = true
+		data.ApprovedAt = event.ChangedAt
+		data.ManagerName = ""
+		if event.ManagerName != nil {
+			data.ManagerName = *event.ManagerName
+		}
+	}
+	if !approved {
+		return nil, errNotCertifiable
+	}
+	return data, nil
+}
@@ -0,0 +1,189 @@
+package requests
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/gorilla/mux"
+)
+
+// maxCommentLength — максимальная длина сообщения в символах
+const maxCommentLength = 5000
+
+// ListMyRequestCommentsHandler - переписка по заявке для её создателя
+func (h *RequestHandler) ListMyRequestCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, requestID, ok := h.authorizeUserRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithComments(w, r, requestID, userID)
+}
+
+// CreateMyRequestCommentHandler - новое сообщение партнера по своей заявке
+func (h *RequestHandler) CreateMyRequestCommentHandler(w http.ResponseWriter, r *http.Request) {
+	userID, requestID, ok := h.authorizeUserRequest(w, r)
+	if !ok {
+		return
+	}
+	h.createComment(w, r, requestID, userID)
+}
+
+// ListManagerRequestCommentsHandler - переписка по заявке для ответственного менеджера
+func (h *RequestHandler) ListManagerRequestCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, requestID, ok := h.authorizeManagerRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithComments(w, r, requestID, managerID)
+}
+
+// CreateManagerRequestCommentHandler - новое сообщение менеджера по заявке
+func (h *RequestHandler) CreateManagerRequestCommentHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, requestID, ok := h.authorizeManagerRequest(w, r)
+	if !ok {
+		return
+	}
+	h.createComment(w, r, requestID, managerID)
+}
+
+// authorizeUserRequest проверяет, что заявка из URL принадлежит текущему пользователю.
+// При ошибке сам отправляет ответ клиенту и возвращает false.
+func (h *RequestHandler) authorizeUserRequest(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return 0, 0, false
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return 0, 0, false
+	}
+
+	hasAccess, err := h.Repo.CheckUserAccess(r.Context(), userID, requestID)
+	if err != nil {
+		log.Printf("authorizeUserRequest: Error checking access for user %d, request %d: %v", userID, requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check access rights")
+		return 0, 0, false
+	}
+	if !hasAccess {
+		handlers.RespondWithError(w, http.StatusForbidden, "You do not have permission to access this request")
+		return 0, 0, false
+	}
+	return userID, requestID, true
+}
+
+// authorizeManagerRequest проверяет, что менеджер отвечает за партнера заявки из URL.
+// При ошибке сам отправляет ответ клиенту и возвращает false.
+func (h *RequestHandler) authorizeManagerRequest(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "Manager not authenticated")
+		return 0, 0, false
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return 0, 0, false
+	}
+
+	hasAccess, err := h.Repo.CheckManagerAccess(r.Context(), managerID, requestID)
+	if err != nil {
+		log.Printf("authorizeManagerRequest: Error checking manager access for manager %d, request %d: %v", managerID, requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check access rights")
+		return 0, 0, false
+	}
+	if !hasAccess {
+		handlers.RespondWithError(w, http.StatusForbidden, "Manager does not have permission to access this request")
+		return 0, 0, false
+	}
+	return managerID, requestID, true
+}
+
+// respondWithComments отдает переписку и отмечает её прочитанной пользователем (доступ уже проверен).
+func (h *RequestHandler) respondWithComments(w http.ResponseWriter, r *http.Request, requestID, userID int) {
+	comments, err := h.Repo.ListComments(r.Context(), requestID)
+	if err != nil {
+		log.Printf("respondWithComments: Error listing comments for request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch comments")
+		return
+	}
+
+	// Ошибка отметки прочтения не должна мешать чтению переписки
+	if err := h.Repo.MarkCommentsRead(r.Context(), requestID, userID); err != nil {
+		log.Printf("respondWithComments: Error marking comments read for request %d, user %d: %v", requestID, userID, err)
+	}
+
+	handlers.RespondWithJSON(w, http.StatusOK, comments)
+}
+
+// createComment принимает сообщение как JSON {"body": "..."} либо как multipart/form-data
+// с полем 'body' и файлами в 'files[]' (доступ уже проверен).
+func (h *RequestHandler) createComment(w http.ResponseWriter, r *http.Request, requestID, authorID int) {
+	var body string
+	var fileIDs []int
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		// Вложения сохраняются по мере загрузки; не привязанные к сообщению удаляются
+		form, ok := h.receiveForm(w, r, commentFilesField)
+		if !ok {
+			return
+		}
+		defer h.discardFiles(r.Context(), form.fileIDs)
+		body = form.values["body"]
+		fileIDs = form.fileIDs
+	} else {
+		var payload struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+		body = payload.Body
+	}
+
+	body = strings.TrimSpace(body)
+	if body == "" {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Comment body is required")
+		return
+	}
+	if utf8.RuneCountInString(body) > maxCommentLength {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Comment body is too long")
+		return
+	}
+
+	comment := &models.Comment{
+		RequestID: requestID,
+		AuthorID:  &authorID,
+		Body:      body,
+	}
+	if err := h.Repo.CreateComment(r.Context(), comment, fileIDs); err != nil {
+		log.Printf("createComment: Error creating comment for request %d by user %d: %v", requestID, authorID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to create comment")
+		return
+	}
+
+	// Возвращаем сообщение в том же виде, что и в списке (с автором и вложениями)
+	comments, err := h.Repo.ListComments(r.Context(), requestID)
+	if err == nil {
+		for _, c := range comments {
+			if c.ID == comment.ID {
+				handlers.RespondWithJSON(w, http.StatusCreated, c)
+				return
+			}
+		}
+	}
+	handlers.RespondWithJSON(w, http.StatusCreated, comment)
+}
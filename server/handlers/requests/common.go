@@ -1,6 +1,13 @@
 package requests
 
-import "github.com/eeephemera/zvk-requests/server/db"
+import (
+	"os"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/certificate"
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/jobs"
+)
 
 // RequestHandler содержит зависимости для обработчиков запросов.
 type RequestHandler struct {
@@ -8,6 +15,9 @@ type RequestHandler struct {
 	UserRepo      *db.UserRepository
 	PartnerRepo   *db.PartnerRepository // Добавлено, если нужно для логики
 	EndClientRepo *db.EndClientRepository
+	ProductRepo   *db.ProductRepository
+	RateRepo      *db.ExchangeRateRepository
+	Certificates  *certificate.Signer // Подпись кодов проверки свидетельств о регистрации сделок
 }
 
 // NewRequestHandler создает новый RequestHandler.
@@ -17,13 +27,41 @@ func NewRequestHandler(
 	userRepo *db.UserRepository,
 	partnerRepo *db.PartnerRepository,
 	endClientRepo *db.EndClientRepository,
+	productRepo *db.ProductRepository,
+	rateRepo *db.ExchangeRateRepository,
+	certificates *certificate.Signer,
 ) *RequestHandler {
 	return &RequestHandler{
 		Repo:          repo,
 		UserRepo:      userRepo,
 		PartnerRepo:   partnerRepo,
 		EndClientRepo: endClientRepo,
+		ProductRepo:   productRepo,
+		RateRepo:      rateRepo,
+		Certificates:  certificates,
+	}
+}
+
+// withdrawalGracePeriod возвращает срок, в течение которого партнер может
+// восстановить отозванную заявку (WITHDRAWAL_GRACE_PERIOD, по умолчанию 7 дней).
+func withdrawalGracePeriod() time.Duration {
+	period, err := time.ParseDuration(os.Getenv("WITHDRAWAL_GRACE_PERIOD"))
+	if err != nil || period <= 0 {
+		period = 7 * 24 * time.Hour
 	}
+	return period
+}
+
+// dealProtectionPeriod возвращает срок защиты сделки после одобрения
+// (DEAL_PROTECTION_PERIOD, по умолчанию 90 дней).
+func dealProtectionPeriod() time.Duration {
+	return jobs.DurationFromEnv(os.Getenv("DEAL_PROTECTION_PERIOD"), 90*24*time.Hour)
+}
+
+// certificateVerifyURL возвращает адрес публичной проверки свидетельства, к которому дописывается код
+// (CERTIFICATE_VERIFY_URL, например https://deals.example.com/api/verify/). Пусто — адрес не печатается.
+func certificateVerifyURL() string {
+	return os.Getenv("CERTIFICATE_VERIFY_URL")
 }
 
 // Здесь могут быть другие общие функции или типы для пакета requests
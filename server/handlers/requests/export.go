@@ -0,0 +1,100 @@
+package requests
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/export"
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/workflow"
+)
+
+// exportWriteTimeout — время на запись выгрузки клиенту; больше общего WriteTimeout сервера,
+// так как выгружаются все заявки без пагинации.
+const exportWriteTimeout = 10 * time.Minute
+
+// ExportManagerRequestsHandler - выгрузка заявок менеджера в CSV или XLSX (GET /api/manager/requests/export)
+// Принимает те же фильтры и сортировку, что и ListManagerRequestsHandler, и отдает все подходящие заявки
+// потоком, без пагинации.
+func (h *RequestHandler) ExportManagerRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "Manager not authenticated")
+		return
+	}
+
+	format, ok := export.ParseFormat(r.URL.Query().Get("format"))
+	if !ok {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid format: must be csv or xlsx")
+		return
+	}
+	var statusFilter models.RequestStatus
+	if statusFilterStr := r.URL.Query().Get("status"); statusFilterStr != "" {
+		statusFilter, ok = workflow.Parse(statusFilterStr)
+		if !ok {
+			handlers.RespondWithError(w, http.StatusBadRequest, "Invalid status filter value")
+			return
+		}
+	}
+
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportWriteTimeout)); err != nil {
+		log.Printf("ExportManagerRequestsHandler: Could not extend write deadline: %v", err)
+	}
+
+	// Заголовки и начало файла отправляются только с первой строкой: если запрос к БД
+	// завершится ошибкой сразу, клиент еще получит JSON с ошибкой.
+	var out export.Writer
+	started := false
+	start := func() error {
+		started = true
+		filename := fmt.Sprintf("requests_%s.%s", time.Now().Format("2006-01-02"), format)
+		w.Header().Set("Content-Type", format.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		var err error
+		out, err = export.NewWriter(format, w, "Заявки")
+		if err != nil {
+			return err
+		}
+		return out.WriteRow(export.RequestHeader())
+	}
+
+	err := h.Repo.ExportRequestsForManager(
+		r.Context(), managerID,
+		statusFilter, r.URL.Query().Get("organization_name"), r.URL.Query().Get("client"),
+		r.URL.Query().Get("sortBy"), r.URL.Query().Get("sortOrder"),
+		func(req *models.Request) error {
+			if !started {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+			return out.WriteRow(export.RequestRow(req))
+		},
+	)
+	if err != nil {
+		log.Printf("ExportManagerRequestsHandler: Error exporting requests for manager %d: %v", managerID, err)
+		if !started {
+			handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to export requests")
+			return
+		}
+		// Файл уже частично отправлен — обрываем соединение, чтобы клиент не принял его за полный
+		panic(http.ErrAbortHandler)
+	}
+	if !started {
+		// Нет подходящих заявок — файл только с заголовком
+		if err := start(); err != nil {
+			log.Printf("ExportManagerRequestsHandler: Error writing export for manager %d: %v", managerID, err)
+			panic(http.ErrAbortHandler)
+		}
+	}
+	if err := out.Close(); err != nil {
+		log.Printf("ExportManagerRequestsHandler: Error finishing export for manager %d: %v", managerID, err)
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -7,7 +7,6 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/eeephemera/zvk-requests/server/db"
@@ -17,6 +16,10 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// downloadWriteTimeout — время на отправку файла клиенту; больше общего WriteTimeout сервера,
+// так как крупный файл на медленном канале или из S3 передается дольше.
+const downloadWriteTimeout = 10 * time.Minute
+
 // DownloadFileHandler обрабатывает запрос на скачивание файла.
 // Выполняет строгую проверку доступа для всех ролей и поддерживает Range/ETag.
 func (h *RequestHandler) DownloadFileHandler(w http.ResponseWriter, r *http.Request) {
@@ -64,104 +67,32 @@ func (h *RequestHandler) DownloadFileHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// 5. Получаем содержимое файла
-	fileData, err := h.Repo.GetFileDataByID(r.Context(), fileID)
+	// 5. Открываем содержимое файла в хранилище
+	content, err := h.Repo.OpenFile(r.Context(), fileID)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
 			handlers.RespondWithError(w, http.StatusNotFound, "File data not found")
 			return
 		}
-		log.Printf("DownloadFileHandler: Error getting file data for ID %d: %v", fileID, err)
+		log.Printf("DownloadFileHandler: Error opening file data for ID %d: %v", fileID, err)
 		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve file data")
 		return
 	}
+	defer content.Close()
 
-	// 6. Заголовки кеширования/валидации
-	etag := fmt.Sprintf("\"file-%d-%d-%d\"", fileInfo.ID, fileInfo.FileSize, fileInfo.CreatedAt.Unix())
-	w.Header().Set("ETag", etag)
-	// Для чувствительных файлов избегаем кэширования на клиенте/прокси
+	// 6. Заголовки. Для чувствительных файлов избегаем кэширования на клиенте/прокси;
+	// ETag и Last-Modified позволяют докачку (If-Range) и условные запросы
+	w.Header().Set("ETag", fmt.Sprintf("\"file-%d-%d-%d\"", fileInfo.ID, fileInfo.FileSize, fileInfo.CreatedAt.Unix()))
 	w.Header().Set("Cache-Control", "no-store")
-	w.Header().Set("Accept-Ranges", "bytes")
-	lastModified := fileInfo.CreatedAt.UTC().Format(http.TimeFormat)
-	w.Header().Set("Last-Modified", lastModified)
-
-	if inm := r.Header.Get("If-None-Match"); inm != "" && strings.Contains(inm, etag) {
-		w.WriteHeader(http.StatusNotModified)
-		return
-	}
-	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
-		if t, err := time.Parse(http.TimeFormat, ims); err == nil {
-			if !fileInfo.CreatedAt.After(t) {
-				w.WriteHeader(http.StatusNotModified)
-				return
-			}
-		}
-	}
-
-	// 7. Контент и имя файла (санитизированное)
-	cleanName := utils.SanitizeFilename(fileInfo.FileName)
 	w.Header().Set("Content-Type", fileInfo.MimeType)
-	disposition := fmt.Sprintf("attachment; filename*=UTF-8''%s", url.QueryEscape(cleanName))
-	w.Header().Set("Content-Disposition", disposition)
-
-	// 8. Поддержка Range-запросов
-	rangeHeader := r.Header.Get("Range")
-	total := int64(len(fileData))
-	if rangeHeader == "" {
-		w.Header().Set("Content-Length", strconv.FormatInt(total, 10))
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write(fileData)
-		return
-	}
+	cleanName := utils.SanitizeFilename(fileInfo.FileName)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(cleanName)))
 
-	// Пример Range: bytes=start-end
-	if !strings.HasPrefix(rangeHeader, "bytes=") {
-		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-		return
-	}
-	parts := strings.Split(strings.TrimPrefix(rangeHeader, "bytes="), ",")
-	// Поддерживаем только один диапазон
-	rangeSpec := strings.TrimSpace(parts[0])
-	se := strings.Split(rangeSpec, "-")
-	if len(se) != 2 {
-		w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-		return
-	}
-	var start, end int64
-	var perr error
-	if se[0] == "" { // suffix bytes: -N
-		// последние N байт
-		n, perr := strconv.ParseInt(se[1], 10, 64)
-		if perr != nil || n <= 0 {
-			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-			return
-		}
-		if n > total {
-			n = total
-		}
-		start = total - n
-		end = total - 1
-	} else {
-		start, perr = strconv.ParseInt(se[0], 10, 64)
-		if perr != nil || start < 0 || start >= total {
-			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-			return
-		}
-		if se[1] == "" {
-			end = total - 1
-		} else {
-			end, perr = strconv.ParseInt(se[1], 10, 64)
-			if perr != nil || end < start || end >= total {
-				w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
-				return
-			}
-		}
+	// 7. Отдаем содержимое потоком; ServeContent обрабатывает Range, If-None-Match и If-Modified-Since
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Now().Add(downloadWriteTimeout)); err != nil {
+		log.Printf("DownloadFileHandler: Could not extend write deadline: %v", err)
 	}
-	length := end - start + 1
-	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, total))
-	w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
-	w.WriteHeader(http.StatusPartialContent)
-	_, _ = w.Write(fileData[start : end+1])
+	http.ServeContent(w, r, cleanName, fileInfo.CreatedAt, content)
 }
 
 // ListRequestFilesForManager возвращает список файлов заявки для менеджера
@@ -0,0 +1,261 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/currency"
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/pricing"
+	"github.com/shopspring/decimal"
+)
+
+// requestDataDTO — данные заявки из JSON-поля 'request_data' multipart-формы.
+// Используется и при создании, и при редактировании заявки партнером.
+type requestDataDTO struct {
+	EndClientID              *int   `json:"end_client_id"`  // Клиент, выбранный из справочника (/api/requests/my/end-clients)
+	EndClientINN             string `json:"end_client_inn"` // ИНН для поиска/создания клиента
+	EndClientKPP             string `json:"end_client_kpp"`
+	EndClientOGRN            string `json:"end_client_ogrn"`
+	EndClientName            string `json:"end_client_name"`
+	EndClientCity            string `json:"end_client_city"`
+	EndClientFullAddress     string `json:"end_client_full_address"`
+	EndClientContactDetails  string `json:"end_client_contact_details"`
+	EndClientDetailsOverride string `json:"end_client_details_override"` // Если ИНН не указан
+	DistributorID            *int   `json:"distributor_id"`
+	PartnerContactOverride   string `json:"partner_contact_override"`
+	FZLawType                string `json:"fz_law_type"`
+	MPTRegistryType          string `json:"mpt_registry_type"`
+	PartnerActivities        string `json:"partner_activities"`
+	DealStateDescription     string `json:"deal_state_description"`
+	EstimatedCloseDateStr    string `json:"estimated_close_date"` // Дата как строка YYYY-MM-DD
+	// Проект, товарные позиции и валюта их цен (ISO 4217, по умолчанию RUB)
+	ProjectName string           `json:"project_name"`
+	Items       []requestItemDTO `json:"items"`
+	Currency    string           `json:"currency"`
+	// Устаревшая единственная позиция: используется, только если items не переданы
+	Quantity  *int    `json:"quantity"`
+	UnitPrice *string `json:"unit_price"` // Принимаем как строку для гибкости
+
+	// Только для редактирования: файлы, которые нужно открепить, и комментарий партнера
+	RemoveFileIDs []int  `json:"remove_file_ids"`
+	Comment       string `json:"comment"`
+}
+
+// requestItemDTO — товарная позиция из request_data. Цены принимаются строкой или числом,
+// итог строки клиент не передает: его рассчитывает сервер.
+type requestItemDTO struct {
+	ProductName     string          `json:"product_name"`
+	SKU             string          `json:"sku"`
+	Quantity        int             `json:"quantity"`
+	UnitPrice       decimal.Decimal `json:"unit_price"`
+	DiscountPercent decimal.Decimal `json:"discount_percent"`
+}
+
+// receiveRequestForm читает форму заявки потоком: JSON из поля 'request_data' и файлы
+// из requestFilesField, которые сразу сохраняются (см. receiveForm). Вызывающий должен
+// передать возвращенные ID в discardFiles, когда заявка сохранена или отвергнута.
+// При ошибке сам отправляет ответ клиенту и возвращает false.
+func (h *RequestHandler) receiveRequestForm(w http.ResponseWriter, r *http.Request) (*requestDataDTO, []int, bool) {
+	form, ok := h.receiveForm(w, r, requestFilesField)
+	if !ok {
+		return nil, nil, false
+	}
+
+	requestDataJSON := form.values["request_data"]
+	if requestDataJSON == "" {
+		h.discardFiles(r.Context(), form.fileIDs)
+		handlers.RespondWithError(w, http.StatusBadRequest, "Missing 'request_data' field in form")
+		return nil, nil, false
+	}
+
+	// Используем DTO, чтобы отделить данные запроса от полной модели
+	var dto requestDataDTO
+	if err := json.Unmarshal([]byte(requestDataJSON), &dto); err != nil {
+		h.discardFiles(r.Context(), form.fileIDs)
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid JSON in 'request_data': "+err.Error())
+		return nil, nil, false
+	}
+	return &dto, form.fileIDs, true
+}
+
+// resolveEndClient возвращает клиента, выбранного из справочника по end_client_id,
+// иначе находит конечного клиента по ИНН или создает нового.
+// Возвращает nil, если не указаны ни ID, ни ИНН. При ошибке сам отправляет ответ и возвращает false.
+func (h *RequestHandler) resolveEndClient(w http.ResponseWriter, r *http.Request, dto *requestDataDTO) (*int, bool) {
+	if dto.EndClientID != nil {
+		// Выбранный клиент мог быть объединен с другим — ссылаемся на основного
+		client, err := h.EndClientRepo.ResolveEndClient(r.Context(), *dto.EndClientID)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				handlers.RespondWithError(w, http.StatusBadRequest, "End client not found")
+				return nil, false
+			}
+			log.Printf("resolveEndClient: Error fetching end client %d: %v", *dto.EndClientID, err)
+			handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check end client existence")
+			return nil, false
+		}
+		return &client.ID, true
+	}
+	dto.EndClientINN = strings.TrimSpace(dto.EndClientINN)
+	if dto.EndClientINN == "" {
+		return nil, true
+	}
+	kpp, ogrn := stringToPtr(strings.TrimSpace(dto.EndClientKPP)), stringToPtr(strings.TrimSpace(dto.EndClientOGRN))
+	if errs := handlers.ValidateRequisites("end_client_", dto.EndClientINN, kpp, ogrn); len(errs) > 0 {
+		handlers.RespondWithValidationErrors(w, errs)
+		return nil, false
+	}
+
+	// Пытаемся найти клиента по ИНН
+	foundClient, err := h.EndClientRepo.FindEndClientByINN(r.Context(), dto.EndClientINN)
+	if err != nil {
+		log.Printf("resolveEndClient: Error finding end client by INN %s: %v", dto.EndClientINN, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check end client existence")
+		return nil, false
+	}
+	if foundClient != nil {
+		return &foundClient.ID, true
+	}
+
+	// Если не найден, создаем нового
+	newClient := &models.EndClient{
+		Name:                 dto.EndClientName, // Нужно передавать все поля
+		City:                 stringToPtr(dto.EndClientCity),
+		INN:                  stringToPtr(dto.EndClientINN),
+		KPP:                  kpp,
+		OGRN:                 ogrn,
+		FullAddress:          stringToPtr(dto.EndClientFullAddress),
+		ContactPersonDetails: stringToPtr(dto.EndClientContactDetails),
+	}
+	if err := h.EndClientRepo.CreateEndClient(r.Context(), newClient); err != nil {
+		log.Printf("resolveEndClient: Error creating new end client with INN %s: %v", dto.EndClientINN, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to create new end client")
+		return nil, false
+	}
+	return &newClient.ID, true
+}
+
+// checkExchangeRate проверяет, что для валюты заявки задан курс к рублю. Окончательная проверка —
+// в транзакции сохранения; ранняя отвечает ошибкой по полю currency до сохранения заявки.
+// При ошибке сам отправляет ответ и возвращает false.
+func (h *RequestHandler) checkExchangeRate(w http.ResponseWriter, r *http.Request, code string) bool {
+	if code == currency.Base {
+		return true
+	}
+	if _, err := h.RateRepo.GetExchangeRate(r.Context(), code); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			respondWithNoExchangeRate(w, code)
+			return false
+		}
+		log.Printf("checkExchangeRate: Error fetching exchange rate %s: %v", code, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check exchange rate")
+		return false
+	}
+	return true
+}
+
+// respondWithInvalidDeal отвечает на ошибку applyTo: ошибки позиций и сумм — по полям.
+func respondWithInvalidDeal(w http.ResponseWriter, err error) {
+	var fieldErr *pricing.FieldError
+	if errors.As(err, &fieldErr) {
+		handlers.RespondWithValidationErrors(w, []middleware.ValidationError{{Field: fieldErr.Field, Message: fieldErr.Message}})
+		return
+	}
+	handlers.RespondWithError(w, http.StatusBadRequest, err.Error())
+}
+
+func respondWithNoExchangeRate(w http.ResponseWriter, code string) {
+	handlers.RespondWithValidationErrors(w, []middleware.ValidationError{{
+		Field:   "currency",
+		Message: "Exchange rate for " + code + " is not set; ask a manager to add it",
+	}})
+}
+
+// applyTo переносит поля сделки из DTO в заявку, разбирая дату и вычисляя цены.
+// Поля владельца, статус и конечный клиент не затрагиваются. Ошибки позиций и сумм — *pricing.FieldError.
+func (dto *requestDataDTO) applyTo(req *models.Request) error {
+	var estimatedCloseDate *time.Time
+	if dto.EstimatedCloseDateStr != "" {
+		parsedDate, err := time.Parse("2006-01-02", dto.EstimatedCloseDateStr)
+		if err != nil {
+			return errors.New("Invalid format for estimated_close_date (use YYYY-MM-DD)")
+		}
+		estimatedCloseDate = &parsedDate
+	}
+
+	// Проверяем валюту и позиции, вычисляем цены
+	code := currency.Normalize(dto.Currency)
+	if err := currency.Validate(code); err != nil {
+		return errors.New("Invalid currency: " + err.Error())
+	}
+	items, err := dto.requestItems()
+	if err != nil {
+		return err
+	}
+	if err := pricing.Prepare(items); err != nil {
+		return err
+	}
+	var totalPrice *decimal.Decimal
+	if len(items) > 0 {
+		total := pricing.Total(items)
+		totalPrice = &total
+	}
+
+	req.EndClientDetailsOverride = stringToPtr(dto.EndClientDetailsOverride)
+	req.DistributorID = dto.DistributorID
+	req.PartnerContactOverride = stringToPtr(dto.PartnerContactOverride)
+	req.FZLawType = stringToPtr(dto.FZLawType)
+	req.MPTRegistryType = stringToPtr(dto.MPTRegistryType)
+	req.PartnerActivities = stringToPtr(dto.PartnerActivities)
+	req.DealStateDescription = stringToPtr(dto.DealStateDescription)
+	req.EstimatedCloseDate = estimatedCloseDate
+	req.ProjectName = stringToPtr(dto.ProjectName)
+	req.Items = items
+	req.TotalPrice = totalPrice
+	req.Currency = code
+	return nil
+}
+
+// requestItems возвращает позиции из items, а если их нет — единственную позицию
+// из устаревших полей quantity и unit_price с названием проекта в качестве товара.
+func (dto *requestDataDTO) requestItems() ([]models.RequestItem, error) {
+	if len(dto.Items) > 0 {
+		items := make([]models.RequestItem, len(dto.Items))
+		for i, it := range dto.Items {
+			items[i] = models.RequestItem{
+				ProductName:     it.ProductName,
+				SKU:             stringToPtr(it.SKU),
+				Quantity:        it.Quantity,
+				UnitPrice:       it.UnitPrice,
+				DiscountPercent: it.DiscountPercent,
+			}
+		}
+		return items, nil
+	}
+	if dto.Quantity == nil || dto.UnitPrice == nil || *dto.UnitPrice == "" {
+		return nil, nil
+	}
+	unitPrice, err := decimal.NewFromString(*dto.UnitPrice)
+	if err != nil {
+		return nil, errors.New("Invalid format for unit_price")
+	}
+	name := strings.TrimSpace(dto.ProjectName)
+	if name == "" {
+		name = "Позиция 1"
+	}
+	return []models.RequestItem{{ProductName: name, Quantity: *dto.Quantity, UnitPrice: unitPrice}}, nil
+}
+
+// Поля multipart-формы с файлами. Ключи должны соответствовать тому, как FormData на клиенте добавляет файлы.
+const (
+	requestFilesField = "overall_tz_files[]"
+	commentFilesField = "files[]"
+)
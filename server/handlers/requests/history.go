@@ -0,0 +1,78 @@
+package requests
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/gorilla/mux"
+)
+
+// GetMyRequestHistoryHandler - история статусов заявки для её создателя
+func (h *RequestHandler) GetMyRequestHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return
+	}
+
+	hasAccess, err := h.Repo.CheckUserAccess(r.Context(), userID, requestID)
+	if err != nil {
+		log.Printf("GetMyRequestHistoryHandler: Error checking access for user %d, request %d: %v", userID, requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check access rights")
+		return
+	}
+	if !hasAccess {
+		handlers.RespondWithError(w, http.StatusForbidden, "You do not have permission to view this request")
+		return
+	}
+
+	h.respondWithHistory(w, r, requestID)
+}
+
+// GetManagerRequestHistoryHandler - история статусов заявки для ответственного менеджера
+func (h *RequestHandler) GetManagerRequestHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "Manager not authenticated")
+		return
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return
+	}
+
+	hasAccess, err := h.Repo.CheckManagerAccess(r.Context(), managerID, requestID)
+	if err != nil {
+		log.Printf("GetManagerRequestHistoryHandler: Error checking manager access for manager %d, request %d: %v", managerID, requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check access rights")
+		return
+	}
+	if !hasAccess {
+		handlers.RespondWithError(w, http.StatusForbidden, "Manager does not have permission to view this request")
+		return
+	}
+
+	h.respondWithHistory(w, r, requestID)
+}
+
+// respondWithHistory отдает хронологию статусов заявки (доступ уже проверен вызывающим).
+func (h *RequestHandler) respondWithHistory(w http.ResponseWriter, r *http.Request, requestID int) {
+	events, err := h.Repo.ListStatusEvents(r.Context(), requestID)
+	if err != nil {
+		log.Printf("respondWithHistory: Error listing status events for request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch request history")
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, events)
+}
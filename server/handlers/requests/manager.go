@@ -6,11 +6,13 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/eeephemera/zvk-requests/server/db"
 	"github.com/eeephemera/zvk-requests/server/handlers"
 	"github.com/eeephemera/zvk-requests/server/middleware"
 	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/workflow"
 	"github.com/gorilla/mux"
 )
 
@@ -40,8 +42,9 @@ func (h *RequestHandler) UpdateRequestStatusHandler(w http.ResponseWriter, r *ht
 
 	// 3. Декодируем тело запроса (ожидаем JSON с новым статусом и комментарием)
 	var payload struct {
-		Status  models.RequestStatus `json:"status"`
-		Comment string               `json:"comment"`
+		Status         string `json:"status"`
+		Comment        string `json:"comment"`
+		ProtectedUntil string `json:"protected_until"` // YYYY-MM-DD, только при одобрении
 	}
 	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
@@ -49,11 +52,28 @@ func (h *RequestHandler) UpdateRequestStatusHandler(w http.ResponseWriter, r *ht
 	}
 
 	// 4. Проверяем, что статус валиден
-	if !isValidRequestStatus(payload.Status) {
+	newStatus, ok := workflow.Parse(payload.Status)
+	if !ok {
 		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid status value")
 		return
 	}
 
+	// При одобрении сделка защищается на dealProtectionPeriod, если менеджер не указал дату сам
+	var protectedUntil *time.Time
+	if newStatus == models.StatusApproved {
+		until := protectionDate(time.Now().Add(dealProtectionPeriod()))
+		if payload.ProtectedUntil != "" {
+			if until, err = parseFutureDate(payload.ProtectedUntil); err != nil {
+				handlers.RespondWithError(w, http.StatusBadRequest, "Invalid protected_until: "+err.Error())
+				return
+			}
+		}
+		protectedUntil = &until
+	} else if payload.ProtectedUntil != "" {
+		handlers.RespondWithError(w, http.StatusBadRequest, "protected_until can only be set when approving a request")
+		return
+	}
+
 	// 5. Проверяем права доступа менеджера к этой заявке
 	hasAccess, err := h.Repo.CheckManagerAccess(r.Context(), managerID, requestID)
 	if err != nil {
@@ -67,12 +87,21 @@ func (h *RequestHandler) UpdateRequestStatusHandler(w http.ResponseWriter, r *ht
 	}
 
 	// 6. Обновляем статус в репозитории
-	err = h.Repo.UpdateRequestStatus(r.Context(), requestID, payload.Status, &payload.Comment)
+	err = h.Repo.UpdateRequestStatus(r.Context(), requestID, newStatus, &payload.Comment, managerID, protectedUntil)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
 			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
 			return
 		}
+		var transitionErr *workflow.TransitionError
+		if errors.As(err, &transitionErr) {
+			respondWithTransitionError(w, transitionErr)
+			return
+		}
+		if errors.Is(err, db.ErrInvalidState) {
+			handlers.RespondWithError(w, http.StatusConflict, "Request is withdrawn by partner or deleted")
+			return
+		}
 		log.Printf("UpdateRequestStatusHandler: Error updating status for request %d: %v", requestID, err)
 		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to update request status")
 		return
@@ -118,8 +147,8 @@ func (h *RequestHandler) ListManagerRequestsHandler(w http.ResponseWriter, r *ht
 
 	var statusFilter models.RequestStatus
 	if statusFilterStr != "" {
-		statusFilter = models.RequestStatus(statusFilterStr)
-		if !isValidRequestStatus(statusFilter) {
+		statusFilter, ok = workflow.Parse(statusFilterStr)
+		if !ok {
 			handlers.RespondWithError(w, http.StatusBadRequest, "Invalid status filter value")
 			return
 		}
@@ -189,10 +218,24 @@ func (h *RequestHandler) GetManagerRequestDetailsHandler(w http.ResponseWriter,
 		return
 	}
 
+	// Заявки других партнеров на ту же сделку
+	conflicts, err := h.Repo.ListRequestConflicts(r.Context(), requestID)
+	if err != nil {
+		log.Printf("GetManagerRequestDetailsHandler: Error fetching conflicts for request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch request conflicts")
+		return
+	}
+	req.Conflicts = conflicts
+	for _, c := range conflicts {
+		if c.Direction == models.ConflictEarlier {
+			req.IsConflicting = true
+		}
+	}
+
 	handlers.RespondWithJSON(w, http.StatusOK, req)
 }
 
-// DeleteManagerRequestHandler - удаление заявки менеджером
+// DeleteManagerRequestHandler - удаление заявки менеджером (перемещение в корзину)
 func (h *RequestHandler) DeleteManagerRequestHandler(w http.ResponseWriter, r *http.Request) {
 	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
 	if !ok {
@@ -220,8 +263,8 @@ func (h *RequestHandler) DeleteManagerRequestHandler(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Удаляем заявку
-	err = h.Repo.DeleteRequest(r.Context(), requestID)
+	// Перемещаем заявку в корзину
+	err = h.Repo.DeleteRequest(r.Context(), requestID, managerID)
 	if err != nil {
 		if errors.Is(err, db.ErrNotFound) {
 			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
@@ -240,19 +283,20 @@ func (h *RequestHandler) DeleteManagerRequestHandler(w http.ResponseWriter, r *h
 // ...
 // }
 
-// isValidRequestStatus проверяет, является ли переданный статус одним из допустимых.
-// Используем карту для быстрой проверки.
-func isValidRequestStatus(status models.RequestStatus) bool {
-	switch status {
-	case
-		"На рассмотрении",
-		"В работе",
-		"На уточнении",
-		"Одобрена",
-		"Отклонена",
-		"Завершена":
-		return true
-	default:
-		return false
-	}
+// TransitionErrorResponse — ответ 409 на недопустимый переход статуса
+type TransitionErrorResponse struct {
+	Error         string                 `json:"error"`
+	CurrentStatus models.RequestStatus   `json:"current_status"`
+	Requested     models.RequestStatus   `json:"requested_status"`
+	Allowed       []models.RequestStatus `json:"allowed_statuses"`
+}
+
+// respondWithTransitionError отправляет 409 со списком допустимых следующих статусов.
+func respondWithTransitionError(w http.ResponseWriter, err *workflow.TransitionError) {
+	handlers.RespondWithJSON(w, http.StatusConflict, TransitionErrorResponse{
+		Error:         "Status transition is not allowed",
+		CurrentStatus: err.From,
+		Requested:     err.To,
+		Allowed:       err.Allowed,
+	})
 }
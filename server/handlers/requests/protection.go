@@ -0,0 +1,217 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/gorilla/mux"
+)
+
+// protectionDate отбрасывает время суток: защита сделки задается с точностью до дня.
+func protectionDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseFutureDate разбирает дату YYYY-MM-DD и проверяет, что она позже сегодняшней.
+func parseFutureDate(value string) (time.Time, error) {
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("use YYYY-MM-DD")
+	}
+	if !date.After(protectionDate(time.Now())) {
+		return time.Time{}, errors.New("date must be in the future")
+	}
+	return date, nil
+}
+
+// CreateMyExtensionHandler - запрос партнера на продление защиты своей одобренной заявки.
+// Ожидает JSON {"requested_until": "YYYY-MM-DD", "reason": "..."}.
+func (h *RequestHandler) CreateMyExtensionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, requestID, ok := h.authorizeUserRequest(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		RequestedUntil string `json:"requested_until"`
+		Reason         string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	requestedUntil, err := parseFutureDate(payload.RequestedUntil)
+	if err != nil {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid requested_until: "+err.Error())
+		return
+	}
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if payload.Reason == "" {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Extension reason is required")
+		return
+	}
+	if utf8.RuneCountInString(payload.Reason) > maxCommentLength {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Extension reason is too long")
+		return
+	}
+
+	ext := &models.RequestExtension{
+		RequestID:      requestID,
+		RequestedUntil: requestedUntil,
+		Reason:         payload.Reason,
+	}
+	if err := h.Repo.CreateExtension(r.Context(), ext, userID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+			return
+		}
+		if errors.Is(err, db.ErrInvalidState) || db.IsUniqueConstraintViolation(err, "idx_request_extensions_pending_request") {
+			handlers.RespondWithError(w, http.StatusConflict, "Extension can only be requested for an approved request without a pending extension, and for a date later than the current protection end")
+			return
+		}
+		log.Printf("CreateMyExtensionHandler: Error creating extension for request %d by user %d: %v", requestID, userID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to request extension")
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusCreated, ext)
+}
+
+// ListMyExtensionsHandler - запросы на продление защиты заявки для её создателя
+func (h *RequestHandler) ListMyExtensionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, requestID, ok := h.authorizeUserRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithExtensions(w, r, requestID)
+}
+
+// ListManagerExtensionsHandler - запросы на продление защиты заявки для ответственного менеджера
+func (h *RequestHandler) ListManagerExtensionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, requestID, ok := h.authorizeManagerRequest(w, r)
+	if !ok {
+		return
+	}
+	h.respondWithExtensions(w, r, requestID)
+}
+
+// ApproveExtensionHandler - одобрение продления защиты менеджером.
+// Тело необязательно: {"protected_until": "YYYY-MM-DD", "comment": "..."}; без даты защита
+// продлевается до запрошенной партнером. Дата должна быть позже текущего окончания защиты.
+func (h *RequestHandler) ApproveExtensionHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, requestID, ok := h.authorizeManagerRequest(w, r)
+	if !ok {
+		return
+	}
+	extensionID, ok := extensionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		ProtectedUntil string `json:"protected_until"`
+		Comment        string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	var until *time.Time
+	if payload.ProtectedUntil != "" {
+		date, err := parseFutureDate(payload.ProtectedUntil)
+		if err != nil {
+			handlers.RespondWithError(w, http.StatusBadRequest, "Invalid protected_until: "+err.Error())
+			return
+		}
+		until = &date
+	}
+
+	ext, err := h.Repo.ApproveExtension(r.Context(), requestID, extensionID, managerID, until, stringToPtr(strings.TrimSpace(payload.Comment)))
+	if errors.Is(err, db.ErrProtectionNotExtended) {
+		handlers.RespondWithValidationErrors(w, []middleware.ValidationError{{
+			Field:   "protected_until",
+			Message: "Must be later than the current protection end date",
+		}})
+		return
+	}
+	if err != nil {
+		h.respondWithExtensionError(w, "ApproveExtensionHandler", extensionID, err)
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, ext)
+}
+
+// RejectExtensionHandler - отклонение продления защиты менеджером, комментарий обязателен.
+func (h *RequestHandler) RejectExtensionHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, requestID, ok := h.authorizeManagerRequest(w, r)
+	if !ok {
+		return
+	}
+	extensionID, ok := extensionIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Comment string `json:"comment"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	payload.Comment = strings.TrimSpace(payload.Comment)
+	if payload.Comment == "" {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Rejection comment is required")
+		return
+	}
+
+	ext, err := h.Repo.RejectExtension(r.Context(), requestID, extensionID, managerID, payload.Comment)
+	if err != nil {
+		h.respondWithExtensionError(w, "RejectExtensionHandler", extensionID, err)
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, ext)
+}
+
+// respondWithExtensions отдает запросы на продление защиты заявки (доступ уже проверен).
+func (h *RequestHandler) respondWithExtensions(w http.ResponseWriter, r *http.Request, requestID int) {
+	extensions, err := h.Repo.ListExtensions(r.Context(), requestID)
+	if err != nil {
+		log.Printf("respondWithExtensions: Error listing extensions for request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch extensions")
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, extensions)
+}
+
+func (h *RequestHandler) respondWithExtensionError(w http.ResponseWriter, handler string, extensionID int, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		handlers.RespondWithError(w, http.StatusNotFound, "Extension not found")
+		return
+	}
+	if errors.Is(err, db.ErrInvalidState) {
+		handlers.RespondWithError(w, http.StatusConflict, "Extension is already reviewed or the request is no longer approved")
+		return
+	}
+	log.Printf("%s: Error reviewing extension %d: %v", handler, extensionID, err)
+	handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to review extension")
+}
+
+func extensionIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+	extensionID, err := strconv.Atoi(mux.Vars(r)["extensionID"])
+	if err != nil || extensionID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid extension ID format")
+		return 0, false
+	}
+	return extensionID, true
+}
@@ -0,0 +1,80 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+)
+
+const (
+	defaultStatsPeriod = 12 * 7 // Дней в периоде по умолчанию (12 недель)
+	maxStatsPeriod     = 2 * 366
+	defaultStatsTop    = 5
+	maxStatsTop        = 50
+)
+
+// ManagerStatsHandler - сводка по заявкам партнеров менеджера (GET /api/manager/stats)
+// Параметры: from, to (YYYY-MM-DD, по дате создания заявки, включительно) и top — размер рейтингов.
+// По умолчанию — последние 12 недель по сегодняшний день.
+func (h *RequestHandler) ManagerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "Manager not authenticated")
+		return
+	}
+
+	from, to, err := parseStatsPeriod(r.URL.Query().Get("from"), r.URL.Query().Get("to"), time.Now())
+	if err != nil {
+		handlers.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	top := defaultStatsTop
+	if topStr := r.URL.Query().Get("top"); topStr != "" {
+		top, err = strconv.Atoi(topStr)
+		if err != nil || top < 1 || top > maxStatsTop {
+			handlers.RespondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid top: must be between 1 and %d", maxStatsTop))
+			return
+		}
+	}
+
+	stats, err := h.Repo.GetManagerStats(r.Context(), managerID, from, to, top)
+	if err != nil {
+		log.Printf("ManagerStatsHandler: Error collecting stats for manager %d: %v", managerID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch statistics")
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, stats)
+}
+
+// parseStatsPeriod разбирает границы периода: по умолчанию to — сегодня (UTC), from — defaultStatsPeriod дней до to.
+func parseStatsPeriod(fromStr, toStr string, now time.Time) (time.Time, time.Time, error) {
+	to := protectionDate(now.UTC())
+	if toStr != "" {
+		parsed, err := time.Parse("2006-01-02", toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid to: expected format YYYY-MM-DD")
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, -defaultStatsPeriod+1)
+	if fromStr != "" {
+		parsed, err := time.Parse("2006-01-02", fromStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("Invalid from: expected format YYYY-MM-DD")
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("Invalid period: from must not be after to")
+	}
+	if to.Sub(from) > maxStatsPeriod*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("Invalid period: must not exceed %d days", maxStatsPeriod)
+	}
+	return from, to, nil
+}
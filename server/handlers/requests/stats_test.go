@@ -0,0 +1,47 @@
+package requests
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStatsPeriod(t *testing.T) {
+	now := time.Date(2024, 3, 15, 22, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		from, to string
+		wantFrom string // пустая строка — ожидается ошибка
+		wantTo   string
+	}{
+		{"Defaults", "", "", "2023-12-23", "2024-03-15"},
+		{"DefaultFrom", "", "2024-01-31", "2023-11-09", "2024-01-31"},
+		{"Range", "2024-01-01", "2024-01-31", "2024-01-01", "2024-01-31"},
+		{"SingleDay", "2024-01-01", "2024-01-01", "2024-01-01", "2024-01-01"},
+		{"MaxPeriod", "2022-01-01", "2024-01-03", "2022-01-01", "2024-01-03"},
+		{"FromAfterTo", "2024-02-01", "2024-01-31", "", ""},
+		{"TooLong", "2022-01-01", "2024-01-04", "", ""},
+		{"InvalidFrom", "01.01.2024", "", "", ""},
+		{"InvalidTo", "", "2024-13-01", "", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			from, to, err := parseStatsPeriod(tc.from, tc.to, now)
+			if tc.wantFrom == "" {
+				if err == nil {
+					t.Errorf("parseStatsPeriod(%q, %q) = %s, %s, want error", tc.from, tc.to, from, to)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseStatsPeriod(%q, %q): %v", tc.from, tc.to, err)
+			}
+			if got := from.Format("2006-01-02"); got != tc.wantFrom {
+				t.Errorf("from = %s, want %s", got, tc.wantFrom)
+			}
+			if got := to.Format("2006-01-02"); got != tc.wantTo {
+				t.Errorf("to = %s, want %s", got, tc.wantTo)
+			}
+		})
+	}
+}
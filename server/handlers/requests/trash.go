@@ -0,0 +1,95 @@
+package requests
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/eeephemera/zvk-requests/server/db"
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/middleware"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/gorilla/mux"
+)
+
+// ListTrashHandler - список заявок в корзине менеджера (с пагинацией)
+func (h *RequestHandler) ListTrashHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "Manager not authenticated")
+		return
+	}
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	requests, total, err := h.Repo.ListDeletedRequestsForManager(r.Context(), managerID, limit, offset)
+	if err != nil {
+		log.Printf("ListTrashHandler: Error fetching deleted requests for manager %d: %v", managerID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch deleted requests")
+		return
+	}
+
+	handlers.RespondWithJSON(w, http.StatusOK, models.PaginatedResponse{
+		Items: requests,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	})
+}
+
+// RestoreManagerRequestHandler - восстановление заявки из корзины менеджером
+func (h *RequestHandler) RestoreManagerRequestHandler(w http.ResponseWriter, r *http.Request) {
+	managerID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "Manager not authenticated")
+		return
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return
+	}
+
+	hasAccess, err := h.Repo.CheckManagerAccess(r.Context(), managerID, requestID)
+	if err != nil {
+		log.Printf("RestoreManagerRequestHandler: Error checking manager access for manager %d, request %d: %v", managerID, requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check access rights")
+		return
+	}
+	if !hasAccess {
+		handlers.RespondWithError(w, http.StatusForbidden, "Manager does not have permission to restore this request")
+		return
+	}
+
+	if err := h.Repo.RestoreDeletedRequest(r.Context(), requestID, managerID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+			return
+		}
+		if errors.Is(err, db.ErrInvalidState) {
+			handlers.RespondWithError(w, http.StatusConflict, "Request is not in trash")
+			return
+		}
+		log.Printf("RestoreManagerRequestHandler: Error restoring request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to restore request")
+		return
+	}
+
+	req, err := h.Repo.GetRequestDetailsByID(r.Context(), requestID)
+	if err != nil {
+		log.Printf("RestoreManagerRequestHandler: restored fetch failed for request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch request details")
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, req)
+}
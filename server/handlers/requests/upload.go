@@ -0,0 +1,173 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/handlers"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/storage"
+	"github.com/eeephemera/zvk-requests/server/upload"
+	"github.com/eeephemera/zvk-requests/server/utils"
+)
+
+// uploadTimeout — сколько может длиться чтение формы с файлами и ответ на неё.
+const uploadTimeout = 10 * time.Minute
+
+// receivedForm — прочитанная multipart-форма: текстовые поля и ID уже сохраненных файлов.
+type receivedForm struct {
+	values  map[string]string
+	fileIDs []int
+}
+
+// receiveForm читает multipart-форму потоком и сохраняет файлы из поля fileField в хранилище
+// по мере поступления, не держа их в памяти. Если чтение формы не удалось, уже сохраненные
+// файлы удаляются. При ошибке сам отправляет ответ клиенту и возвращает false.
+func (h *RequestHandler) receiveForm(w http.ResponseWriter, r *http.Request, fileField string) (*receivedForm, bool) {
+	// Общий ReadTimeout сервера рассчитан на обычные запросы; крупная форма на медленном канале
+	// читается дольше, а ответ отправляется только после её сохранения
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(uploadTimeout)
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		log.Printf("receiveForm: Could not extend read deadline: %v", err)
+	}
+	if err := rc.SetWriteDeadline(deadline); err != nil {
+		log.Printf("receiveForm: Could not extend write deadline: %v", err)
+	}
+
+	limits := upload.LimitsFromEnv()
+	allowed := upload.AllowedTypesFromEnv()
+	form, err := upload.NewForm(w, r, limits)
+	if err != nil {
+		respondWithUploadError(w, err, limits)
+		return nil, false
+	}
+
+	received := &receivedForm{}
+	for {
+		file, err := form.NextFile()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && file.Field != fileField {
+			err = fmt.Errorf("%w: unexpected file field %q", errUnexpectedFileField, file.Field)
+		}
+		var fileID int
+		if err == nil {
+			fileID, err = h.saveUploadedFile(r.Context(), file, allowed)
+		}
+		if err != nil {
+			h.discardFiles(r.Context(), received.fileIDs)
+			respondWithUploadError(w, err, limits)
+			return nil, false
+		}
+		received.fileIDs = append(received.fileIDs, fileID)
+	}
+	received.values = form.Values
+	return received, true
+}
+
+// errUnexpectedFileField — файл прислан в поле формы, которое файлов не принимает.
+var errUnexpectedFileField = errors.New("unexpected file field")
+
+// saveUploadedFile проверяет тип файла по содержимому и передает его в хранилище; размер
+// и SHA-256 вычисляются при записи. Заявленный клиентом Content-Type не учитывается: тип
+// определяется по первым байтам, а у ZIP-архивов — по структуре после сохранения.
+func (h *RequestHandler) saveUploadedFile(ctx context.Context, file *upload.File, allowed upload.AllowedTypes) (int, error) {
+	name := utils.SanitizeFilename(file.Name)
+	if err := upload.CheckDetected(name, file.DetectedType, allowed); err != nil {
+		return 0, err
+	}
+
+	newFile := &models.File{
+		FileName: name,
+		MimeType: file.DetectedType,
+		FileSize: -1, // Размер станет известен после записи
+	}
+	fileID, err := h.Repo.CreateFile(ctx, newFile, file)
+	if err != nil || file.DetectedType != upload.MIMEZip {
+		return fileID, err
+	}
+	if err := h.inspectArchive(ctx, fileID, name, allowed); err != nil {
+		h.discardFiles(ctx, []int{fileID})
+		return 0, err
+	}
+	return fileID, nil
+}
+
+// inspectArchive разбирает сохраненный ZIP-архив: документ Word или Excel внутри него
+// записывается в files.mime_type вместо application/zip.
+func (h *RequestHandler) inspectArchive(ctx context.Context, fileID int, name string, allowed upload.AllowedTypes) error {
+	blob, err := h.Repo.OpenFile(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to open stored archive: %w", err)
+	}
+	defer blob.Close()
+
+	mimeType, err := upload.InspectZip(storage.ReaderAt(blob), blob.Size())
+	if err != nil {
+		return err
+	}
+	if !allowed[mimeType] {
+		return upload.ErrUnsupportedType
+	}
+	if err := upload.CheckExtension(name, mimeType); err != nil {
+		return err
+	}
+	if mimeType == upload.MIMEZip {
+		return nil
+	}
+	return h.Repo.SetFileMimeType(ctx, fileID, mimeType)
+}
+
+// discardFiles удаляет загруженные файлы, которые так и не были привязаны к заявке или сообщению.
+// Вызывается и после успешного сохранения: привязанные файлы репозиторий не удаляет.
+// Выполняется, даже если клиент уже отключился.
+func (h *RequestHandler) discardFiles(ctx context.Context, fileIDs []int) {
+	if err := h.Repo.DeleteUnlinkedFiles(context.WithoutCancel(ctx), fileIDs); err != nil {
+		log.Printf("discardFiles: Error deleting unlinked files %v: %v", fileIDs, err)
+	}
+}
+
+// respondWithUploadError отправляет ответ на ошибку чтения формы или сохранения файла.
+func respondWithUploadError(w http.ResponseWriter, err error, limits upload.Limits) {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytes):
+		handlers.RespondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("Request exceeds %dMB limit", limits.MaxRequestSize>>20))
+	case errors.Is(err, upload.ErrFileTooLarge):
+		handlers.RespondWithError(w, http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("File exceeds %dMB limit", limits.MaxFileSize>>20))
+	case errors.Is(err, upload.ErrTooManyFiles):
+		handlers.RespondWithError(w, http.StatusBadRequest,
+			fmt.Sprintf("Too many files: at most %d allowed", limits.MaxFiles))
+	case errors.Is(err, upload.ErrFieldTooLarge):
+		handlers.RespondWithError(w, http.StatusRequestEntityTooLarge, "Form field is too large")
+	case errors.Is(err, upload.ErrNotMultipart):
+		handlers.RespondWithError(w, http.StatusBadRequest, "Expected multipart/form-data")
+	case errors.Is(err, upload.ErrUnsupportedType):
+		handlers.RespondWithError(w, http.StatusBadRequest, "Unsupported file type")
+	case errors.Is(err, upload.ErrExtensionMismatch):
+		handlers.RespondWithError(w, http.StatusBadRequest, "File extension does not match its content")
+	case errors.Is(err, upload.ErrInvalidArchive):
+		handlers.RespondWithError(w, http.StatusBadRequest, "File is a damaged ZIP archive")
+	case errors.Is(err, errUnexpectedFileField):
+		handlers.RespondWithError(w, http.StatusBadRequest, "Files are not accepted in this form field")
+	case errors.Is(err, context.Canceled):
+		// Клиент оборвал загрузку; отвечать некому
+	default:
+		var readErr *upload.ReadError
+		if errors.As(err, &readErr) {
+			handlers.RespondWithError(w, http.StatusBadRequest, "Failed to parse multipart form: "+readErr.Err.Error())
+			return
+		}
+		log.Printf("receiveForm: Error saving uploaded file: %v", err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to save uploaded file")
+	}
+}
@@ -3,19 +3,17 @@ package requests
 import (
 	"encoding/json"
 	"errors"
-	"io"
 	"log"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/eeephemera/zvk-requests/server/db"
 	"github.com/eeephemera/zvk-requests/server/handlers" // Предполагаем, что хелперы тут
 	"github.com/eeephemera/zvk-requests/server/middleware"
 	"github.com/eeephemera/zvk-requests/server/models"
-	"github.com/eeephemera/zvk-requests/server/utils"
+	"github.com/eeephemera/zvk-requests/server/workflow"
 	"github.com/gorilla/mux"
-	"github.com/shopspring/decimal"
 )
 
 // CreateRequestHandlerNew - Создание новой заявки по новой схеме.
@@ -49,210 +47,47 @@ func (h *RequestHandler) CreateRequestHandlerNew(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// 3. Парсим multipart form
-	// Глобальный лимит тела запроса
-	r.Body = http.MaxBytesReader(w, r.Body, 20<<20) // 20MB
-	// Лимит multipart: 15MB (как на UI)
-	err = r.ParseMultipartForm(15 << 20)
-	log.Printf("Результат ParseMultipartForm (nil - это хорошо): %v", err) // Логируем ошибку парсинга
-	if err != nil {
-		handlers.RespondWithError(w, http.StatusBadRequest, "Failed to parse multipart form: "+err.Error())
+	// 3. Читаем форму: JSON из поля 'request_data' и файлы, которые сохраняются по мере загрузки.
+	// Файлы, не привязанные к заявке (она не прошла проверку или не сохранилась), удаляются
+	requestDTO, fileIDs, ok := h.receiveRequestForm(w, r)
+	if !ok {
 		return
 	}
+	defer h.discardFiles(r.Context(), fileIDs)
 
-	// --- ДИАГНОСТИЧЕСКОЕ ЛОГИРОВАНИЕ: ПРОВЕРКА КЛЮЧЕЙ ФОРМЫ ---
-	if r.MultipartForm != nil {
-		log.Println("Полученные ключи в multipart form (текстовые поля):")
-		for key := range r.MultipartForm.Value {
-			log.Printf("- %s", key)
-		}
-		log.Println("Полученные ключи в multipart form (файлы):")
-		for key := range r.MultipartForm.File {
-			log.Printf("- %s", key)
-		}
-	} else {
-		log.Println("r.MultipartForm пуст (is nil)")
-	}
-	// --- КОНЕЦ ДИАГНОСТИЧЕСКОГО ЛОГИРОВАНИЯ ---
-
-	// 4. Получаем JSON данные из поля 'request_data'
-	requestDataJSON := r.FormValue("request_data")
-	if requestDataJSON == "" {
-		handlers.RespondWithError(w, http.StatusBadRequest, "Missing 'request_data' field in form")
-		return
-	}
-
-	// 5. Десериализуем JSON в структуру DTO (или напрямую в Request, если поля совпадают)
-	// Используем временную структуру DTO, чтобы отделить данные запроса от полной модели
-	var requestDTO struct {
-		EndClientINN             string `json:"end_client_inn"` // ИНН для поиска/создания клиента
-		EndClientName            string `json:"end_client_name"`
-		EndClientCity            string `json:"end_client_city"`
-		EndClientFullAddress     string `json:"end_client_full_address"`
-		EndClientContactDetails  string `json:"end_client_contact_details"`
-		EndClientDetailsOverride string `json:"end_client_details_override"` // Если ИНН не указан
-		DistributorID            *int   `json:"distributor_id"`
-		PartnerContactOverride   string `json:"partner_contact_override"`
-		FZLawType                string `json:"fz_law_type"`
-		MPTRegistryType          string `json:"mpt_registry_type"`
-		PartnerActivities        string `json:"partner_activities"`
-		DealStateDescription     string `json:"deal_state_description"`
-		EstimatedCloseDateStr    string `json:"estimated_close_date"` // Дата как строка YYYY-MM-DD
-		// Новые поля для упрощенной модели
-		ProjectName string  `json:"project_name"`
-		Quantity    *int    `json:"quantity"`
-		UnitPrice   *string `json:"unit_price"` // Принимаем как строку для гибкости
-	}
-
-	if err := json.Unmarshal([]byte(requestDataJSON), &requestDTO); err != nil {
-		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid JSON in 'request_data': "+err.Error())
-		return
-	}
-
-	// 6. Обрабатываем конечного клиента
-	var endClientID *int
-	if requestDTO.EndClientINN != "" {
-		// Пытаемся найти клиента по ИНН
-		foundClient, err := h.EndClientRepo.FindEndClientByINN(r.Context(), requestDTO.EndClientINN)
-		if err != nil {
-			log.Printf("CreateRequestHandlerNew: Error finding end client by INN %s: %v", requestDTO.EndClientINN, err)
-			handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to check end client existence")
-			return
-		}
-		if foundClient != nil {
-			endClientID = &foundClient.ID
-		} else {
-			// Если не найден, создаем нового
-			newClient := &models.EndClient{
-				Name:                 requestDTO.EndClientName, // Нужно передавать все поля
-				City:                 stringToPtr(requestDTO.EndClientCity),
-				INN:                  stringToPtr(requestDTO.EndClientINN),
-				FullAddress:          stringToPtr(requestDTO.EndClientFullAddress),
-				ContactPersonDetails: stringToPtr(requestDTO.EndClientContactDetails),
-			}
-			if err := h.EndClientRepo.CreateEndClient(r.Context(), newClient); err != nil {
-				log.Printf("CreateRequestHandlerNew: Error creating new end client with INN %s: %v", requestDTO.EndClientINN, err)
-				handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to create new end client")
-				return
-			}
-			endClientID = &newClient.ID
-		}
+	// 4. Обрабатываем конечного клиента
+	endClientID, ok := h.resolveEndClient(w, r, requestDTO)
+	if !ok {
+		return
 	}
 
-	// 7. Обрабатываем дату
-	var estimatedCloseDate *time.Time
-	if requestDTO.EstimatedCloseDateStr != "" {
-		parsedDate, err := time.Parse("2006-01-02", requestDTO.EstimatedCloseDateStr)
-		if err != nil {
-			handlers.RespondWithError(w, http.StatusBadRequest, "Invalid format for estimated_close_date (use YYYY-MM-DD)")
-			return
-		}
-		estimatedCloseDate = &parsedDate
-	}
-
-	// 8. Обрабатываем файлы (если есть)
-	var fileIDs []int
-	// Ключ 'overall_tz_files[]' должен соответствовать тому, как FormData на клиенте добавляет файлы
-	uploadedFiles := r.MultipartForm.File["overall_tz_files[]"]
-	if len(uploadedFiles) > 0 {
-		log.Printf("Получено %d файлов для загрузки", len(uploadedFiles))
-		for _, fileHeader := range uploadedFiles {
-			// Лимит 15MB на файл
-			if fileHeader.Size > 15*1024*1024 {
-				handlers.RespondWithError(w, http.StatusRequestEntityTooLarge, "File exceeds 15MB limit")
-				return
-			}
-			file, err := fileHeader.Open()
-			if err != nil {
-				log.Printf("CreateRequestHandlerNew: Error opening uploaded file %s: %v", fileHeader.Filename, err)
-				handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to process uploaded file")
-				return
-			}
-			defer file.Close()
-
-			fileBytes, err := io.ReadAll(file)
-			if err != nil {
-				log.Printf("CreateRequestHandlerNew: Error reading uploaded file %s: %v", fileHeader.Filename, err)
-				handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to read uploaded file")
-				return
-			}
-
-			// MIME allowlist проверка
-			contentType := fileHeader.Header.Get("Content-Type")
-			if contentType == "" {
-				contentType = http.DetectContentType(fileBytes)
-			}
-			switch contentType {
-			case "application/pdf", "image/png", "image/jpeg", "text/plain":
-				// ok
-			default:
-				handlers.RespondWithError(w, http.StatusBadRequest, "Unsupported file type")
-				return
-			}
-
-			newFile := &models.File{
-				FileName: utils.SanitizeFilename(fileHeader.Filename),
-				MimeType: contentType,
-				FileSize: fileHeader.Size,
-				FileData: fileBytes,
-			}
-
-			fileID, err := h.Repo.CreateFile(r.Context(), newFile)
-			if err != nil {
-				log.Printf("CreateRequestHandlerNew: Error saving file %s to DB: %v", fileHeader.Filename, err)
-				handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to save file to database")
-				return
-			}
-			fileIDs = append(fileIDs, fileID)
-		}
+	// 5. Собираем основной объект заявки (дата и цены валидируются здесь)
+	req := &models.Request{
+		PartnerUserID: userID,
+		PartnerID:     *user.PartnerID,
+		EndClientID:   endClientID,
+		Status:        models.StatusPending,
 	}
-
-	// Обрабатываем и вычисляем цены
-	var unitPriceDecimal *decimal.Decimal
-	var totalPrice *decimal.Decimal
-	if requestDTO.UnitPrice != nil && *requestDTO.UnitPrice != "" {
-		parsedPrice, err := decimal.NewFromString(*requestDTO.UnitPrice)
-		if err != nil {
-			handlers.RespondWithError(w, http.StatusBadRequest, "Invalid format for unit_price")
-			return
-		}
-		unitPriceDecimal = &parsedPrice
+	if err := requestDTO.applyTo(req); err != nil {
+		respondWithInvalidDeal(w, err)
+		return
 	}
-
-	if requestDTO.Quantity != nil && unitPriceDecimal != nil {
-		quantityDecimal := decimal.NewFromInt(int64(*requestDTO.Quantity))
-		calculatedTotal := quantityDecimal.Mul(*unitPriceDecimal)
-		totalPrice = &calculatedTotal
+	if !h.linkCatalogItems(w, r, req.Items, nil) || !h.checkExchangeRate(w, r, req.Currency) {
+		return
 	}
 
-	// 9. Собираем основной объект заявки
-	req := &models.Request{
-		PartnerUserID:            userID,
-		PartnerID:                *user.PartnerID,
-		EndClientID:              endClientID,
-		EndClientDetailsOverride: stringToPtr(requestDTO.EndClientDetailsOverride),
-		DistributorID:            requestDTO.DistributorID,
-		PartnerContactOverride:   stringToPtr(requestDTO.PartnerContactOverride),
-		FZLawType:                stringToPtr(requestDTO.FZLawType),
-		MPTRegistryType:          stringToPtr(requestDTO.MPTRegistryType),
-		PartnerActivities:        stringToPtr(requestDTO.PartnerActivities),
-		DealStateDescription:     stringToPtr(requestDTO.DealStateDescription),
-		EstimatedCloseDate:       estimatedCloseDate,
-		ProjectName:              stringToPtr(requestDTO.ProjectName),
-		Quantity:                 requestDTO.Quantity,
-		UnitPrice:                unitPriceDecimal,
-		TotalPrice:               totalPrice,
-		Status:                   models.StatusPending,
-	}
-
-	// 10. Создаем заявку в БД, передавая ID загруженных файлов
+	// 6. Создаем заявку в БД, передавая ID загруженных файлов
 	if err := h.Repo.CreateRequest(r.Context(), req, fileIDs); err != nil {
+		if errors.Is(err, db.ErrNoExchangeRate) {
+			respondWithNoExchangeRate(w, req.Currency)
+			return
+		}
 		log.Printf("CreateRequestHandlerNew: Error calling repository CreateRequest for user %d: %v", userID, err)
 		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to save request")
 		return
 	}
 
-	// 11. Отправляем успешный ответ
+	// 7. Отправляем успешный ответ
 	// Возвращаем созданную заявку с ID и временными метками
 	handlers.RespondWithJSON(w, http.StatusCreated, req)
 }
@@ -288,8 +123,11 @@ func (h *RequestHandler) ListMyRequestsHandler(w http.ResponseWriter, r *http.Re
 	}
 	offset := (page - 1) * limit
 
+	// Отозванные заявки по умолчанию скрыты
+	includeWithdrawn := r.URL.Query().Get("include_withdrawn") == "true"
+
 	// Вызываем обновленный метод репозитория
-	requests, total, err := h.Repo.ListRequestsByUser(r.Context(), userID, limit, offset)
+	requests, total, err := h.Repo.ListRequestsByUser(r.Context(), userID, limit, offset, includeWithdrawn)
 	if err != nil {
 		log.Printf("ListMyRequestsHandler: Error fetching requests for user %d: %v", userID, err)
 		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch requests")
@@ -336,6 +174,11 @@ func (h *RequestHandler) GetMyRequestDetailsHandler(w http.ResponseWriter, r *ht
 		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch request details")
 		return
 	}
+	// Заявки из корзины менеджера партнеру не показываем
+	if req.DeletedAt != nil {
+		handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+		return
+	}
 
 	// 4. Проверяем права доступа: пользователь должен быть создателем заявки
 	if req.PartnerUserID != userID {
@@ -348,9 +191,196 @@ func (h *RequestHandler) GetMyRequestDetailsHandler(w http.ResponseWriter, r *ht
 	_ = json.NewEncoder(w).Encode(req)
 }
 
-// DeleteMyRequestHandler - Удаление конкретной заявки пользователя
+// UpdateMyRequestHandler - Редактирование заявки партнером и повторная отправка на рассмотрение.
+// Ожидает тот же multipart/form-data, что и создание; в 'request_data' дополнительно
+// принимает 'remove_file_ids' (открепляемые файлы) и 'comment' (пояснение для менеджера).
+func (h *RequestHandler) UpdateMyRequestHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Получаем ID пользователя из контекста
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// 2. Получаем ID заявки из URL
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return
+	}
+
+	// 3. Проверяем, что заявка существует, принадлежит пользователю и может быть отредактирована
+	existing, err := h.Repo.GetRequestDetailsByID(r.Context(), requestID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+			return
+		}
+		log.Printf("UpdateMyRequestHandler: Error fetching request %d: %v", requestID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to fetch request details")
+		return
+	}
+	if existing.PartnerUserID != userID {
+		handlers.RespondWithError(w, http.StatusForbidden, "You do not have permission to modify this request")
+		return
+	}
+	// Ранняя проверка, чтобы не сохранять файлы впустую; окончательная — в транзакции репозитория
+	currentStatus, _ := workflow.Parse(string(existing.Status))
+	var transitionErr *workflow.TransitionError
+	if err := workflow.CheckTransition(currentStatus, models.StatusPending, models.RoleUser); errors.As(err, &transitionErr) {
+		respondWithTransitionError(w, transitionErr)
+		return
+	}
+
+	// 4. Читаем форму (новые файлы сохраняются по мере загрузки) и обрабатываем конечного клиента
+	requestDTO, fileIDs, ok := h.receiveRequestForm(w, r)
+	if !ok {
+		return
+	}
+	defer h.discardFiles(r.Context(), fileIDs)
+	endClientID, ok := h.resolveEndClient(w, r, requestDTO)
+	if !ok {
+		return
+	}
+
+	req := &models.Request{
+		ID:            requestID,
+		PartnerUserID: userID,
+		PartnerID:     existing.PartnerID,
+		EndClientID:   endClientID,
+	}
+	if err := requestDTO.applyTo(req); err != nil {
+		respondWithInvalidDeal(w, err)
+		return
+	}
+	if !h.linkCatalogItems(w, r, req.Items, existing.Items) || !h.checkExchangeRate(w, r, req.Currency) {
+		return
+	}
+
+	// 5. Сохраняем правку; комментарий попадает в историю статусов
+	comment := "Заявка отредактирована партнером"
+	if requestDTO.Comment != "" {
+		comment += ": " + requestDTO.Comment
+	}
+	err = h.Repo.UpdateRequest(r.Context(), req, fileIDs, requestDTO.RemoveFileIDs, &comment)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+			return
+		}
+		if errors.As(err, &transitionErr) {
+			respondWithTransitionError(w, transitionErr)
+			return
+		}
+		if errors.Is(err, db.ErrInvalidState) {
+			handlers.RespondWithError(w, http.StatusConflict, "Withdrawn request cannot be edited")
+			return
+		}
+		if errors.Is(err, db.ErrNoExchangeRate) {
+			respondWithNoExchangeRate(w, req.Currency)
+			return
+		}
+		log.Printf("UpdateMyRequestHandler: Error updating request %d for user %d: %v", requestID, userID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to update request")
+		return
+	}
+
+	// 6. Возвращаем обновленную заявку целиком
+	updatedReq, err := h.Repo.GetRequestDetailsByID(r.Context(), requestID)
+	if err != nil {
+		log.Printf("UpdateMyRequestHandler: updated fetch failed for request %d: %v", requestID, err)
+		handlers.RespondWithJSON(w, http.StatusOK, req)
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, updatedReq)
+}
+
+// DeleteMyRequestHandler - Отзыв (мягкое удаление) заявки её создателем.
+// Ожидает JSON с причиной отзыва; заявку можно восстановить в течение withdrawalGracePeriod.
 func (h *RequestHandler) DeleteMyRequestHandler(w http.ResponseWriter, r *http.Request) {
-	// ... (реализация аналогична GetMyRequestDetailsHandler, но с вызовом DeleteRequest)
+	// 1. Получаем ID пользователя из контекста
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	// 2. Получаем ID заявки из URL
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return
+	}
+
+	// 3. Декодируем причину отзыва
+	var payload struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	payload.Reason = strings.TrimSpace(payload.Reason)
+	if payload.Reason == "" {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Withdrawal reason is required")
+		return
+	}
+
+	// 4. Отзываем заявку (владение проверяется в репозитории)
+	err = h.Repo.WithdrawRequest(r.Context(), requestID, userID, payload.Reason)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+			return
+		}
+		if errors.Is(err, db.ErrInvalidState) {
+			handlers.RespondWithError(w, http.StatusConflict, "Only active requests under review can be withdrawn")
+			return
+		}
+		log.Printf("DeleteMyRequestHandler: Error withdrawing request %d for user %d: %v", requestID, userID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to withdraw request")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreMyRequestHandler - Восстановление отозванной заявки в пределах срока восстановления
+func (h *RequestHandler) RestoreMyRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		handlers.RespondWithError(w, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || requestID <= 0 {
+		handlers.RespondWithError(w, http.StatusBadRequest, "Invalid request ID format")
+		return
+	}
+
+	err = h.Repo.RestoreWithdrawnRequest(r.Context(), requestID, userID, withdrawalGracePeriod())
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			handlers.RespondWithError(w, http.StatusNotFound, "Request not found")
+			return
+		}
+		if errors.Is(err, db.ErrInvalidState) {
+			handlers.RespondWithError(w, http.StatusConflict, "Request is not withdrawn or the restore period has expired")
+			return
+		}
+		log.Printf("RestoreMyRequestHandler: Error restoring request %d for user %d: %v", requestID, userID, err)
+		handlers.RespondWithError(w, http.StatusInternalServerError, "Failed to restore request")
+		return
+	}
+
+	restored, err := h.Repo.GetRequestDetailsByID(r.Context(), requestID)
+	if err != nil {
+		log.Printf("RestoreMyRequestHandler: restored fetch failed for request %d: %v", requestID, err)
+		handlers.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Request restored successfully"})
+		return
+	}
+	handlers.RespondWithJSON(w, http.StatusOK, restored)
 }
 
 // stringToPtr возвращает указатель на строку или nil, если строка пустая.
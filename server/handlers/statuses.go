@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/eeephemera/zvk-requests/server/workflow"
+)
+
+// ListStatusesHandler обрабатывает запрос GET /api/statuses
+// и возвращает список статусов заявок и граф разрешённых переходов
+func ListStatusesHandler(w http.ResponseWriter, r *http.Request) {
+	RespondWithJSON(w, http.StatusOK, workflow.Describe())
+}
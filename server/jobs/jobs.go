@@ -0,0 +1,49 @@
+// Package jobs запускает периодические фоновые задачи сервера
+// (очистка корзины и т.п.) до отмены контекста.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job — периодическая задача.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Start запускает задачу в отдельной горутине: сразу и затем каждые Interval.
+// Ошибки логируются и не прерывают дальнейшие запуски. Задача останавливается при отмене ctx.
+func Start(ctx context.Context, job Job) {
+	go func() {
+		ticker := time.NewTicker(job.Interval)
+		defer ticker.Stop()
+		for {
+			runOnce(ctx, job)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func runOnce(ctx context.Context, job Job) {
+	if err := job.Run(ctx); err != nil && ctx.Err() == nil {
+		slog.Error("Ошибка фоновой задачи", "job", job.Name, "error", err)
+	}
+}
+
+// DurationFromEnv разбирает длительность из значения переменной окружения,
+// возвращая def для пустого, некорректного или неположительного значения.
+func DurationFromEnv(value string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
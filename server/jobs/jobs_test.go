@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStartRunsRepeatedlyUntilCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var runs atomic.Int32
+	done := make(chan struct{})
+
+	Start(ctx, Job{
+		Name:     "test",
+		Interval: 5 * time.Millisecond,
+		Run: func(ctx context.Context) error {
+			if runs.Add(1) == 3 {
+				close(done)
+			}
+			return errors.New("ошибка не должна останавливать задачу")
+		},
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("job ran %d times, want at least 3", runs.Load())
+	}
+	cancel()
+
+	// После отмены контекста задача больше не запускается
+	time.Sleep(20 * time.Millisecond)
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Errorf("job kept running after cancel")
+	}
+}
+
+func TestDurationFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", time.Hour},
+		{"30m", 30 * time.Minute},
+		{"invalid", time.Hour},
+		{"-5m", time.Hour},
+	}
+	for _, tc := range tests {
+		if got := DurationFromEnv(tc.value, time.Hour); got != tc.want {
+			t.Errorf("DurationFromEnv(%q) = %v, want %v", tc.value, got, tc.want)
+		}
+	}
+}
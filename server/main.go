@@ -11,11 +11,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eeephemera/zvk-requests/server/certificate"
 	"github.com/eeephemera/zvk-requests/server/db"
 	"github.com/eeephemera/zvk-requests/server/handlers"
 	requests_handler "github.com/eeephemera/zvk-requests/server/handlers/requests"
+	"github.com/eeephemera/zvk-requests/server/jobs"
 	"github.com/eeephemera/zvk-requests/server/middleware"
 	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/registry"
+	"github.com/eeephemera/zvk-requests/server/storage"
 	"github.com/eeephemera/zvk-requests/server/utils"
 
 	"github.com/gorilla/mux"
@@ -34,6 +38,7 @@ func main() {
 	// Проверяем наличие необходимых переменных окружения
 	requiredEnv := []string{
 		"JWT_SECRET",
+		"CERTIFICATE_SECRET",
 		"DB_HOST",
 		"DB_PORT",
 		"DB_USER",
@@ -48,6 +53,10 @@ func main() {
 
 	// Устанавливаем секрет для JWT
 	middleware.SetJWTSecret(os.Getenv("JWT_SECRET"))
+	// Коды проверки свидетельств публичны, поэтому подписываются отдельным ключом
+	if os.Getenv("CERTIFICATE_SECRET") == os.Getenv("JWT_SECRET") {
+		log.Fatal("CERTIFICATE_SECRET должен отличаться от JWT_SECRET")
+	}
 
 	// Создаем контекст и подключаемся к базе
 	ctx, cancel := context.WithCancel(context.Background())
@@ -67,20 +76,81 @@ func main() {
 		log.Fatal("Не удалось получить пул подключений к БД")
 	}
 
+	// Хранилище содержимого загруженных файлов: локальный каталог или S3 (FILE_STORAGE)
+	blobs, err := storage.NewFromEnv()
+	if err != nil {
+		slog.Error("Не удалось настроить хранилище файлов", "error", err)
+		log.Fatalf("Не удалось настроить хранилище файлов: %v", err)
+	}
+
 	// Инициализируем все репозитории
 	slog.Info("Инициализация репозиториев...")
 	userRepo := db.NewUserRepository(pool)
 	partnerRepo := db.NewPartnerRepository(pool)
 	endClientRepo := db.NewEndClientRepository(pool)
-	requestRepo := db.NewRequestRepository(pool)
+	requestRepo := db.NewRequestRepository(pool, blobs)
+	productRepo := db.NewProductRepository(pool)
+	rateRepo := db.NewExchangeRateRepository(pool)
+	joinRequestRepo := db.NewJoinRequestRepository(pool)
+	auditRepo := db.NewAuditRepository(pool)
 	slog.Info("Репозитории инициализированы")
 
+	// Первый администратор: существующий пользователь BOOTSTRAP_ADMIN_LOGIN получает роль ADMIN,
+	// только если администраторов в системе еще нет. Дальше роли назначаются через /api/admin/users.
+	if login := os.Getenv("BOOTSTRAP_ADMIN_LOGIN"); login != "" {
+		promoted, err := userRepo.PromoteToAdminIfNone(ctx, login)
+		if err != nil {
+			slog.Error("Не удалось назначить первого администратора", "login", login, "error", err)
+		} else if promoted {
+			slog.Info("Пользователю назначена роль ADMIN", "login", login)
+		}
+	}
+
+	// Фоновая очистка корзины: заявки, удаленные менеджером, хранятся REQUEST_RETENTION_PERIOD
+	retention := jobs.DurationFromEnv(os.Getenv("REQUEST_RETENTION_PERIOD"), 30*24*time.Hour)
+	jobs.Start(ctx, jobs.Job{
+		Name:     "purge-deleted-requests",
+		Interval: time.Hour,
+		Run: func(ctx context.Context) error {
+			requests, files, err := requestRepo.PurgeDeletedRequests(ctx, retention)
+			if err != nil {
+				return err
+			}
+			if requests > 0 || files > 0 {
+				slog.Info("Корзина очищена", "requests", requests, "files", files)
+			}
+			return nil
+		},
+	})
+
+	// Истечение защиты одобренных сделок: проверка каждые DEAL_EXPIRY_CHECK_INTERVAL
+	jobs.Start(ctx, jobs.Job{
+		Name:     "expire-approved-requests",
+		Interval: jobs.DurationFromEnv(os.Getenv("DEAL_EXPIRY_CHECK_INTERVAL"), time.Hour),
+		Run: func(ctx context.Context) error {
+			expired, err := requestRepo.ExpireOverdueRequests(ctx)
+			if err != nil {
+				return err
+			}
+			if expired > 0 {
+				slog.Info("Истекла защита одобренных сделок", "requests", expired)
+			}
+			return nil
+		},
+	})
+
 	// Инициализируем обработчики
 	slog.Info("Инициализация обработчиков...")
-	requestHandler := requests_handler.NewRequestHandler(requestRepo, userRepo, partnerRepo, endClientRepo)
-	authHandler := handlers.NewAuthHandler(userRepo, partnerRepo)
+	requestHandler := requests_handler.NewRequestHandler(requestRepo, userRepo, partnerRepo, endClientRepo, productRepo, rateRepo,
+		certificate.NewSigner([]byte(os.Getenv("CERTIFICATE_SECRET"))))
+	authHandler := handlers.NewAuthHandler(userRepo, partnerRepo, joinRequestRepo)
 	partnerHandler := handlers.NewPartnerHandler(partnerRepo)
-	endClientHandler := handlers.NewEndClientHandler(endClientRepo)
+	productHandler := handlers.NewProductHandler(productRepo)
+	exchangeRateHandler := handlers.NewExchangeRateHandler(rateRepo)
+	endClientHandler := handlers.NewEndClientHandler(endClientRepo, newCompanyRegistry())
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+	adminHandler := handlers.NewAdminHandler(userRepo, partnerRepo)
+	joinRequestHandler := handlers.NewJoinRequestHandler(joinRequestRepo, partnerRepo)
 	slog.Info("Обработчики инициализированы")
 
 	// Создаем основной роутер
@@ -131,6 +201,15 @@ func main() {
 	}
 	loginLimiter := middleware.NewRateLimiterWithBlock(1*time.Minute, loginMax, 30*time.Minute)
 
+	// Лимитер публичной проверки свидетельств: защищает от перебора кодов (по умолчанию 30/мин)
+	verifyMax := 30
+	if v := os.Getenv("RATE_LIMIT_VERIFY_PER_MIN"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			verifyMax = n
+		}
+	}
+	verifyLimiter := middleware.NewRateLimiterWithBlock(1*time.Minute, verifyMax, 15*time.Minute)
+
 	// CORS middleware (УДАЛЕНО, теперь обрабатывается Nginx)
 	// r.Use(func(next http.Handler) http.Handler {
 	// 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -165,51 +244,155 @@ func main() {
 	// 	})
 	// })
 
+	// Журнал аудита изменяющих запросов. Имена маршрутов (Name) становятся действием в журнале.
+	auditMiddleware := middleware.Audit(auditRepo)
+
 	// Публичные маршруты
-	r.HandleFunc("/api/register", authHandler.RegisterUser).Methods("POST")
+	r.Handle("/api/register", auditMiddleware(http.HandlerFunc(authHandler.RegisterUser))).Methods("POST").Name("auth.register")
 
 	// Применяем более строгий rate limiter к маршруту login
 	loginRouter := r.PathPrefix("/api/login").Subrouter()
+	// Аудит до лимитера, чтобы в журнал попадали и заблокированные попытки входа
+	loginRouter.Use(auditMiddleware)
 	loginRouter.Use(loginLimiter.LimitByPath([]string{"/api/login"}, loginMax))
-	loginRouter.HandleFunc("", authHandler.LoginUser).Methods("POST")
+	loginRouter.HandleFunc("", authHandler.LoginUser).Methods("POST").Name("auth.login")
+
+	// Публичная проверка свидетельства о регистрации сделки по коду (без учетной записи)
+	r.Handle("/api/verify/{code}", verifyLimiter.LimitByIP(http.HandlerFunc(requestHandler.VerifyCertificateHandler))).Methods("GET")
 
 	// Защищенные маршруты
 	authRouter := r.PathPrefix("/api").Subrouter()
 	authRouter.Use(middleware.ValidateToken)
 	// CSRF защита для всех мутирующих методов под /api
 	authRouter.Use(utils.CSRFProtection)
+	authRouter.Use(auditMiddleware)
 
 	authRouter.HandleFunc("/me", authHandler.Me).Methods("GET", "OPTIONS")
-	authRouter.HandleFunc("/logout", handlers.LogoutUser).Methods("POST", "OPTIONS")
-	authRouter.HandleFunc("/refresh", authHandler.RefreshToken).Methods("POST", "OPTIONS")
+	authRouter.HandleFunc("/logout", handlers.LogoutUser).Methods("POST", "OPTIONS").Name("auth.logout")
+	authRouter.HandleFunc("/refresh", authHandler.RefreshToken).Methods("POST", "OPTIONS").Name("auth.refresh")
 
 	// --- Новые маршруты для справочников ---
 	authRouter.HandleFunc("/partners", partnerHandler.ListPartnersHandler).Methods("GET", "OPTIONS")
+	authRouter.HandleFunc("/partners/{id:[0-9]+}", partnerHandler.GetPartnerHandler).Methods("GET")
 	authRouter.HandleFunc("/end-clients/search", endClientHandler.SearchByINNHandler).Methods("GET", "OPTIONS")
+	// Просмотр и ведение справочника конечных клиентов (MANAGER, ADMIN): в нем контакты и адреса
+	// клиентов всех партнеров. Партнеру доступны только свои клиенты — /requests/my/end-clients
+	endClientRouter := authRouter.PathPrefix("/end-clients").Subrouter()
+	endClientRouter.Use(middleware.RequireRole(string(models.RoleManager), string(models.RoleAdmin)))
+	endClientRouter.HandleFunc("", endClientHandler.SearchEndClientsHandler).Methods("GET")
+	endClientRouter.HandleFunc("/{id:[0-9]+}", endClientHandler.GetEndClientHandler).Methods("GET")
+	endClientRouter.HandleFunc("/{id:[0-9]+}", endClientHandler.UpdateEndClientHandler).Methods("PUT").Name("end_client.update")
+	endClientRouter.HandleFunc("/{id:[0-9]+}/merge", endClientHandler.MergeEndClientsHandler).Methods("POST").Name("end_client.merge")
+	authRouter.HandleFunc("/statuses", handlers.ListStatusesHandler).Methods("GET", "OPTIONS")
+
+	// Ведение справочника партнеров (MANAGER, ADMIN)
+	partnerRouter := authRouter.PathPrefix("/partners").Subrouter()
+	partnerRouter.Use(middleware.RequireRole(string(models.RoleManager), string(models.RoleAdmin)))
+	partnerRouter.HandleFunc("", partnerHandler.CreatePartnerHandler).Methods("POST").Name("partner.create")
+	partnerRouter.HandleFunc("/{id:[0-9]+}", partnerHandler.UpdatePartnerHandler).Methods("PUT").Name("partner.update")
+	partnerRouter.HandleFunc("/{id:[0-9]+}/status", partnerHandler.UpdatePartnerStatusHandler).Methods("PUT").Name("partner.status_change")
+
+	// --- Каталог продуктов: просмотр для всех, ведение и импорт прайс-листа (MANAGER, ADMIN) ---
+	authRouter.HandleFunc("/products", productHandler.ListProductsHandler).Methods("GET", "OPTIONS")
+	authRouter.HandleFunc("/products/{id:[0-9]+}", productHandler.GetProductHandler).Methods("GET")
+	productRouter := authRouter.PathPrefix("/products").Subrouter()
+	productRouter.Use(middleware.RequireRole(string(models.RoleManager), string(models.RoleAdmin)))
+	productRouter.HandleFunc("", productHandler.CreateProductHandler).Methods("POST").Name("product.create")
+	productRouter.HandleFunc("/import", productHandler.ImportProductsHandler).Methods("POST").Name("product.import")
+	productRouter.HandleFunc("/{id:[0-9]+}", productHandler.UpdateProductHandler).Methods("PUT").Name("product.update")
+	productRouter.HandleFunc("/{id:[0-9]+}", productHandler.DeleteProductHandler).Methods("DELETE").Name("product.delete")
+
+	// --- Курсы валют к рублю: просмотр для всех, ведение (MANAGER, ADMIN) ---
+	authRouter.HandleFunc("/exchange-rates", exchangeRateHandler.ListExchangeRatesHandler).Methods("GET", "OPTIONS")
+	exchangeRateRouter := authRouter.PathPrefix("/exchange-rates").Subrouter()
+	exchangeRateRouter.Use(middleware.RequireRole(string(models.RoleManager), string(models.RoleAdmin)))
+	exchangeRateRouter.HandleFunc("/{currency:[A-Za-z]{3}}", exchangeRateHandler.SetExchangeRateHandler).Methods("PUT").Name("exchange_rate.set")
+	exchangeRateRouter.HandleFunc("/{currency:[A-Za-z]{3}}", exchangeRateHandler.DeleteExchangeRateHandler).Methods("DELETE").Name("exchange_rate.delete")
+
+	// --- Онбординг: заявки на присоединение к партнеру ---
+	// Повторная заявка пользователя без партнера (например, после отклонения)
+	authRouter.Handle("/me/join-request",
+		middleware.RequireRole(string(models.RoleUser))(http.HandlerFunc(joinRequestHandler.ResubmitJoinRequestHandler)),
+	).Methods("POST").Name("join_request.create")
+	joinRequestRouter := authRouter.PathPrefix("/join-requests").Subrouter()
+	joinRequestRouter.Use(middleware.RequireRole(string(models.RoleManager), string(models.RoleAdmin)))
+	joinRequestRouter.HandleFunc("", joinRequestHandler.ListJoinRequestsHandler).Methods("GET")
+	joinRequestRouter.HandleFunc("/{id:[0-9]+}/approve", joinRequestHandler.ApproveJoinRequestHandler).Methods("POST").Name("join_request.approve")
+	joinRequestRouter.HandleFunc("/{id:[0-9]+}/reject", joinRequestHandler.RejectJoinRequestHandler).Methods("POST").Name("join_request.reject")
 
 	// --- Маршруты для партнеров (USER) ---
 	userRouter := authRouter.PathPrefix("/requests").Subrouter()
 	userRouter.Use(middleware.RequireRole(string(models.RoleUser)))
 	// Валидация для создания заявки выполняется внутри обработчика
-	userRouter.HandleFunc("", requestHandler.CreateRequestHandlerNew).Methods("POST")
+	userRouter.HandleFunc("", requestHandler.CreateRequestHandlerNew).Methods("POST").Name("request.create")
 	userRouter.HandleFunc("/my", requestHandler.ListMyRequestsHandler).Methods("GET")
+	userRouter.HandleFunc("/my/end-clients", endClientHandler.ListMyEndClientsHandler).Methods("GET")
 	userRouter.HandleFunc("/my/{id:[0-9]+}", requestHandler.GetMyRequestDetailsHandler).Methods("GET")
+	userRouter.HandleFunc("/my/{id:[0-9]+}", requestHandler.UpdateMyRequestHandler).Methods("PUT").Name("request.update")
+	// Отзыв заявки партнером (мягкое удаление) и его отмена
+	userRouter.HandleFunc("/my/{id:[0-9]+}", requestHandler.DeleteMyRequestHandler).Methods("DELETE").Name("request.withdraw")
+	userRouter.HandleFunc("/my/{id:[0-9]+}/restore", requestHandler.RestoreMyRequestHandler).Methods("POST").Name("request.withdrawal_restore")
+	userRouter.HandleFunc("/my/{id:[0-9]+}/history", requestHandler.GetMyRequestHistoryHandler).Methods("GET")
+	userRouter.HandleFunc("/my/{id:[0-9]+}/comments", requestHandler.ListMyRequestCommentsHandler).Methods("GET")
+	userRouter.HandleFunc("/my/{id:[0-9]+}/comments", requestHandler.CreateMyRequestCommentHandler).Methods("POST").Name("request.comment")
+	// Продление защиты одобренной сделки
+	userRouter.HandleFunc("/my/{id:[0-9]+}/extensions", requestHandler.ListMyExtensionsHandler).Methods("GET")
+	userRouter.HandleFunc("/my/{id:[0-9]+}/extensions", requestHandler.CreateMyExtensionHandler).Methods("POST").Name("request.extension_request")
+	// Свидетельство о регистрации одобренной сделки
+	userRouter.HandleFunc("/my/{id:[0-9]+}/certificate.pdf", requestHandler.GetMyCertificateHandler).Methods("GET")
 	// Новый роут для скачивания файла по его ID
-	userRouter.HandleFunc("/files/{fileID:[0-9]+}", requestHandler.DownloadFileHandler).Methods("GET")
+	userRouter.HandleFunc("/files/{fileID:[0-9]+}", requestHandler.DownloadFileHandler).Methods("GET").Name("file.download")
 
 	// --- Маршруты для менеджеров (MANAGER) ---
+	// Сводная статистика по заявкам партнеров менеджера
+	authRouter.Handle("/manager/stats",
+		middleware.RequireRole(string(models.RoleManager))(http.HandlerFunc(requestHandler.ManagerStatsHandler)),
+	).Methods("GET")
 	managerRouter := authRouter.PathPrefix("/manager/requests").Subrouter()
 	managerRouter.Use(middleware.RequireRole(string(models.RoleManager)))
 	// Сначала определяем более конкретные маршруты
-	managerRouter.HandleFunc("/{id:[0-9]+}/status", requestHandler.UpdateRequestStatusHandler).Methods("PUT")
+	managerRouter.HandleFunc("/{id:[0-9]+}/status", requestHandler.UpdateRequestStatusHandler).Methods("PUT").Name("request.status_change")
 	// Маршрут скачивания файлов менеджером по fileID
-	managerRouter.HandleFunc("/files/{fileID:[0-9]+}", requestHandler.DownloadFileHandler).Methods("GET")
+	managerRouter.HandleFunc("/files/{fileID:[0-9]+}", requestHandler.DownloadFileHandler).Methods("GET").Name("file.download")
 	// Список файлов заявки для менеджера
 	managerRouter.HandleFunc("/{id:[0-9]+}/files", requestHandler.ListRequestFilesForManager).Methods("GET")
+	// История изменения статусов заявки
+	managerRouter.HandleFunc("/{id:[0-9]+}/history", requestHandler.GetManagerRequestHistoryHandler).Methods("GET")
+	// Переписка по заявке
+	managerRouter.HandleFunc("/{id:[0-9]+}/comments", requestHandler.ListManagerRequestCommentsHandler).Methods("GET")
+	managerRouter.HandleFunc("/{id:[0-9]+}/comments", requestHandler.CreateManagerRequestCommentHandler).Methods("POST").Name("request.comment")
+	// Свидетельство о регистрации одобренной сделки
+	managerRouter.HandleFunc("/{id:[0-9]+}/certificate.pdf", requestHandler.GetManagerCertificateHandler).Methods("GET")
+	// Запросы партнеров на продление защиты сделки
+	managerRouter.HandleFunc("/{id:[0-9]+}/extensions", requestHandler.ListManagerExtensionsHandler).Methods("GET")
+	managerRouter.HandleFunc("/{id:[0-9]+}/extensions/{extensionID:[0-9]+}/approve", requestHandler.ApproveExtensionHandler).Methods("POST").Name("request.extension_approve")
+	managerRouter.HandleFunc("/{id:[0-9]+}/extensions/{extensionID:[0-9]+}/reject", requestHandler.RejectExtensionHandler).Methods("POST").Name("request.extension_reject")
 	// Затем общие
+	// Корзина: удаленные заявки и их восстановление
+	managerRouter.HandleFunc("/trash", requestHandler.ListTrashHandler).Methods("GET")
+	// Выгрузка списка заявок в CSV/XLSX с фильтрами и сортировкой списка
+	managerRouter.HandleFunc("/export", requestHandler.ExportManagerRequestsHandler).Methods("GET")
+	managerRouter.HandleFunc("/{id:[0-9]+}/restore", requestHandler.RestoreManagerRequestHandler).Methods("POST").Name("request.trash_restore")
 	managerRouter.HandleFunc("", requestHandler.ListManagerRequestsHandler).Methods("GET")
 	managerRouter.HandleFunc("/{id:[0-9]+}", requestHandler.GetManagerRequestDetailsHandler).Methods("GET")
-	managerRouter.HandleFunc("/{id:[0-9]+}", requestHandler.DeleteManagerRequestHandler).Methods("DELETE")
+	managerRouter.HandleFunc("/{id:[0-9]+}", requestHandler.DeleteManagerRequestHandler).Methods("DELETE").Name("request.delete")
+
+	// --- Администрирование ---
+	adminRouter := authRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Use(middleware.RequireRole(string(models.RoleAdmin)))
+	adminRouter.HandleFunc("/audit", auditHandler.ListAuditEventsHandler).Methods("GET")
+	// Пользователи: роли и привязка к партнерам
+	adminRouter.HandleFunc("/users", adminHandler.ListUsersHandler).Methods("GET")
+	adminRouter.HandleFunc("/users", adminHandler.CreateUserHandler).Methods("POST").Name("admin.user_create")
+	adminRouter.HandleFunc("/users/{id:[0-9]+}", adminHandler.GetUserHandler).Methods("GET")
+	adminRouter.HandleFunc("/users/{id:[0-9]+}", adminHandler.UpdateUserHandler).Methods("PUT").Name("admin.user_update")
+	adminRouter.HandleFunc("/users/{id:[0-9]+}", adminHandler.DeleteUserHandler).Methods("DELETE").Name("admin.user_delete")
+	// Партнеры и назначение ответственных менеджеров
+	adminRouter.HandleFunc("/partners", partnerHandler.ListPartnersHandler).Methods("GET")
+	adminRouter.HandleFunc("/partners", adminHandler.CreatePartnerHandler).Methods("POST").Name("admin.partner_create")
+	adminRouter.HandleFunc("/partners/{id:[0-9]+}", partnerHandler.GetPartnerHandler).Methods("GET")
+	adminRouter.HandleFunc("/partners/{id:[0-9]+}", adminHandler.UpdatePartnerHandler).Methods("PUT").Name("admin.partner_update")
+	adminRouter.HandleFunc("/partners/{id:[0-9]+}", adminHandler.DeletePartnerHandler).Methods("DELETE").Name("admin.partner_delete")
 
 	// Создаем HTTP сервер
 	server := &http.Server{
@@ -256,6 +439,30 @@ func getServerPort() string {
 	return port
 }
 
+// newCompanyRegistry настраивает внешний реестр организаций для автозаполнения конечного клиента по ИНН:
+// HTTP-реестр (COMPANY_REGISTRY_URL) или локальный JSON-файл (COMPANY_REGISTRY_FILE).
+// Возвращает nil, если реестр не настроен.
+func newCompanyRegistry() registry.CompanyRegistry {
+	var source registry.CompanyRegistry
+	switch {
+	case os.Getenv("COMPANY_REGISTRY_URL") != "":
+		source = registry.NewHTTPRegistry(os.Getenv("COMPANY_REGISTRY_URL"), os.Getenv("COMPANY_REGISTRY_TOKEN"), nil)
+	case os.Getenv("COMPANY_REGISTRY_FILE") != "":
+		fileRegistry, err := registry.NewFileRegistry(os.Getenv("COMPANY_REGISTRY_FILE"))
+		if err != nil {
+			slog.Error("Не удалось загрузить реестр организаций", "error", err)
+			return nil
+		}
+		source = fileRegistry
+	default:
+		return nil
+	}
+	return registry.NewCached(source,
+		jobs.DurationFromEnv(os.Getenv("COMPANY_REGISTRY_CACHE_TTL"), 24*time.Hour),
+		jobs.DurationFromEnv(os.Getenv("COMPANY_REGISTRY_TIMEOUT"), 3*time.Second),
+	)
+}
+
 // initLogging инициализирует структурированное логирование
 func initLogging() {
 	logLevel := slog.LevelInfo
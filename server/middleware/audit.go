@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/gorilla/mux"
+)
+
+// AuditStore сохраняет записи журнала аудита
+type AuditStore interface {
+	RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error
+}
+
+// Audit возвращает middleware, которое записывает в журнал все изменяющие запросы
+// (POST/PUT/PATCH/DELETE) и скачивания файлов. Действие берется из имени маршрута,
+// а при его отсутствии — из метода и шаблона пути. Ошибки записи журнала
+// логируются и не влияют на ответ клиенту.
+func Audit(store AuditStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := mux.CurrentRoute(r)
+			template := r.URL.Path
+			routeName := ""
+			if route != nil {
+				if t, err := route.GetPathTemplate(); err == nil {
+					template = t
+				}
+				routeName = route.GetName()
+			}
+			if !shouldAudit(r.Method, template) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			entry := &audit.Entry{}
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(audit.NewContext(r.Context(), entry)))
+
+			var defaultActor *int
+			if userID, ok := r.Context().Value(UserIDKey).(int); ok {
+				defaultActor = &userID
+			}
+			defaultRole, _ := r.Context().Value(RoleKey).(string)
+			snapshot := entry.Snapshot(defaultActor, defaultRole)
+
+			event := &models.AuditEvent{
+				ActorID:    snapshot.ActorID,
+				Action:     routeName,
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				IP:         clientIP(r),
+				StatusCode: recorder.status,
+				Before:     snapshot.Before,
+				After:      snapshot.After,
+			}
+			if event.Action == "" {
+				event.Action = r.Method + " " + template
+			}
+			if snapshot.ActorRole != "" {
+				event.ActorRole = &snapshot.ActorRole
+			}
+			entityType, entityID := snapshot.EntityType, snapshot.EntityID
+			if entityType == "" {
+				entityType, entityID = targetFromRoute(template, mux.Vars(r))
+			}
+			if entityType != "" {
+				event.EntityType = &entityType
+			}
+			if entityID != "" {
+				event.EntityID = &entityID
+			}
+
+			// Запрос клиента мог уже завершиться, но запись в журнал терять нельзя
+			if err := store.RecordAuditEvent(context.WithoutCancel(r.Context()), event); err != nil {
+				slog.Error("Failed to record audit event", "action", event.Action, "path", event.Path, "error", err)
+			}
+		})
+	}
+}
+
+// shouldAudit решает, попадает ли запрос в журнал: изменяющие методы и скачивание файлов.
+func shouldAudit(method, template string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	case http.MethodGet:
+		return strings.Contains(template, "/files/{fileID")
+	}
+	return false
+}
+
+// targetFromRoute определяет сущность по шаблону пути: {fileID} — файл,
+// {id} — сущность из предшествующего сегмента пути ("/requests/{id}" -> "request").
+func targetFromRoute(template string, vars map[string]string) (string, string) {
+	if fileID, ok := vars["fileID"]; ok {
+		return "file", fileID
+	}
+	id, ok := vars["id"]
+	if !ok {
+		return "", ""
+	}
+	segments := strings.Split(strings.Trim(template, "/"), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{id") && i > 0 {
+			return singular(segments[i-1]), id
+		}
+	}
+	return "", id
+}
+
+// singular превращает имя коллекции в имя сущности: "requests" -> "request", "end-clients" -> "end_client".
+func singular(collection string) string {
+	name := strings.ReplaceAll(collection, "-", "_")
+	if name == "my" {
+		return "request" // /api/requests/my/{id}
+	}
+	return strings.TrimSuffix(name, "s")
+}
+
+// clientIP возвращает IP клиента с учетом прокси (первый адрес из X-Forwarded-For).
+func clientIP(r *http.Request) string {
+	ip := r.Header.Get("X-Forwarded-For")
+	if ip != "" {
+		// берём первый hop
+		if comma := strings.Index(ip, ","); comma != -1 {
+			ip = strings.TrimSpace(ip[:comma])
+		} else {
+			ip = strings.TrimSpace(ip)
+		}
+	}
+	if ip == "" {
+		ip = r.Header.Get("X-Real-IP")
+	}
+	if ip == "" {
+		ip = r.RemoteAddr
+	}
+	return ip
+}
+
+// statusRecorder запоминает код ответа обработчика
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.status = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap позволяет http.ResponseController добраться до исходного ResponseWriter
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/eeephemera/zvk-requests/server/audit"
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/gorilla/mux"
+)
+
+type fakeAuditStore struct {
+	events []*models.AuditEvent
+}
+
+func (s *fakeAuditStore) RecordAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func TestAuditRecordsMutations(t *testing.T) {
+	store := &fakeAuditStore{}
+	router := mux.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserIDKey, 42)
+			ctx = context.WithValue(ctx, RoleKey, string(models.RoleManager))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	router.Use(Audit(store))
+	router.HandleFunc("/api/manager/requests/{id:[0-9]+}/status", func(w http.ResponseWriter, r *http.Request) {
+		audit.RecordChange(r.Context(), "request", 7,
+			map[string]any{"status": "На рассмотрении"},
+			map[string]any{"status": "Одобрено"})
+		w.WriteHeader(http.StatusOK)
+	}).Methods("PUT").Name("request.status_change")
+	router.HandleFunc("/api/manager/requests/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}).Methods("GET", "DELETE")
+
+	// Чтение заявки не аудируется
+	req := httptest.NewRequest("GET", "/api/manager/requests/7", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if len(store.events) != 0 {
+		t.Fatalf("GET must not be audited, got %d events", len(store.events))
+	}
+
+	req = httptest.NewRequest("PUT", "/api/manager/requests/7/status", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("DELETE", "/api/manager/requests/8", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(store.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(store.events))
+	}
+
+	status := store.events[0]
+	if status.Action != "request.status_change" {
+		t.Errorf("Action = %q, want request.status_change", status.Action)
+	}
+	if status.ActorID == nil || *status.ActorID != 42 || status.ActorRole == nil || *status.ActorRole != "MANAGER" {
+		t.Errorf("actor = %v/%v, want 42/MANAGER", status.ActorID, status.ActorRole)
+	}
+	if status.IP != "10.0.0.1" {
+		t.Errorf("IP = %q, want 10.0.0.1", status.IP)
+	}
+	if status.Before["status"] != "На рассмотрении" || status.After["status"] != "Одобрено" {
+		t.Errorf("diff = %v -> %v", status.Before, status.After)
+	}
+
+	deletion := store.events[1]
+	if deletion.Action != "DELETE /api/manager/requests/{id:[0-9]+}" {
+		t.Errorf("Action = %q", deletion.Action)
+	}
+	if deletion.StatusCode != http.StatusForbidden {
+		t.Errorf("StatusCode = %d, want 403", deletion.StatusCode)
+	}
+	if deletion.EntityType == nil || *deletion.EntityType != "request" || deletion.EntityID == nil || *deletion.EntityID != "8" {
+		t.Errorf("target = %v/%v, want request/8", deletion.EntityType, deletion.EntityID)
+	}
+}
+
+func TestTargetFromRoute(t *testing.T) {
+	tests := []struct {
+		template string
+		vars     map[string]string
+		wantType string
+		wantID   string
+	}{
+		{"/api/requests/my/{id:[0-9]+}/restore", map[string]string{"id": "3"}, "request", "3"},
+		{"/api/requests/files/{fileID:[0-9]+}", map[string]string{"fileID": "9"}, "file", "9"},
+		{"/api/admin/partners/{id:[0-9]+}", map[string]string{"id": "1"}, "partner", "1"},
+		{"/api/logout", map[string]string{}, "", ""},
+	}
+	for _, tc := range tests {
+		gotType, gotID := targetFromRoute(tc.template, tc.vars)
+		if gotType != tc.wantType || gotID != tc.wantID {
+			t.Errorf("targetFromRoute(%q) = (%q, %q), want (%q, %q)", tc.template, gotType, gotID, tc.wantType, tc.wantID)
+		}
+	}
+}
@@ -198,7 +198,7 @@ func ValidateToken(next http.Handler) http.Handler {
 		// Извлечение роли (допускаем строковое значение)
 		roleValue, _ := claims["role"].(string)
 		if roleValue != "" {
-			if roleValue != string(models.RoleUser) && roleValue != string(models.RoleManager) {
+			if !models.UserRole(roleValue).IsValid() {
 				logger.Warn("Invalid user role in token", "jti", jti, "role", roleValue)
 				http.Error(w, "Invalid user role value in token", http.StatusUnauthorized)
 				return
@@ -190,6 +190,10 @@ func TestRequireRole(t *testing.T) {
 
 	// Тест с несколькими разрешенными ролями
 	testRoleUser(t, models.RoleManager, []string{string(models.RoleUser), string(models.RoleManager)}, http.StatusOK)
+
+	// Администратор не получает доступ к маршрутам менеджера автоматически
+	testRoleUser(t, models.RoleAdmin, []string{string(models.RoleManager)}, http.StatusForbidden)
+	testRoleUser(t, models.RoleAdmin, []string{string(models.RoleAdmin)}, http.StatusOK)
 }
 
 func testRoleUser(t *testing.T, userRole models.UserRole, allowedRoles []string, expectedStatus int) {
@@ -4,7 +4,6 @@ package middleware
 import (
 	"fmt"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 )
@@ -140,21 +139,7 @@ func (rl *RateLimiter) respondTooManyRequests(w http.ResponseWriter) {
 func (rl *RateLimiter) LimitByIP(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Получаем реальный IP пользователя (первый из X-Forwarded-For)
-		ip := r.Header.Get("X-Forwarded-For")
-		if ip != "" {
-			// берём первый hop
-			if comma := strings.Index(ip, ","); comma != -1 {
-				ip = strings.TrimSpace(ip[:comma])
-			} else {
-				ip = strings.TrimSpace(ip)
-			}
-		}
-		if ip == "" {
-			ip = r.Header.Get("X-Real-IP")
-		}
-		if ip == "" {
-			ip = r.RemoteAddr
-		}
+		ip := clientIP(r)
 
 		// Проверяем, заблокирован ли IP
 		if rl.isBlocked(ip) {
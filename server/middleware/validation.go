@@ -3,9 +3,13 @@ package middleware
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/upload"
 )
 
 // ValidationError описывает ошибку валидации для одного поля
@@ -61,34 +65,19 @@ func ValidateCreateRequest(r *http.Request) []ValidationError {
 	contentType := r.Header.Get("Content-Type")
 
 	if strings.Contains(contentType, "multipart/form-data") {
-		// Поддерживаем новую контрактную схему: FormData с полем 'request_data' (JSON)
-		if err := r.ParseMultipartForm(20 << 20); err != nil {
+		// Тело не разбираем: форма читается потоком в обработчике (upload.Form), который
+		// проверяет request_data, размер каждого файла и число файлов по мере загрузки.
+		// Здесь — только то, что известно из заголовков.
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil || params["boundary"] == "" {
 			errors = append(errors, ValidationError{Field: "_form", Message: "Некорректный формат формы"})
 			return errors
 		}
-
-		requestDataJSON := r.FormValue("request_data")
-		if requestDataJSON == "" {
-			errors = append(errors, ValidationError{Field: "request_data", Message: "Поле request_data обязательно"})
-			return errors
-		}
-
-		// Проверяем, что request_data — корректный JSON
-		var tmp map[string]any
-		if err := json.Unmarshal([]byte(requestDataJSON), &tmp); err != nil {
-			errors = append(errors, ValidationError{Field: "_json", Message: "Некорректный JSON в request_data"})
-			return errors
-		}
-
-		// Файлы опциональны, но ограничим размер каждого до 15МБ
-		if r.MultipartForm != nil {
-			for field, fhs := range r.MultipartForm.File {
-				for _, fh := range fhs {
-					if fh.Size > 15*1024*1024 {
-						errors = append(errors, ValidationError{Field: field, Message: "Размер файла не должен превышать 15МБ"})
-					}
-				}
-			}
+		if limits := upload.LimitsFromEnv(); r.ContentLength > limits.MaxRequestSize {
+			errors = append(errors, ValidationError{
+				Field:   "_form",
+				Message: fmt.Sprintf("Размер запроса не должен превышать %dМБ", limits.MaxRequestSize>>20),
+			})
 		}
 	} else if strings.Contains(contentType, "application/json") {
 		// Мягкая проверка JSON: просто валидируем, что тело — корректный JSON
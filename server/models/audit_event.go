@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AuditEvent — запись журнала аудита о действии пользователя через API.
+type AuditEvent struct {
+	ID         int64          `json:"id"`
+	OccurredAt time.Time      `json:"occurred_at"`
+	ActorID    *int           `json:"actor_id,omitempty"` // nil для неаутентифицированных запросов
+	ActorRole  *string        `json:"actor_role,omitempty"`
+	Action     string         `json:"action"`
+	Method     string         `json:"method"`
+	Path       string         `json:"path"`
+	EntityType *string        `json:"entity_type,omitempty"`
+	EntityID   *string        `json:"entity_id,omitempty"`
+	IP         string         `json:"ip"`
+	StatusCode int            `json:"status_code"`
+	Before     map[string]any `json:"before,omitempty"` // Значения изменившихся полей до действия
+	After      map[string]any `json:"after,omitempty"`  // Значения изменившихся полей после действия
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CertificateVerification — результат публичной проверки свидетельства о регистрации сделки.
+// Содержит только несекретные сведения, которые третьи лица могут видеть без учетной записи.
+type CertificateVerification struct {
+	Valid             bool          `json:"valid"` // Сделка сейчас зарегистрирована за партнером
+	CertificateNumber int           `json:"certificate_number"`
+	PartnerName       string        `json:"partner_name"`
+	EndClientName     string        `json:"end_client_name,omitempty"`
+	Status            RequestStatus `json:"status"`
+	Withdrawn         bool          `json:"withdrawn"`
+	ValidFrom         time.Time     `json:"valid_from"`
+	ValidUntil        *time.Time    `json:"valid_until,omitempty"`
+}
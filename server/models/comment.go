@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Comment представляет сообщение в переписке по заявке между партнером и менеджером.
+type Comment struct {
+	ID         int       `json:"id"`
+	RequestID  int       `json:"request_id"`
+	AuthorID   *int      `json:"author_id,omitempty"` // nil, если автор удален
+	AuthorName *string   `json:"author_name,omitempty"`
+	AuthorRole *UserRole `json:"author_role,omitempty"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	Files      []*File   `json:"files"`
+}
@@ -8,3 +8,11 @@ type PaginatedResponse struct {
 	Page  int         `json:"page"`
 	Limit int         `json:"limit"`
 }
+
+// OrgType — тип организации, определяемый по длине ИНН
+type OrgType string
+
+const (
+	OrgTypeLegalEntity            OrgType = "legal_entity"            // Юридическое лицо, ИНН из 10 цифр
+	OrgTypeIndividualEntrepreneur OrgType = "individual_entrepreneur" // Индивидуальный предприниматель, ИНН из 12 цифр
+)
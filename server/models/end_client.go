@@ -8,8 +8,13 @@ type EndClient struct {
 	Name                 string    `json:"name"`
 	City                 *string   `json:"city,omitempty"`
 	INN                  *string   `json:"inn,omitempty"`
+	KPP                  *string   `json:"kpp,omitempty"`
+	OGRN                 *string   `json:"ogrn,omitempty"`
+	OrgType              *OrgType  `json:"org_type,omitempty"` // Вычисляется в БД по ИНН
 	FullAddress          *string   `json:"full_address,omitempty"`
 	ContactPersonDetails *string   `json:"contact_person_details,omitempty"`
+	MergedIntoID         *int      `json:"merged_into_id,omitempty"` // Дубликат, объединенный с этим клиентом
+	External             bool      `json:"external,omitempty"`       // Найден во внешнем реестре и еще не сохранен (ID = 0)
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ExchangeRate — курс валюты к рублю, заданный менеджером: сколько рублей стоит единица валюты.
+type ExchangeRate struct {
+	ID        int             `json:"id"`
+	Currency  string          `json:"currency"`
+	Rate      decimal.Decimal `json:"rate"`
+	UpdatedBy *int            `json:"updated_by,omitempty"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
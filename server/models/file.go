@@ -8,6 +8,6 @@ type File struct {
 	FileName  string    `json:"file_name"`
 	MimeType  string    `json:"mime_type"`
 	FileSize  int64     `json:"file_size"`
-	FileData  []byte    `json:"-"` // Данные файла не отправляем в JSON по умолчанию
+	SHA256    *string   `json:"sha256,omitempty"` // Hex SHA-256 содержимого; у старых файлов не заполнен
 	CreatedAt time.Time `json:"created_at"`
 }
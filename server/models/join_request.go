@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// JoinRequestStatus определяет состояние заявки на присоединение к партнеру
+type JoinRequestStatus string
+
+const (
+	JoinRequestPending  JoinRequestStatus = "PENDING"
+	JoinRequestApproved JoinRequestStatus = "APPROVED"
+	JoinRequestRejected JoinRequestStatus = "REJECTED"
+)
+
+// JoinRequest — заявка зарегистрировавшегося пользователя на привязку к организации-партнеру.
+// Создается при регистрации и рассматривается менеджером или администратором.
+type JoinRequest struct {
+	ID            int               `json:"id"`
+	UserID        int               `json:"user_id"`
+	PartnerINN    string            `json:"partner_inn"`
+	PartnerName   string            `json:"partner_name"`
+	PartnerID     *int              `json:"partner_id,omitempty"` // Найден по ИНН или выбран при одобрении
+	Status        JoinRequestStatus `json:"status"`
+	ReviewerID    *int              `json:"reviewer_id,omitempty"`
+	ReviewComment *string           `json:"review_comment,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	ReviewedAt    *time.Time        `json:"reviewed_at,omitempty"`
+
+	// Связанные данные для списка на рассмотрении
+	User    *User    `json:"user,omitempty"`
+	Partner *Partner `json:"partner,omitempty"`
+}
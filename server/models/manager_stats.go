@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ManagerStats — сводка по заявкам партнеров менеджера за период (по дате создания заявки).
+// Суммы — в базовой валюте (total_price_base). Отозванные заявки учитываются только в Withdrawn.
+type ManagerStats struct {
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	TotalRequests int64           `json:"total_requests"`
+	PipelineValue decimal.Decimal `json:"pipeline_value"`
+	Withdrawn     int64           `json:"withdrawn"`
+	ByStatus      []StatusStats   `json:"by_status"`
+	Decisions     DecisionStats   `json:"decisions"`
+	TopPartners   []RankedValue   `json:"top_partners"`
+	TopEndClients []RankedValue   `json:"top_end_clients"`
+	Weekly        []WeeklyStats   `json:"weekly"`
+}
+
+// StatusStats — число заявок и их сумма в одном статусе.
+type StatusStats struct {
+	Status RequestStatus   `json:"status"`
+	Count  int64           `json:"count"`
+	Value  decimal.Decimal `json:"value"`
+}
+
+// DecisionStats — первые решения менеджеров (одобрение или отклонение) по заявкам периода.
+type DecisionStats struct {
+	Approved     int64    `json:"approved"`
+	Rejected     int64    `json:"rejected"`
+	ApprovalRate *float64 `json:"approval_rate"` // Доля одобренных среди решений; nil, если решений нет
+	// Медиана времени от создания заявки до первого решения, в часах; nil, если решений нет
+	MedianDecisionHours *float64 `json:"median_decision_hours"`
+}
+
+// RankedValue — партнер или конечный клиент в рейтинге по сумме заявок.
+type RankedValue struct {
+	ID    int             `json:"id"`
+	Name  string          `json:"name"`
+	Count int64           `json:"count"`
+	Value decimal.Decimal `json:"value"`
+}
+
+// WeeklyStats — новые заявки и решения за неделю, начинающуюся с понедельника WeekStart.
+type WeeklyStats struct {
+	WeekStart time.Time       `json:"week_start"`
+	Created   int64           `json:"created"`
+	Value     decimal.Decimal `json:"value"`
+	Approved  int64           `json:"approved"`
+	Rejected  int64           `json:"rejected"`
+}
@@ -2,13 +2,24 @@ package models
 
 import "time"
 
+// Статусы партнера (partners.partner_status)
+const (
+	PartnerStatusActive   = "active"
+	PartnerStatusPending  = "pending"
+	PartnerStatusInactive = "inactive"
+)
+
 // Partner представляет организацию-партнера
 type Partner struct {
 	ID                int       `json:"id"`
 	Name              string    `json:"name"`
 	Address           *string   `json:"address,omitempty"`
 	INN               *string   `json:"inn,omitempty"`
+	KPP               *string   `json:"kpp,omitempty"`
+	OGRN              *string   `json:"ogrn,omitempty"`
+	OrgType           *OrgType  `json:"org_type,omitempty"` // Вычисляется в БД по ИНН
 	PartnerStatus     *string   `json:"partner_status,omitempty"`
+	IsDistributor     bool      `json:"is_distributor"`
 	AssignedManagerID *int      `json:"assigned_manager_id,omitempty"`
 	CreatedAt         time.Time `json:"created_at"`
 	UpdatedAt         time.Time `json:"updated_at"`
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Product — позиция каталога (прайс-листа). Неактивные продукты нельзя указывать в новых заявках.
+type Product struct {
+	ID        int             `json:"id"`
+	SKU       string          `json:"sku"`
+	Name      string          `json:"name"`
+	Category  *string         `json:"category,omitempty"`
+	ListPrice decimal.Decimal `json:"list_price"`
+	Currency  string          `json:"currency"`
+	IsActive  bool            `json:"is_active"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
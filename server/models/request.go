@@ -16,6 +16,7 @@ const (
 	StatusClarify    RequestStatus = "Требует уточнения"
 	StatusInProgress RequestStatus = "В работе"
 	StatusCompleted  RequestStatus = "Выполнено"
+	StatusExpired    RequestStatus = "Истекло" // Истек срок защиты одобренной сделки
 )
 
 // Request представляет основную сущность "Заявка на регистрацию сделки".
@@ -32,16 +33,31 @@ type Request struct {
 	PartnerActivities        *string       `json:"partner_activities,omitempty"`
 	DealStateDescription     *string       `json:"deal_state_description,omitempty"`
 	EstimatedCloseDate       *time.Time    `json:"estimated_close_date,omitempty"`
+	ProtectedUntil           *time.Time    `json:"protected_until,omitempty"` // Дата окончания защиты одобренной сделки
+	ExpiredAt                *time.Time    `json:"expired_at,omitempty"`
 	Status                   RequestStatus `json:"status"`
 	ManagerComment           *string       `json:"manager_comment,omitempty"`
 	CreatedAt                time.Time     `json:"created_at"`
 	UpdatedAt                time.Time     `json:"updated_at"`
 
-	// Новые поля, перенесенные из request_items и добавленные
+	// Отзыв заявки партнером (мягкое удаление)
+	Withdrawn        bool       `json:"withdrawn"`
+	WithdrawnAt      *time.Time `json:"withdrawn_at,omitempty"`
+	WithdrawalReason *string    `json:"withdrawal_reason,omitempty"`
+
+	// Удаление заявки менеджером в корзину (мягкое удаление)
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	DeletedBy *int       `json:"deleted_by,omitempty"`
+
+	// Проект и товарные позиции; TotalPrice — сумма итогов позиций, рассчитывается сервером
 	ProjectName *string          `json:"project_name,omitempty"`
-	Quantity    *int             `json:"quantity,omitempty"`
-	UnitPrice   *decimal.Decimal `json:"unit_price,omitempty"`
 	TotalPrice  *decimal.Decimal `json:"total_price,omitempty"`
+	Items       []RequestItem    `json:"items,omitempty"`
+	// Валюта цен и total_price (ISO 4217); TotalPriceBase — сумма в рублях по курсу ExchangeRate
+	// на момент сохранения заявки
+	Currency       string           `json:"currency"`
+	ExchangeRate   *decimal.Decimal `json:"exchange_rate,omitempty"`
+	TotalPriceBase *decimal.Decimal `json:"total_price_base,omitempty"`
 
 	// Связанные данные (вложенные объекты)
 	Partner     *Partner   `json:"partner,omitempty"`
@@ -54,4 +70,10 @@ type Request struct {
 
 	// Поля для агрегации, которые не хранятся в таблице напрямую
 	TotalSum *decimal.Decimal `json:"total_sum,omitempty"`
+	// Число непрочитанных текущим пользователем сообщений переписки (заполняется в списках)
+	UnreadComments int `json:"unread_comments"`
+
+	// Пересечение с ранее зарегистрированной сделкой другого партнера (заполняется для менеджера)
+	IsConflicting bool              `json:"is_conflicting,omitempty"`
+	Conflicts     []RequestConflict `json:"conflicts,omitempty"`
 }
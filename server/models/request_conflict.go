@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Направление пересечения относительно просматриваемой заявки
+const (
+	ConflictEarlier = "earlier" // Другая заявка зарегистрирована раньше — просматриваемая помечена конфликтной
+	ConflictLater   = "later"   // Другая заявка зарегистрирована позже и помечена конфликтной
+)
+
+// RequestConflict — заявка другого партнера на ту же сделку (тот же конечный клиент, похожий проект).
+// Поля другой заявки отражают ее текущее состояние.
+type RequestConflict struct {
+	RequestID      int              `json:"request_id"`
+	Direction      string           `json:"direction"`
+	Reasons        []string         `json:"reasons"`
+	NameSimilarity *float64         `json:"name_similarity,omitempty"`
+	DetectedAt     time.Time        `json:"detected_at"`
+	PartnerID      int              `json:"partner_id"`
+	PartnerName    string           `json:"partner_name"`
+	ProjectName    *string          `json:"project_name,omitempty"`
+	TotalPrice     *decimal.Decimal `json:"total_price,omitempty"`
+	Currency       string           `json:"currency"`
+	TotalPriceBase *decimal.Decimal `json:"total_price_base,omitempty"`
+	Status         RequestStatus    `json:"status"`
+	Withdrawn      bool             `json:"withdrawn"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ExtensionStatus определяет состояние запроса на продление защиты сделки
+type ExtensionStatus string
+
+const (
+	ExtensionPending  ExtensionStatus = "PENDING"
+	ExtensionApproved ExtensionStatus = "APPROVED"
+	ExtensionRejected ExtensionStatus = "REJECTED"
+)
+
+// RequestExtension — запрос партнера на продление защиты одобренной сделки.
+// Рассматривается ответственным менеджером; пока запрос не рассмотрен, защита не истекает.
+type RequestExtension struct {
+	ID             int             `json:"id"`
+	RequestID      int             `json:"request_id"`
+	RequestedBy    *int            `json:"requested_by,omitempty"`
+	RequestedUntil time.Time       `json:"requested_until"`
+	Reason         string          `json:"reason"`
+	Status         ExtensionStatus `json:"status"`
+	ApprovedUntil  *time.Time      `json:"approved_until,omitempty"` // Может отличаться от запрошенной даты
+	ReviewerID     *int            `json:"reviewer_id,omitempty"`
+	ReviewComment  *string         `json:"review_comment,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	ReviewedAt     *time.Time      `json:"reviewed_at,omitempty"`
+}
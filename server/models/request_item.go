@@ -0,0 +1,23 @@
+package models
+
+import "github.com/shopspring/decimal"
+
+// RequestItem — товарная позиция заявки. Итог строки и сумма заявки рассчитываются на сервере.
+type RequestItem struct {
+	ID              int             `json:"id"`
+	RequestID       int             `json:"request_id"`
+	Position        int             `json:"position"` // Порядковый номер позиции в заявке, с 1
+	ProductName     string          `json:"product_name"`
+	SKU             *string         `json:"sku,omitempty"`
+	Quantity        int             `json:"quantity"`
+	UnitPrice       decimal.Decimal `json:"unit_price"`
+	DiscountPercent decimal.Decimal `json:"discount_percent"`
+	LineTotal       decimal.Decimal `json:"line_total"`
+
+	// Продукт каталога, указанный по SKU, и его прайсовая цена на момент регистрации
+	ProductID    *int             `json:"product_id,omitempty"`
+	ListPrice    *decimal.Decimal `json:"list_price,omitempty"`
+	ListCurrency *string          `json:"list_currency,omitempty"`
+	// Скидка партнера от прайсовой цены с учетом discount_percent (рассчитывается при чтении)
+	ListDiscountPercent *decimal.Decimal `json:"list_discount_percent,omitempty"`
+}
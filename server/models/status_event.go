@@ -4,8 +4,12 @@ import "time"
 
 // StatusEvent представляет событие в истории изменения статуса заявки.
 type StatusEvent struct {
-	Status    string    `json:"status"`
-	ChangedAt time.Time `json:"changed_at"`
-	Comment   *string   `json:"comment,omitempty"`
-	ManagerID *int      `json:"manager_id,omitempty"`
+	ID          int       `json:"id"`
+	RequestID   int       `json:"request_id"`
+	OldStatus   *string   `json:"old_status,omitempty"` // nil для события создания заявки
+	Status      string    `json:"status"`
+	ChangedAt   time.Time `json:"changed_at"`
+	Comment     *string   `json:"comment,omitempty"`
+	ManagerID   *int      `json:"manager_id,omitempty"`
+	ManagerName *string   `json:"manager_name,omitempty"`
 }
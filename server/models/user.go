@@ -10,8 +10,19 @@ const (
 	RoleUser UserRole = "USER"
 	// RoleManager роль менеджера
 	RoleManager UserRole = "MANAGER"
+	// RoleAdmin роль администратора: управление пользователями, партнерами и назначениями
+	RoleAdmin UserRole = "ADMIN"
 )
 
+// IsValid сообщает, является ли роль одной из известных системе (значения user_role_enum).
+func (r UserRole) IsValid() bool {
+	switch r {
+	case RoleUser, RoleManager, RoleAdmin:
+		return true
+	}
+	return false
+}
+
 // User представляет пользователя системы
 type User struct {
 	ID           int       `json:"id"`
@@ -26,4 +37,7 @@ type User struct {
 
 	// Можно оставить поле для связи, если нужно будет подгружать партнера
 	Partner *Partner `json:"partner,omitempty"`
+
+	// Последняя заявка на присоединение к партнеру — статус онбординга для /api/me
+	Onboarding *JoinRequest `json:"onboarding,omitempty"`
 }
@@ -0,0 +1,138 @@
+// Package pricing проверяет товарные позиции заявки и рассчитывает их стоимость:
+// итог строки с учетом скидки и общую сумму заявки. Клиентским расчетам сервер не доверяет.
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+// MaxItems — максимальное число позиций в одной заявке.
+const MaxItems = 100
+
+// maxProductNameLength — максимальная длина названия товара в символах.
+const maxProductNameLength = 255
+
+// maxSKULength соответствует request_items.sku varchar(100).
+const maxSKULength = 100
+
+// MaxQuantity — предел request_items.quantity integer.
+const MaxQuantity = math.MaxInt32
+
+var (
+	hundred = decimal.NewFromInt(100)
+	// Пределы numeric(12,2) для цены и numeric(14,2) для итогов в request_items и requests.total_price
+	maxUnitPrice = decimal.New(1, 10)
+	maxLineTotal = decimal.New(1, 12)
+)
+
+// FieldError — ошибка в поле заявки. Field — путь к полю, как в JSON: "items[0].quantity", "total_price".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// LineTotal возвращает стоимость позиции: количество × цена за единицу за вычетом скидки в процентах,
+// округленную до копеек.
+func LineTotal(quantity int, unitPrice, discountPercent decimal.Decimal) decimal.Decimal {
+	gross := unitPrice.Mul(decimal.NewFromInt(int64(quantity)))
+	return gross.Sub(gross.Mul(discountPercent).Div(hundred)).Round(2)
+}
+
+// Total возвращает сумму итогов позиций.
+func Total(items []models.RequestItem) decimal.Decimal {
+	total := decimal.Zero
+	for _, item := range items {
+		total = total.Add(item.LineTotal)
+	}
+	return total
+}
+
+// ListDiscount возвращает скидку позиции от прайсовой цены в процентах: насколько итог строки
+// меньше стоимости того же количества по прайсу. Отрицательное значение — цена выше прайсовой.
+// currency — валюта цен заявки. Возвращает nil, если прайсовая цена не указана, нулевая
+// или в другой валюте.
+func ListDiscount(item models.RequestItem, currency string) *decimal.Decimal {
+	if item.ListPrice == nil || !item.ListPrice.IsPositive() || item.Quantity <= 0 {
+		return nil
+	}
+	if item.ListCurrency != nil && *item.ListCurrency != currency {
+		return nil
+	}
+	listTotal := item.ListPrice.Mul(decimal.NewFromInt(int64(item.Quantity)))
+	discount := decimal.NewFromInt(1).Sub(item.LineTotal.Div(listTotal)).Mul(hundred).Round(2)
+	return &discount
+}
+
+// Prepare проверяет позиции, нормализует их (названия без пробелов по краям, нумерация с 1)
+// и заполняет итог каждой строки. Возвращает *FieldError; номер позиции в нем начинается с 0, как в JSON.
+// Количество и суммы ограничены размерами столбцов в БД.
+func Prepare(items []models.RequestItem) error {
+	if len(items) > MaxItems {
+		return &FieldError{Field: "items", Message: fmt.Sprintf("too many items: at most %d allowed", MaxItems)}
+	}
+	for i := range items {
+		item := &items[i]
+		if field, msg := normalize(item); msg != "" {
+			return &FieldError{Field: fmt.Sprintf("items[%d].%s", i, field), Message: msg}
+		}
+		item.Position = i + 1
+		item.LineTotal = LineTotal(item.Quantity, item.UnitPrice, item.DiscountPercent)
+		if !item.LineTotal.LessThan(maxLineTotal) {
+			return &FieldError{Field: fmt.Sprintf("items[%d].line_total", i), Message: "line total is too large"}
+		}
+	}
+	if !Total(items).LessThan(maxLineTotal) {
+		return &FieldError{Field: "total_price", Message: "total price is too large"}
+	}
+	return nil
+}
+
+// normalize проверяет и нормализует позицию. Возвращает поле и текст ошибки, если позиция неверна.
+func normalize(item *models.RequestItem) (field, message string) {
+	item.ProductName = strings.TrimSpace(item.ProductName)
+	if item.ProductName == "" {
+		return "product_name", "product_name is required"
+	}
+	if utf8.RuneCountInString(item.ProductName) > maxProductNameLength {
+		return "product_name", fmt.Sprintf("product_name must be at most %d characters", maxProductNameLength)
+	}
+	if item.SKU != nil {
+		sku := strings.TrimSpace(*item.SKU)
+		if sku == "" {
+			item.SKU = nil
+		} else if utf8.RuneCountInString(sku) > maxSKULength {
+			return "sku", fmt.Sprintf("sku must be at most %d characters", maxSKULength)
+		} else {
+			item.SKU = &sku
+		}
+	}
+	if item.Quantity <= 0 {
+		return "quantity", "quantity must be positive"
+	}
+	if item.Quantity > MaxQuantity {
+		return "quantity", fmt.Sprintf("quantity must be at most %d", MaxQuantity)
+	}
+	if item.UnitPrice.IsNegative() || !item.UnitPrice.LessThan(maxUnitPrice) {
+		return "unit_price", "unit_price is out of range"
+	}
+	if !item.UnitPrice.Equal(item.UnitPrice.Round(2)) {
+		return "unit_price", "unit_price must have at most 2 decimal places"
+	}
+	if item.DiscountPercent.IsNegative() || item.DiscountPercent.GreaterThan(hundred) {
+		return "discount_percent", "discount_percent must be between 0 and 100"
+	}
+	if !item.DiscountPercent.Equal(item.DiscountPercent.Round(2)) {
+		return "discount_percent", "discount_percent must have at most 2 decimal places"
+	}
+	return "", ""
+}
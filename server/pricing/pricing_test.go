@@ -0,0 +1,131 @@
+package pricing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/shopspring/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestLineTotal(t *testing.T) {
+	tests := []struct {
+		name     string
+		quantity int
+		price    string
+		discount string
+		want     string
+	}{
+		{"NoDiscount", 3, "1500.50", "0", "4501.5"},
+		{"Discount", 10, "99.99", "15", "849.92"},
+		{"FullDiscount", 2, "500", "100", "0"},
+		{"RoundsToKopecks", 1, "0.05", "50", "0.03"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := LineTotal(tc.quantity, dec(tc.price), dec(tc.discount))
+			if !got.Equal(dec(tc.want)) {
+				t.Errorf("LineTotal(%d, %s, %s) = %s, want %s", tc.quantity, tc.price, tc.discount, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrepare(t *testing.T) {
+	sku := "  SRV-01 "
+	items := []models.RequestItem{
+		{ProductName: " Сервер ", SKU: &sku, Quantity: 2, UnitPrice: dec("100000"), DiscountPercent: dec("10")},
+		{ProductName: "Лицензия", Quantity: 5, UnitPrice: dec("2000.40")},
+	}
+	if err := Prepare(items); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if items[0].ProductName != "Сервер" || items[0].SKU == nil || *items[0].SKU != "SRV-01" {
+		t.Errorf("item not normalized: %+v", items[0])
+	}
+	if items[0].Position != 1 || items[1].Position != 2 {
+		t.Errorf("positions = %d, %d, want 1, 2", items[0].Position, items[1].Position)
+	}
+	if !items[0].LineTotal.Equal(dec("180000")) || !items[1].LineTotal.Equal(dec("10002")) {
+		t.Errorf("line totals = %s, %s", items[0].LineTotal, items[1].LineTotal)
+	}
+	if total := Total(items); !total.Equal(dec("190002")) {
+		t.Errorf("Total = %s, want 190002", total)
+	}
+}
+
+func TestPrepareRejectsInvalidItems(t *testing.T) {
+	tests := []struct {
+		name string
+		item models.RequestItem
+		want string
+	}{
+		{"NoName", models.RequestItem{ProductName: " ", Quantity: 1}, "product_name"},
+		{"ZeroQuantity", models.RequestItem{ProductName: "Сервер", Quantity: 0}, "quantity"},
+		{"NegativePrice", models.RequestItem{ProductName: "Сервер", Quantity: 1, UnitPrice: dec("-1")}, "unit_price"},
+		{"FractionalKopecks", models.RequestItem{ProductName: "Сервер", Quantity: 1, UnitPrice: dec("1.005")}, "unit_price"},
+		{"DiscountOver100", models.RequestItem{ProductName: "Сервер", Quantity: 1, DiscountPercent: dec("120")}, "discount_percent"},
+		{"TooLarge", models.RequestItem{ProductName: "Сервер", Quantity: 1000, UnitPrice: dec("9999999999")}, "too large"},
+		{"QuantityOverflow", models.RequestItem{ProductName: "Сервер", Quantity: MaxQuantity + 1}, "quantity"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			items := []models.RequestItem{{ProductName: "Кабель", Quantity: 1, UnitPrice: dec("10")}, tc.item}
+			err := Prepare(items)
+			var fieldErr *FieldError
+			if !errors.As(err, &fieldErr) || !strings.HasPrefix(fieldErr.Field, "items[1].") || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Prepare() error = %v, want items[1] %s field error", err, tc.want)
+			}
+		})
+	}
+
+	if err := Prepare(make([]models.RequestItem, MaxItems+1)); err == nil {
+		t.Error("Prepare() accepted more than MaxItems items")
+	}
+
+	// Каждая позиция в пределах, а сумма — нет
+	items := make([]models.RequestItem, 20)
+	for i := range items {
+		items[i] = models.RequestItem{ProductName: "Сервер", Quantity: 99, UnitPrice: dec("9999999999")}
+	}
+	var fieldErr *FieldError
+	if err := Prepare(items); !errors.As(err, &fieldErr) || fieldErr.Field != "total_price" {
+		t.Errorf("Prepare() error = %v, want total_price field error", err)
+	}
+}
+
+func TestListDiscount(t *testing.T) {
+	rub, usd := "RUB", "USD"
+	listPrice := dec("1000")
+	tests := []struct {
+		name     string
+		item     models.RequestItem
+		currency string
+		want     string // пустая строка — скидка не рассчитывается
+	}{
+		{"Discount", models.RequestItem{Quantity: 2, LineTotal: dec("1500"), ListPrice: &listPrice, ListCurrency: &rub}, "RUB", "25"},
+		{"AboveList", models.RequestItem{Quantity: 1, LineTotal: dec("1100"), ListPrice: &listPrice}, "RUB", "-10"},
+		{"NoListPrice", models.RequestItem{Quantity: 1, LineTotal: dec("1100")}, "RUB", ""},
+		{"SameForeignCurrency", models.RequestItem{Quantity: 1, LineTotal: dec("900"), ListPrice: &listPrice, ListCurrency: &usd}, "USD", "10"},
+		{"OtherCurrency", models.RequestItem{Quantity: 1, LineTotal: dec("900"), ListPrice: &listPrice, ListCurrency: &usd}, "RUB", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := ListDiscount(tc.item, tc.currency)
+			switch {
+			case tc.want == "" && got != nil:
+				t.Errorf("ListDiscount() = %s, want nil", got)
+			case tc.want != "" && (got == nil || !got.Equal(dec(tc.want))):
+				t.Errorf("ListDiscount() = %v, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// FileRegistry — локальный реестр из JSON-файла для разработки и тестов.
+// Файл содержит массив организаций в формате EndClient: [{"inn": "...", "name": "...", ...}].
+type FileRegistry struct {
+	companies map[string]models.EndClient
+}
+
+// NewFileRegistry загружает организации из файла path.
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registry file: %w", err)
+	}
+	var list []models.EndClient
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse registry file %s: %w", path, err)
+	}
+
+	companies := make(map[string]models.EndClient, len(list))
+	for _, c := range list {
+		if c.INN == nil || *c.INN == "" {
+			return nil, fmt.Errorf("registry file %s: company %q has no INN", path, c.Name)
+		}
+		c.ID = 0
+		c.MergedIntoID = nil
+		c.External = true
+		setOrgType(&c)
+		companies[*c.INN] = c
+	}
+	return &FileRegistry{companies: companies}, nil
+}
+
+// LookupByINN возвращает организацию из файла или ErrNotFound.
+func (r *FileRegistry) LookupByINN(ctx context.Context, inn string) (*models.EndClient, error) {
+	c, ok := r.companies[inn]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &c, nil
+}
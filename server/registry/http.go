@@ -0,0 +1,145 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// HTTPRegistry обращается к внешнему реестру по протоколу findById/party
+// (совместим с API подсказок DaData): POST {"query": "<ИНН>"} и ответ {"suggestions": [...]}.
+type HTTPRegistry struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTPRegistry создает адаптер реестра по адресу url. Токен передается в заголовке
+// Authorization: Token <token>. Если client равен nil, используется http.DefaultClient.
+func NewHTTPRegistry(url, token string, client *http.Client) *HTTPRegistry {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPRegistry{url: url, token: token, client: client}
+}
+
+type partySuggestions struct {
+	Suggestions []struct {
+		Value string `json:"value"`
+		Data  struct {
+			INN  string `json:"inn"`
+			KPP  string `json:"kpp"`
+			OGRN string `json:"ogrn"`
+			Name struct {
+				ShortWithOPF string `json:"short_with_opf"`
+			} `json:"name"`
+			Address *struct {
+				Value string `json:"value"`
+				Data  struct {
+					City string `json:"city"`
+				} `json:"data"`
+			} `json:"address"`
+			Management *struct {
+				Name string `json:"name"`
+				Post string `json:"post"`
+			} `json:"management"`
+		} `json:"data"`
+	} `json:"suggestions"`
+}
+
+// LookupByINN запрашивает организацию в реестре. Берется первая (головная) организация из ответа.
+func (r *HTTPRegistry) LookupByINN(ctx context.Context, inn string) (*models.EndClient, error) {
+	body, err := json.Marshal(map[string]any{"query": inn, "count": 1})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build registry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Token "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("registry responded with status %d", resp.StatusCode)
+	}
+
+	var parsed partySuggestions
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode registry response: %w", err)
+	}
+	if len(parsed.Suggestions) == 0 {
+		return nil, ErrNotFound
+	}
+
+	s := parsed.Suggestions[0]
+	client := &models.EndClient{
+		Name:     firstNonEmpty(s.Data.Name.ShortWithOPF, s.Value),
+		INN:      optional(firstNonEmpty(s.Data.INN, inn)),
+		KPP:      optional(s.Data.KPP),
+		OGRN:     optional(s.Data.OGRN),
+		External: true,
+	}
+	if s.Data.Address != nil {
+		client.FullAddress = optional(s.Data.Address.Value)
+		client.City = optional(s.Data.Address.Data.City)
+	}
+	if m := s.Data.Management; m != nil && m.Name != "" {
+		contact := m.Name
+		if m.Post != "" {
+			contact = m.Post + ": " + m.Name
+		}
+		client.ContactPersonDetails = &contact
+	}
+	setOrgType(client)
+	return client, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func optional(s string) *string {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// setOrgType определяет тип организации по длине ИНН, как это делает столбец org_type в БД.
+func setOrgType(client *models.EndClient) {
+	if client.INN == nil {
+		return
+	}
+	var orgType models.OrgType
+	switch len(*client.INN) {
+	case 10:
+		orgType = models.OrgTypeLegalEntity
+	case 12:
+		orgType = models.OrgTypeIndividualEntrepreneur
+	default:
+		return
+	}
+	client.OrgType = &orgType
+}
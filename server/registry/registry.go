@@ -0,0 +1,114 @@
+// Package registry ищет сведения об организациях во внешнем реестре (ЕГРЮЛ/ЕГРИП через
+// сторонний сервис) для автозаполнения конечного клиента по ИНН. Найденные данные не сохраняются
+// в end_clients: клиент создается только вместе с заявкой.
+package registry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// ErrNotFound возвращается, если организации с таким ИНН в реестре нет.
+var ErrNotFound = errors.New("company not found in registry")
+
+// CompanyRegistry — источник сведений об организациях.
+// Возвращает конечного клиента без ID с External = true.
+type CompanyRegistry interface {
+	LookupByINN(ctx context.Context, inn string) (*models.EndClient, error)
+}
+
+// maxCacheEntries ограничивает размер кеша; при переполнении удаляются устаревшие записи.
+const maxCacheEntries = 10000
+
+// Cached оборачивает реестр кешем ответов и ограничивает время одного обращения,
+// чтобы медленный реестр не задерживал заполнение заявки.
+type Cached struct {
+	next    CompanyRegistry
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	client  *models.EndClient // nil — организации в реестре нет
+	expires time.Time
+}
+
+// NewCached создает кеширующую обертку: найденные и ненайденные ИНН хранятся ttl,
+// ошибки (в том числе превышение timeout) не кешируются.
+func NewCached(next CompanyRegistry, ttl, timeout time.Duration) *Cached {
+	return &Cached{
+		next:    next,
+		ttl:     ttl,
+		timeout: timeout,
+		entries: make(map[string]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// LookupByINN возвращает данные из кеша или запрашивает реестр не дольше timeout.
+func (c *Cached) LookupByINN(ctx context.Context, inn string) (*models.EndClient, error) {
+	if client, ok := c.cached(inn); ok {
+		if client == nil {
+			return nil, ErrNotFound
+		}
+		return client, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	client, err := c.next.LookupByINN(ctx, inn)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		c.store(inn, nil)
+		return nil, ErrNotFound
+	case err != nil:
+		return nil, err
+	}
+	c.store(inn, client)
+	copied := *client
+	return &copied, nil
+}
+
+func (c *Cached) cached(inn string) (*models.EndClient, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[inn]
+	if !ok || c.now().After(entry.expires) {
+		return nil, false
+	}
+	if entry.client == nil {
+		return nil, true
+	}
+	// Копия, чтобы вызывающий код не менял закешированные данные
+	copied := *entry.client
+	return &copied, true
+}
+
+func (c *Cached) store(inn string, client *models.EndClient) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.entries) >= maxCacheEntries {
+		for key, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			c.entries = make(map[string]cacheEntry)
+		}
+	}
+	if client != nil {
+		copied := *client
+		client = &copied
+	}
+	c.entries[inn] = cacheEntry{client: client, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,183 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+func TestFileRegistry(t *testing.T) {
+	reg, err := NewFileRegistry("testdata/companies.json")
+	if err != nil {
+		t.Fatalf("NewFileRegistry: %v", err)
+	}
+
+	client, err := reg.LookupByINN(context.Background(), "7707083893")
+	if err != nil {
+		t.Fatalf("LookupByINN: %v", err)
+	}
+	if client.Name != "ПАО Сбербанк" || !client.External || client.ID != 0 {
+		t.Errorf("got %+v, want external ПАО Сбербанк without ID", client)
+	}
+	if client.OrgType == nil || *client.OrgType != models.OrgTypeLegalEntity {
+		t.Errorf("OrgType = %v, want %q", client.OrgType, models.OrgTypeLegalEntity)
+	}
+
+	individual, err := reg.LookupByINN(context.Background(), "500100732259")
+	if err != nil || individual.OrgType == nil || *individual.OrgType != models.OrgTypeIndividualEntrepreneur {
+		t.Errorf("individual entrepreneur lookup = (%+v, %v)", individual, err)
+	}
+
+	if _, err := reg.LookupByINN(context.Background(), "7736050003"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown INN error = %v, want ErrNotFound", err)
+	}
+}
+
+// stubRegistry считает обращения и отвечает заданным результатом.
+type stubRegistry struct {
+	calls  int
+	client *models.EndClient
+	err    error
+	delay  time.Duration
+}
+
+func (s *stubRegistry) LookupByINN(ctx context.Context, inn string) (*models.EndClient, error) {
+	s.calls++
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	copied := *s.client
+	return &copied, nil
+}
+
+func TestCachedStoresResultsUntilTTL(t *testing.T) {
+	stub := &stubRegistry{client: &models.EndClient{Name: "ООО Ромашка", External: true}}
+	cached := NewCached(stub, time.Hour, time.Second)
+	now := time.Now()
+	cached.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		client, err := cached.LookupByINN(context.Background(), "7707083893")
+		if err != nil || client.Name != "ООО Ромашка" {
+			t.Fatalf("lookup %d = (%+v, %v)", i, client, err)
+		}
+		client.Name = "изменено вызывающим кодом"
+	}
+	if stub.calls != 1 {
+		t.Errorf("registry called %d times, want 1", stub.calls)
+	}
+
+	now = now.Add(2 * time.Hour)
+	client, err := cached.LookupByINN(context.Background(), "7707083893")
+	if err != nil || client.Name != "ООО Ромашка" {
+		t.Fatalf("lookup after TTL = (%+v, %v)", client, err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("registry called %d times after TTL, want 2", stub.calls)
+	}
+}
+
+func TestCachedStoresNotFoundButNotErrors(t *testing.T) {
+	notFound := &stubRegistry{err: ErrNotFound}
+	cached := NewCached(notFound, time.Hour, time.Second)
+	for i := 0; i < 2; i++ {
+		if _, err := cached.LookupByINN(context.Background(), "7707083893"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("lookup %d error = %v, want ErrNotFound", i, err)
+		}
+	}
+	if notFound.calls != 1 {
+		t.Errorf("registry called %d times for missing INN, want 1", notFound.calls)
+	}
+
+	failing := &stubRegistry{err: errors.New("registry unavailable")}
+	cached = NewCached(failing, time.Hour, time.Second)
+	for i := 0; i < 2; i++ {
+		if _, err := cached.LookupByINN(context.Background(), "7707083893"); err == nil {
+			t.Fatalf("lookup %d succeeded, want error", i)
+		}
+	}
+	if failing.calls != 2 {
+		t.Errorf("registry called %d times after errors, want 2", failing.calls)
+	}
+}
+
+func TestCachedTimeout(t *testing.T) {
+	slow := &stubRegistry{client: &models.EndClient{Name: "ООО Ромашка"}, delay: time.Second}
+	cached := NewCached(slow, time.Hour, 10*time.Millisecond)
+
+	start := time.Now()
+	_, err := cached.LookupByINN(context.Background(), "7707083893")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("lookup took %v, want it bounded by the timeout", elapsed)
+	}
+}
+
+func TestHTTPRegistry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req struct {
+			Query string `json:"query"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Query != "7707083893" {
+			w.Write([]byte(`{"suggestions": []}`))
+			return
+		}
+		w.Write([]byte(`{"suggestions": [{
+			"value": "ПАО СБЕРБАНК",
+			"data": {
+				"inn": "7707083893", "kpp": "773601001", "ogrn": "1027700132195",
+				"name": {"short_with_opf": "ПАО Сбербанк"},
+				"address": {"value": "г Москва, ул Вавилова, д 19", "data": {"city": "Москва"}},
+				"management": {"name": "Греф Герман Оскарович", "post": "Президент"}
+			}
+		}]}`))
+	}))
+	defer server.Close()
+
+	reg := NewHTTPRegistry(server.URL, "secret", server.Client())
+	client, err := reg.LookupByINN(context.Background(), "7707083893")
+	if err != nil {
+		t.Fatalf("LookupByINN: %v", err)
+	}
+	if client.Name != "ПАО Сбербанк" || !client.External {
+		t.Errorf("got %+v, want external ПАО Сбербанк", client)
+	}
+	if client.City == nil || *client.City != "Москва" || client.KPP == nil || *client.KPP != "773601001" {
+		t.Errorf("address or KPP not filled: %+v", client)
+	}
+	if client.ContactPersonDetails == nil || *client.ContactPersonDetails != "Президент: Греф Герман Оскарович" {
+		t.Errorf("ContactPersonDetails = %v", client.ContactPersonDetails)
+	}
+
+	if _, err := reg.LookupByINN(context.Background(), "500100732259"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown INN error = %v, want ErrNotFound", err)
+	}
+
+	unauthorized := NewHTTPRegistry(server.URL, "wrong", server.Client())
+	if _, err := unauthorized.LookupByINN(context.Background(), "7707083893"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("error with wrong token = %v, want status error", err)
+	}
+}
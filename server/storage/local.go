@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore хранит объекты файлами в каталоге; ключ — путь относительно каталога.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore создает хранилище в каталоге dir, создавая его при необходимости.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+func (s *LocalStore) path(key string) (string, error) {
+	if !validKey(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}
+
+// Put пишет объект во временный файл рядом с целевым и переименовывает его после записи,
+// поэтому читатели никогда не видят недописанный объект.
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create temporary blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // После успешного переименования ничего не удаляет
+
+	written, err := io.Copy(tmp, contextReader{ctx, r})
+	if err == nil && size >= 0 && written != size {
+		err = ErrSizeMismatch
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("failed to store blob %s: %w", key, err)
+	}
+	return written, nil
+}
+
+// Open открывает файл объекта.
+func (s *LocalStore) Open(ctx context.Context, key string) (Blob, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to open blob %s: %w", key, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat blob %s: %w", key, err)
+	}
+	return &localBlob{File: f, size: info.Size()}, nil
+}
+
+// Delete удаляет файл объекта.
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	return nil
+}
+
+type localBlob struct {
+	*os.File
+	size int64
+}
+
+func (b *localBlob) Size() int64 {
+	return b.size
+}
+
+// contextReader прерывает чтение при отмене контекста (например, если клиент оборвал загрузку).
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	// unsignedPayload — тело запроса не входит в подпись: содержимое файла передается потоком,
+	// и считать его хеш до отправки пришлось бы с повторным чтением
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	emptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// maxS3ErrorBody — сколько байт ответа с ошибкой включать в текст ошибки
+	maxS3ErrorBody = 1024
+)
+
+// S3Config — параметры подключения к S3-совместимому хранилищу (AWS S3, MinIO, Yandex Object Storage).
+type S3Config struct {
+	Endpoint        string // Например https://storage.yandexcloud.net или http://minio:9000
+	Bucket          string
+	Region          string // По умолчанию us-east-1
+	AccessKeyID     string
+	SecretAccessKey string
+	// VirtualHosted — адресовать бакет поддоменом (bucket.endpoint) вместо пути (endpoint/bucket)
+	VirtualHosted bool
+	Client        *http.Client // Если nil, используется http.DefaultClient
+}
+
+// S3Store хранит объекты в бакете S3-совместимого хранилища. Запросы подписываются AWS Signature V4.
+type S3Store struct {
+	cfg      S3Config
+	endpoint *url.URL
+	now      func() time.Time
+}
+
+// NewS3Store проверяет конфигурацию и создает хранилище. Наличие бакета не проверяется.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKeyID == "" || cfg.SecretAccessKey == "" {
+		return nil, errors.New("S3 storage requires endpoint, bucket, access key ID and secret access key")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultS3Region
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &S3Store{cfg: cfg, endpoint: endpoint, now: time.Now}, nil
+}
+
+// objectURL возвращает адрес объекта. Ключи проверены validKey и не требуют экранирования.
+func (s *S3Store) objectURL(key string) string {
+	u := *s.endpoint
+	if s.cfg.VirtualHosted {
+		u.Host = s.cfg.Bucket + "." + u.Host
+		u.Path += "/" + key
+	} else {
+		u.Path += "/" + s.cfg.Bucket + "/" + key
+	}
+	return u.String()
+}
+
+// do подписывает и выполняет запрос к объекту.
+func (s *S3Store) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("invalid blob key %q", key)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	payloadHash := emptyPayload
+	if body != nil {
+		req.ContentLength = size
+		payloadHash = unsignedPayload
+	}
+	signV4(req, payloadHash, s.cfg.AccessKeyID, s.cfg.SecretAccessKey, s.cfg.Region, s.now())
+	return s.cfg.Client.Do(req)
+}
+
+// Put загружает объект одним запросом PUT. Если размер неизвестен, содержимое сначала
+// сохраняется во временный файл: S3 требует Content-Length.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error) {
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "blob-*")
+		if err != nil {
+			return 0, fmt.Errorf("failed to create temporary blob file: %w", err)
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if size, err = io.Copy(tmp, contextReader{ctx, r}); err != nil {
+			return 0, fmt.Errorf("failed to buffer blob %s: %w", key, err)
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		r = tmp
+	}
+
+	counter := &countingReader{r: io.LimitReader(contextReader{ctx, r}, size+1)}
+	resp, err := s.do(ctx, http.MethodPut, key, counter, size, http.Header{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		if counter.n != size {
+			return 0, fmt.Errorf("failed to upload blob %s: %w", key, ErrSizeMismatch)
+		}
+		return 0, fmt.Errorf("failed to upload blob %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, s3Error("upload", key, resp)
+	}
+	return size, nil
+}
+
+// Open запрашивает размер объекта (HEAD); содержимое загружается при чтении, начиная с текущей позиции.
+func (s *S3Store) Open(ctx context.Context, key string) (Blob, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, 0, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat blob %s: %w", key, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, s3Error("stat", key, resp)
+	}
+	return &s3Blob{store: s, ctx: ctx, key: key, size: resp.ContentLength}, nil
+}
+
+// Delete удаляет объект. S3 отвечает 204 и на удаление несуществующего объекта.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, 0, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete blob %s: %w", key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", key, resp)
+	}
+	return nil
+}
+
+func s3Error(op, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxS3ErrorBody))
+	return fmt.Errorf("failed to %s blob %s: S3 responded %s: %s", op, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// s3Blob читает объект запросом GET с Range от текущей позиции; Seek закрывает текущий ответ,
+// и следующее чтение начинается с новой позиции.
+type s3Blob struct {
+	store  *S3Store
+	ctx    context.Context
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (b *s3Blob) Size() int64 {
+	return b.size
+}
+
+func (b *s3Blob) Read(p []byte) (int, error) {
+	if b.offset >= b.size {
+		return 0, io.EOF
+	}
+	if b.body == nil {
+		header := http.Header{"Range": {fmt.Sprintf("bytes=%d-", b.offset)}}
+		resp, err := b.store.do(b.ctx, http.MethodGet, b.key, nil, 0, header)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read blob %s: %w", b.key, err)
+		}
+		if resp.StatusCode != http.StatusPartialContent && !(resp.StatusCode == http.StatusOK && b.offset == 0) {
+			defer resp.Body.Close()
+			return 0, s3Error("read", b.key, resp)
+		}
+		b.body = resp.Body
+	}
+	n, err := b.body.Read(p)
+	b.offset += int64(n)
+	if err == io.EOF && b.offset < b.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (b *s3Blob) Seek(offset int64, whence int) (int64, error) {
+	var next int64
+	switch whence {
+	case io.SeekStart:
+		next = offset
+	case io.SeekCurrent:
+		next = b.offset + offset
+	case io.SeekEnd:
+		next = b.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if next < 0 {
+		return 0, errors.New("negative position")
+	}
+	if next != b.offset && b.body != nil {
+		b.body.Close()
+		b.body = nil
+	}
+	b.offset = next
+	return next, nil
+}
+
+func (b *s3Blob) Close() error {
+	if b.body != nil {
+		err := b.body.Close()
+		b.body = nil
+		return err
+	}
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// signV4 подписывает запрос к S3 по AWS Signature Version 4 (заголовок Authorization).
+// Подписываются host, range и все x-amz-* заголовки.
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "range" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, canonicalQuery(req.URL.Query()), canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery кодирует параметры запроса по правилам SigV4: сортировка по имени,
+// пробел — %20, а не '+'.
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		vals := append([]string(nil), values[key]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, sigV4Escape(key)+"="+sigV4Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func sigV4Escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,120 @@
+// Package storage хранит содержимое загруженных файлов вне базы данных: в локальном каталоге
+// или в S3-совместимом хранилище. В таблице files остаются только метаданные и ключ объекта.
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound возвращается, если объекта с таким ключом в хранилище нет.
+var ErrNotFound = errors.New("blob not found")
+
+// ErrSizeMismatch возвращается Put, если прочитано не столько байт, сколько было заявлено.
+var ErrSizeMismatch = errors.New("blob size does not match declared size")
+
+// Blob — открытый для чтения объект. Seek позволяет отдавать его частями (Range-запросы).
+type Blob interface {
+	io.ReadSeekCloser
+	Size() int64
+}
+
+// BlobStore — хранилище содержимого файлов.
+type BlobStore interface {
+	// Put сохраняет содержимое r под ключом key и возвращает число записанных байт.
+	// size — ожидаемый размер или -1, если он заранее неизвестен. Частично записанный
+	// объект при ошибке не остается.
+	Put(ctx context.Context, key string, r io.Reader, size int64) (int64, error)
+	// Open открывает объект для чтения; ErrNotFound, если его нет.
+	Open(ctx context.Context, key string) (Blob, error)
+	// Delete удаляет объект; отсутствие объекта ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+}
+
+// ReaderAt позволяет читать объект с произвольных позиций, например archive/zip.
+// Чтения выполняются по очереди через Seek, поэтому последовательные — дешевы и для S3.
+func ReaderAt(b Blob) io.ReaderAt {
+	return &blobReaderAt{blob: b}
+}
+
+type blobReaderAt struct {
+	mu   sync.Mutex
+	blob Blob
+}
+
+func (r *blobReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.blob.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.blob, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// NewKey возвращает новый уникальный ключ объекта вида files/2006/01/<32 hex>.
+// Раскладка по месяцам ограничивает число файлов в одном каталоге локального хранилища.
+func NewKey(now time.Time) string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand не возвращает ошибок на поддерживаемых платформах
+		panic(fmt.Sprintf("storage: crypto/rand failed: %v", err))
+	}
+	return now.UTC().Format("files/2006/01/") + hex.EncodeToString(b)
+}
+
+// validKey проверяет, что ключ — относительный путь из латинских букв, цифр, '-', '_' и '.',
+// без пустых сегментов и переходов на уровень выше.
+func validKey(key string) bool {
+	if key == "" || len(key) > 512 {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+		for _, c := range segment {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// NewFromEnv настраивает хранилище по переменным окружения:
+// FILE_STORAGE=local (по умолчанию) с каталогом FILE_STORAGE_DIR (по умолчанию ./data/files)
+// или FILE_STORAGE=s3 с S3_ENDPOINT, S3_BUCKET, S3_REGION, S3_ACCESS_KEY_ID, S3_SECRET_ACCESS_KEY
+// и S3_USE_PATH_STYLE (по умолчанию true, как требует MinIO).
+func NewFromEnv() (BlobStore, error) {
+	switch backend := os.Getenv("FILE_STORAGE"); backend {
+	case "", "local":
+		dir := os.Getenv("FILE_STORAGE_DIR")
+		if dir == "" {
+			dir = "./data/files"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(S3Config{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			VirtualHosted:   os.Getenv("S3_USE_PATH_STYLE") == "false",
+		})
+	default:
+		return nil, fmt.Errorf("unknown FILE_STORAGE %q: must be local or s3", backend)
+	}
+}
@@ -0,0 +1,216 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// MIME-типы поддерживаемых файлов. Их же хранит files.mime_type.
+const (
+	MIMEPDF  = "application/pdf"
+	MIMEPNG  = "image/png"
+	MIMEJPEG = "image/jpeg"
+	MIMEText = "text/plain"
+	MIMEZip  = "application/zip"
+	MIMEDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MIMEXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	// MIMEUnknown — содержимое не распознано
+	MIMEUnknown = "application/octet-stream"
+)
+
+var (
+	// ErrUnsupportedType — тип содержимого не распознан или не входит в список разрешенных.
+	ErrUnsupportedType = errors.New("unsupported file type")
+	// ErrExtensionMismatch — расширение имени файла не соответствует его содержимому.
+	ErrExtensionMismatch = errors.New("file extension does not match its content")
+	// ErrInvalidArchive — файл начинается как ZIP, но его структура повреждена.
+	ErrInvalidArchive = errors.New("invalid ZIP archive")
+)
+
+// Type — поддерживаемый тип файла.
+type Type struct {
+	Name       string   // Короткое имя для UPLOAD_ALLOWED_TYPES
+	MIME       string   // Тип, сохраняемый в files.mime_type
+	Extensions []string // Допустимые расширения имени файла
+}
+
+// Types — все типы, которые умеет распознавать Detect и InspectZip.
+var Types = []Type{
+	{Name: "pdf", MIME: MIMEPDF, Extensions: []string{".pdf"}},
+	{Name: "png", MIME: MIMEPNG, Extensions: []string{".png"}},
+	{Name: "jpeg", MIME: MIMEJPEG, Extensions: []string{".jpg", ".jpeg"}},
+	{Name: "txt", MIME: MIMEText, Extensions: []string{".txt", ".csv"}},
+	{Name: "docx", MIME: MIMEDOCX, Extensions: []string{".docx"}},
+	{Name: "xlsx", MIME: MIMEXLSX, Extensions: []string{".xlsx"}},
+	{Name: "zip", MIME: MIMEZip, Extensions: []string{".zip"}},
+}
+
+// defaultAllowedTypes — разрешенные типы, если UPLOAD_ALLOWED_TYPES не задан.
+const defaultAllowedTypes = "pdf,png,jpeg,txt,docx,xlsx,zip"
+
+// AllowedTypes — набор разрешенных MIME-типов.
+type AllowedTypes map[string]bool
+
+// AllowedTypesFromEnv читает список разрешенных типов из UPLOAD_ALLOWED_TYPES — коротких имен
+// из Types через запятую (по умолчанию все). Неизвестные имена пропускаются.
+func AllowedTypesFromEnv() AllowedTypes {
+	allowed := parseAllowedTypes(os.Getenv("UPLOAD_ALLOWED_TYPES"))
+	if len(allowed) == 0 {
+		allowed = parseAllowedTypes(defaultAllowedTypes)
+	}
+	return allowed
+}
+
+func parseAllowedTypes(list string) AllowedTypes {
+	allowed := AllowedTypes{}
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		for _, t := range Types {
+			if t.Name == name {
+				allowed[t.MIME] = true
+			}
+		}
+	}
+	return allowed
+}
+
+// AllowsArchive сообщает, разрешен ли хотя бы один тип, хранящийся в ZIP-контейнере.
+// Какой именно, становится известно только после InspectZip.
+func (a AllowedTypes) AllowsArchive() bool {
+	return a[MIMEZip] || a[MIMEDOCX] || a[MIMEXLSX]
+}
+
+// Detect определяет тип по первым байтам содержимого (сигнатурам форматов), не доверяя
+// ни заявленному клиентом Content-Type, ни расширению. Документы Office Open XML
+// распознаются как MIMEZip; уточняет тип InspectZip.
+func Detect(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return MIMEPDF
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return MIMEPNG
+	case bytes.HasPrefix(head, []byte("\xff\xd8\xff")):
+		return MIMEJPEG
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return MIMEZip
+	}
+	// Текст — всё, в чем http.DetectContentType не нашел двоичных данных и разметки
+	if strings.HasPrefix(http.DetectContentType(head), "text/plain") {
+		return MIMEText
+	}
+	return MIMEUnknown
+}
+
+// CheckExtension проверяет, что расширение имени файла допустимо для типа mimeType.
+func CheckExtension(name, mimeType string) error {
+	ext := strings.ToLower(path.Ext(name))
+	for _, t := range Types {
+		if t.MIME != mimeType {
+			continue
+		}
+		for _, e := range t.Extensions {
+			if e == ext {
+				return nil
+			}
+		}
+	}
+	return ErrExtensionMismatch
+}
+
+// archiveExtension сообщает, допустимо ли расширение для какого-либо типа в ZIP-контейнере.
+func archiveExtension(name string) bool {
+	for _, mimeType := range []string{MIMEZip, MIMEDOCX, MIMEXLSX} {
+		if CheckExtension(name, mimeType) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckDetected выполняет проверки, возможные до сохранения файла: тип по первым байтам
+// разрешен, а расширение ему соответствует. Для ZIP проверка окончательна только после InspectZip.
+func CheckDetected(name, detected string, allowed AllowedTypes) error {
+	if detected == MIMEZip {
+		if !allowed.AllowsArchive() {
+			return ErrUnsupportedType
+		}
+		if !archiveExtension(name) {
+			return ErrExtensionMismatch
+		}
+		return nil
+	}
+	if !allowed[detected] {
+		return ErrUnsupportedType
+	}
+	return CheckExtension(name, detected)
+}
+
+const (
+	// contentTypesPart — обязательная часть пакета Office Open XML со списком типов частей
+	contentTypesPart = "[Content_Types].xml"
+	// maxContentTypesSize — больше [Content_Types].xml не читаем (защита от ZIP-бомбы)
+	maxContentTypesSize = 1 << 20
+	docxMainPart        = "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"
+	xlsxMainPart        = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"
+)
+
+// InspectZip читает центральный каталог ZIP-архива и определяет, что в нем: документ Word
+// (MIMEDOCX), книга Excel (MIMEXLSX) или обычный архив (MIMEZip). Документ Office Open XML
+// признается, только если [Content_Types].xml объявляет главную часть и она есть в архиве.
+// Другие пакеты Office (презентации, документы с макросами) возвращают ErrUnsupportedType.
+func InspectZip(r io.ReaderAt, size int64) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	parts := make(map[string]bool, len(zr.File))
+	var contentTypes *zip.File
+	for _, f := range zr.File {
+		parts[f.Name] = true
+		if f.Name == contentTypesPart {
+			contentTypes = f
+		}
+	}
+	if contentTypes == nil {
+		return MIMEZip, nil
+	}
+
+	rc, err := contentTypes.Open()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxContentTypesSize+1))
+	if err != nil || len(data) > maxContentTypesSize {
+		return "", fmt.Errorf("%w: unreadable %s", ErrInvalidArchive, contentTypesPart)
+	}
+	var types struct {
+		Overrides []struct {
+			PartName    string `xml:"PartName,attr"`
+			ContentType string `xml:"ContentType,attr"`
+		} `xml:"Override"`
+	}
+	if err := xml.Unmarshal(data, &types); err != nil {
+		return "", fmt.Errorf("%w: malformed %s", ErrInvalidArchive, contentTypesPart)
+	}
+	for _, o := range types.Overrides {
+		if !parts[strings.TrimPrefix(o.PartName, "/")] {
+			continue
+		}
+		switch o.ContentType {
+		case docxMainPart:
+			return MIMEDOCX, nil
+		case xlsxMainPart:
+			return MIMEXLSX, nil
+		}
+	}
+	return "", ErrUnsupportedType
+}
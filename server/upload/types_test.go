@@ -0,0 +1,131 @@
+package upload
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"%PDF-1.7\n":                    MIMEPDF,
+		"\x89PNG\r\n\x1a\n\x00\x00":     MIMEPNG,
+		"\xff\xd8\xff\xe0\x00\x10JFIF":  MIMEJPEG,
+		"PK\x03\x04\x14\x00":            MIMEZip,
+		"Спецификация, позиция 1;2;3\n": MIMEText,
+		"<html><body>x</body></html>":   MIMEUnknown,
+		"MZ\x90\x00\x03\x00\x00\x00":    MIMEUnknown,
+	}
+	for head, want := range tests {
+		if got := Detect([]byte(head)); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", head, got, want)
+		}
+	}
+}
+
+func TestCheckDetected(t *testing.T) {
+	all := parseAllowedTypes(defaultAllowedTypes)
+	tests := []struct {
+		name, detected string
+		allowed        AllowedTypes
+		want           error
+	}{
+		{"spec.pdf", MIMEPDF, all, nil},
+		{"SPEC.PDF", MIMEPDF, all, nil},
+		{"photo.jpeg", MIMEJPEG, all, nil},
+		{"spec.docx", MIMEPDF, all, ErrExtensionMismatch},
+		{"spec", MIMEPDF, all, ErrExtensionMismatch},
+		{"spec.docx", MIMEZip, all, nil},
+		{"spec.pdf", MIMEZip, all, ErrExtensionMismatch},
+		{"setup.exe", MIMEUnknown, all, ErrUnsupportedType},
+		{"spec.pdf", MIMEPDF, parseAllowedTypes("png"), ErrUnsupportedType},
+		{"spec.docx", MIMEZip, parseAllowedTypes("pdf"), ErrUnsupportedType},
+	}
+	for _, tt := range tests {
+		if err := CheckDetected(tt.name, tt.detected, tt.allowed); !errors.Is(err, tt.want) {
+			t.Errorf("CheckDetected(%q, %q) = %v, want %v", tt.name, tt.detected, err, tt.want)
+		}
+	}
+}
+
+func TestAllowedTypesFromEnv(t *testing.T) {
+	t.Setenv("UPLOAD_ALLOWED_TYPES", " PDF, docx ,unknown")
+	allowed := AllowedTypesFromEnv()
+	if len(allowed) != 2 || !allowed[MIMEPDF] || !allowed[MIMEDOCX] {
+		t.Errorf("AllowedTypesFromEnv = %v", allowed)
+	}
+
+	t.Setenv("UPLOAD_ALLOWED_TYPES", "")
+	if allowed := AllowedTypesFromEnv(); len(allowed) != len(Types) {
+		t.Errorf("default allowed types = %v", allowed)
+	}
+}
+
+func makeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func contentTypes(part, contentType string) string {
+	return `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="` + part + `" ContentType="` + contentType + `"/>
+</Types>`
+}
+
+func TestInspectZip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+		err   error
+	}{
+		{"docx", map[string]string{
+			"[Content_Types].xml": contentTypes("/word/document.xml", docxMainPart),
+			"word/document.xml":   "<w:document/>",
+		}, MIMEDOCX, nil},
+		{"xlsx", map[string]string{
+			"[Content_Types].xml": contentTypes("/xl/workbook.xml", xlsxMainPart),
+			"xl/workbook.xml":     "<workbook/>",
+		}, MIMEXLSX, nil},
+		{"plain zip", map[string]string{"readme.txt": "hello"}, MIMEZip, nil},
+		{"main part missing", map[string]string{
+			"[Content_Types].xml": contentTypes("/word/document.xml", docxMainPart),
+		}, "", ErrUnsupportedType},
+		{"macro-enabled", map[string]string{
+			"[Content_Types].xml": contentTypes("/word/document.xml", "application/vnd.ms-word.document.macroEnabled.main+xml"),
+			"word/document.xml":   "<w:document/>",
+		}, "", ErrUnsupportedType},
+		{"malformed content types", map[string]string{
+			"[Content_Types].xml": "<Types><Override",
+			"word/document.xml":   "<w:document/>",
+		}, "", ErrInvalidArchive},
+	}
+	for _, tt := range tests {
+		data := makeZip(t, tt.files)
+		got, err := InspectZip(bytes.NewReader(data), int64(len(data)))
+		if got != tt.want || !errors.Is(err, tt.err) {
+			t.Errorf("%s: InspectZip = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+
+	// Сигнатура ZIP без центрального каталога
+	truncated := makeZip(t, map[string]string{"a.txt": "a"})[:40]
+	if _, err := InspectZip(bytes.NewReader(truncated), int64(len(truncated))); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("truncated archive: %v, want ErrInvalidArchive", err)
+	}
+}
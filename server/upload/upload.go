@@ -0,0 +1,191 @@
+// Package upload читает формы multipart/form-data потоком: файлы не буферизуются ни в памяти,
+// ни во временных файлах, а отдаются вызывающему по мере поступления, с проверкой лимитов
+// и определением типа содержимого по первым байтам.
+package upload
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	// maxFieldSize — предельный размер текстового поля формы (JSON заявки, текст сообщения)
+	maxFieldSize = 1 << 20
+	// sniffLen — сколько первых байт файла нужно Detect
+	sniffLen = 512
+)
+
+var (
+	// ErrNotMultipart — тело запроса не multipart/form-data или без boundary.
+	ErrNotMultipart = errors.New("request is not multipart/form-data")
+	// ErrFileTooLarge — файл больше Limits.MaxFileSize.
+	ErrFileTooLarge = errors.New("file exceeds size limit")
+	// ErrTooManyFiles — в форме больше Limits.MaxFiles файлов.
+	ErrTooManyFiles = errors.New("too many files")
+	// ErrFieldTooLarge — текстовое поле формы больше 1MB.
+	ErrFieldTooLarge = errors.New("form field exceeds size limit")
+)
+
+// ReadError — ошибка чтения тела запроса (оборванная или некорректная форма, превышение
+// MaxRequestSize), в отличие от ошибок того, кто сохраняет прочитанное.
+type ReadError struct {
+	Err error
+}
+
+func (e *ReadError) Error() string {
+	return "failed to read multipart form: " + e.Err.Error()
+}
+
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// Limits — ограничения на загрузку. Превышение MaxRequestSize проявляется как *http.MaxBytesError.
+type Limits struct {
+	MaxFileSize    int64 // Байт на один файл
+	MaxRequestSize int64 // Байт на всё тело запроса
+	MaxFiles       int   // Файлов в одном запросе
+}
+
+// LimitsFromEnv читает лимиты из UPLOAD_MAX_FILE_SIZE_MB (по умолчанию 15),
+// UPLOAD_MAX_REQUEST_SIZE_MB (по умолчанию 50) и UPLOAD_MAX_FILES (по умолчанию 10).
+func LimitsFromEnv() Limits {
+	return Limits{
+		MaxFileSize:    int64(positiveEnv("UPLOAD_MAX_FILE_SIZE_MB", 15)) << 20,
+		MaxRequestSize: int64(positiveEnv("UPLOAD_MAX_REQUEST_SIZE_MB", 50)) << 20,
+		MaxFiles:       positiveEnv("UPLOAD_MAX_FILES", 10),
+	}
+}
+
+func positiveEnv(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil && v > 0 {
+		return v
+	}
+	return def
+}
+
+// Form — форма, читаемая потоком. Текстовые поля накапливаются в Values по мере чтения,
+// поэтому полностью они доступны только после того, как NextFile вернул io.EOF.
+type Form struct {
+	Values  map[string]string
+	mr      *multipart.Reader
+	limits  Limits
+	files   int
+	current *File
+}
+
+// NewForm начинает чтение формы из тела запроса, ограничивая его размер limits.MaxRequestSize.
+// Запрос с заведомо большим Content-Length отклоняется сразу, не дожидаясь тела.
+func NewForm(w http.ResponseWriter, r *http.Request, limits Limits) (*Form, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, ErrNotMultipart
+	}
+	if r.ContentLength > limits.MaxRequestSize {
+		return nil, &http.MaxBytesError{Limit: limits.MaxRequestSize}
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limits.MaxRequestSize)
+	return &Form{
+		Values: make(map[string]string),
+		mr:     multipart.NewReader(r.Body, params["boundary"]),
+		limits: limits,
+	}, nil
+}
+
+// NextFile дочитывает предыдущий файл, если он прочитан не до конца, собирает текстовые поля
+// до следующего файла и возвращает его. Когда форма закончилась, возвращает io.EOF.
+func (f *Form) NextFile() (*File, error) {
+	if f.current != nil {
+		f.current.part.Close()
+		f.current = nil
+	}
+	for {
+		part, err := f.mr.NextPart()
+		if err == io.EOF {
+			return nil, err
+		}
+		if err != nil {
+			return nil, &ReadError{err}
+		}
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+			if err != nil {
+				return nil, &ReadError{err}
+			}
+			if len(value) > maxFieldSize {
+				return nil, ErrFieldTooLarge
+			}
+			// Как и r.FormValue, учитываем первое значение поля
+			if _, ok := f.Values[part.FormName()]; !ok {
+				f.Values[part.FormName()] = string(value)
+			}
+			continue
+		}
+
+		f.files++
+		if f.files > f.limits.MaxFiles {
+			return nil, ErrTooManyFiles
+		}
+		file, err := newFile(part, f.limits.MaxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		f.current = file
+		return file, nil
+	}
+}
+
+// File — файл формы, читаемый потоком. Read возвращает ErrFileTooLarge, как только
+// прочитано больше лимита, поэтому частично сохраненный файл нужно отбросить.
+type File struct {
+	Field        string // Имя поля формы
+	Name         string // Имя файла, как его прислал клиент
+	DeclaredType string // Content-Type части, указанный клиентом
+	DetectedType string // Тип, определенный по первым байтам (см. Detect); ему, а не DeclaredType, и доверяем
+
+	part  *multipart.Part
+	r     *bufio.Reader
+	size  int64
+	limit int64
+}
+
+func newFile(part *multipart.Part, limit int64) (*File, error) {
+	r := bufio.NewReaderSize(part, sniffLen)
+	// Peek не сдвигает позицию: первые байты будут прочитаны еще раз при сохранении
+	head, err := r.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, &ReadError{err}
+	}
+	return &File{
+		Field:        part.FormName(),
+		Name:         part.FileName(),
+		DeclaredType: part.Header.Get("Content-Type"),
+		DetectedType: Detect(head),
+		part:         part,
+		r:            r,
+		limit:        limit,
+	}, nil
+}
+
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	f.size += int64(n)
+	if f.size > f.limit {
+		return n, ErrFileTooLarge
+	}
+	if err != nil && err != io.EOF {
+		err = &ReadError{err}
+	}
+	return n, err
+}
+
+// Size возвращает число уже прочитанных байт; после чтения до io.EOF — размер файла.
+func (f *File) Size() int64 {
+	return f.size
+}
@@ -0,0 +1,150 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+type testPart struct {
+	field, filename, contentType, content string
+}
+
+func newRequest(t *testing.T, parts ...testPart) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		header := textproto.MIMEHeader{}
+		if p.filename != "" {
+			header.Set("Content-Disposition", `form-data; name="`+p.field+`"; filename="`+p.filename+`"`)
+		} else {
+			header.Set("Content-Disposition", `form-data; name="`+p.field+`"`)
+		}
+		if p.contentType != "" {
+			header.Set("Content-Type", p.contentType)
+		}
+		w, err := mw.CreatePart(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, p.content)
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+var testLimits = Limits{MaxFileSize: 1 << 10, MaxRequestSize: 1 << 20, MaxFiles: 2}
+
+func TestFormReadsFieldsAndFiles(t *testing.T) {
+	pdf := "%PDF-1.7\n" + strings.Repeat("x", 600)
+	r := newRequest(t,
+		testPart{field: "request_data", content: `{"project_name":"A"}`},
+		testPart{field: "files[]", filename: "spec.pdf", contentType: "application/octet-stream", content: pdf},
+		testPart{field: "files[]", filename: "skipped.txt", content: "not read by the caller"},
+		testPart{field: "body", content: "after files"},
+	)
+	form, err := NewForm(httptest.NewRecorder(), r, testLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := form.NextFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Field != "files[]" || file.Name != "spec.pdf" || file.DeclaredType != "application/octet-stream" {
+		t.Errorf("file = %+v", file)
+	}
+	if file.DetectedType != "application/pdf" {
+		t.Errorf("DetectedType = %q, want application/pdf", file.DetectedType)
+	}
+	content, err := io.ReadAll(file)
+	if err != nil || string(content) != pdf || file.Size() != int64(len(pdf)) {
+		t.Errorf("content = %d bytes, size %d, err %v", len(content), file.Size(), err)
+	}
+
+	// Второй файл не читаем: NextFile должен пропустить его сам
+	if _, err := form.NextFile(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := form.NextFile(); err != io.EOF {
+		t.Fatalf("NextFile at end = %v, want io.EOF", err)
+	}
+	if form.Values["request_data"] != `{"project_name":"A"}` || form.Values["body"] != "after files" {
+		t.Errorf("Values = %v", form.Values)
+	}
+}
+
+func TestFormLimits(t *testing.T) {
+	r := newRequest(t, testPart{field: "f", filename: "big.txt", content: strings.Repeat("a", 2<<10)})
+	form, _ := NewForm(httptest.NewRecorder(), r, testLimits)
+	file, err := form.NextFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(file); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("reading oversized file: %v, want ErrFileTooLarge", err)
+	}
+
+	r = newRequest(t,
+		testPart{field: "f", filename: "1.txt", content: "1"},
+		testPart{field: "f", filename: "2.txt", content: "2"},
+		testPart{field: "f", filename: "3.txt", content: "3"},
+	)
+	form, _ = NewForm(httptest.NewRecorder(), r, testLimits)
+	var err3 error
+	for range 3 {
+		if _, err3 = form.NextFile(); err3 != nil {
+			break
+		}
+	}
+	if !errors.Is(err3, ErrTooManyFiles) {
+		t.Errorf("third file: %v, want ErrTooManyFiles", err3)
+	}
+
+	r = newRequest(t, testPart{field: "f", filename: "1.txt", content: strings.Repeat("a", 900)})
+	form, _ = NewForm(httptest.NewRecorder(), r, Limits{MaxFileSize: 1 << 10, MaxRequestSize: 512, MaxFiles: 1})
+	if form != nil {
+		t.Fatal("NewForm accepted request with Content-Length over the limit")
+	}
+	r.ContentLength = -1 // Размер тела заранее неизвестен
+	form, err = NewForm(httptest.NewRecorder(), r, Limits{MaxFileSize: 1 << 10, MaxRequestSize: 512, MaxFiles: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var maxBytes *http.MaxBytesError
+	if file, err = form.NextFile(); err == nil {
+		_, err = io.ReadAll(file)
+	}
+	if !errors.As(err, &maxBytes) {
+		t.Errorf("oversized request: %v, want *http.MaxBytesError", err)
+	}
+}
+
+func TestNewFormRejectsOtherContentTypes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}"))
+	r.Header.Set("Content-Type", "application/json")
+	if _, err := NewForm(httptest.NewRecorder(), r, testLimits); !errors.Is(err, ErrNotMultipart) {
+		t.Errorf("NewForm(json) = %v, want ErrNotMultipart", err)
+	}
+}
+
+func TestLimitsFromEnv(t *testing.T) {
+	t.Setenv("UPLOAD_MAX_FILE_SIZE_MB", "2")
+	t.Setenv("UPLOAD_MAX_REQUEST_SIZE_MB", "bogus")
+	t.Setenv("UPLOAD_MAX_FILES", "3")
+	got := LimitsFromEnv()
+	want := Limits{MaxFileSize: 2 << 20, MaxRequestSize: 50 << 20, MaxFiles: 3}
+	if got != want {
+		t.Errorf("LimitsFromEnv = %+v, want %+v", got, want)
+	}
+}
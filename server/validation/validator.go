@@ -3,7 +3,12 @@ package validation
 import (
 	"errors"
 	"regexp"
+	"strconv"
 	"time"
+	"unicode/utf8"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+	"github.com/eeephemera/zvk-requests/server/workflow"
 )
 
 // Основные ошибки валидации
@@ -16,13 +21,23 @@ var (
 
 // Регулярные выражения для валидации
 var (
-	innRegex           = regexp.MustCompile(`^\d{10}$|^\d{12}$`)
-	emailRegex         = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
-	phoneRegex         = regexp.MustCompile(`^\+7[0-9]{10}$`)
-	latinCyrillicRegex = regexp.MustCompile(`^[a-zA-Zа-яА-ЯёЁ0-9\s\-]+$`)
+	innRegex   = regexp.MustCompile(`^\d{10}$|^\d{12}$`)
+	kppRegex   = regexp.MustCompile(`^\d{4}[\dA-Z]{2}\d{3}$`)
+	ogrnRegex  = regexp.MustCompile(`^\d{13}$|^\d{15}$`)
+	emailRegex = regexp.MustCompile(`^[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}$`)
+	phoneRegex = regexp.MustCompile(`^\+7[0-9]{10}$`)
+	// Названия организаций содержат кавычки и сокращения: ООО "Ромашка", ИП Иванов И.И.
+	orgNameRegex = regexp.MustCompile(`^[a-zA-Zа-яА-ЯёЁ0-9\s\-"'«».,&()№+]+$`)
 )
 
-// ValidateINN проверяет корректность ИНН
+// Весовые коэффициенты контрольных цифр ИНН
+var (
+	innWeights10   = []int{2, 4, 10, 3, 5, 9, 4, 6, 8}
+	innWeights12_1 = []int{7, 2, 4, 10, 3, 5, 9, 4, 6, 8}
+	innWeights12_2 = []int{3, 7, 2, 4, 10, 3, 5, 9, 4, 6, 8}
+)
+
+// ValidateINN проверяет формат и контрольные цифры ИНН
 func ValidateINN(inn string) error {
 	if inn == "" {
 		return ErrRequired
@@ -30,6 +45,83 @@ func ValidateINN(inn string) error {
 	if !innRegex.MatchString(inn) {
 		return errors.New("ИНН должен содержать 10 или 12 цифр")
 	}
+	var valid bool
+	if len(inn) == 10 {
+		valid = innControlDigit(inn, innWeights10) == inn[9]
+	} else {
+		valid = innControlDigit(inn, innWeights12_1) == inn[10] &&
+			innControlDigit(inn, innWeights12_2) == inn[11]
+	}
+	if !valid {
+		return errors.New("неверное контрольное число ИНН")
+	}
+	return nil
+}
+
+// innControlDigit вычисляет контрольную цифру по первым len(weights) цифрам ИНН
+func innControlDigit(inn string, weights []int) byte {
+	sum := 0
+	for i, w := range weights {
+		sum += int(inn[i]-'0') * w
+	}
+	return byte('0' + sum%11%10)
+}
+
+// DetectOrgType проверяет ИНН и определяет по нему тип организации:
+// 10 цифр — юридическое лицо, 12 — индивидуальный предприниматель
+func DetectOrgType(inn string) (models.OrgType, error) {
+	if err := ValidateINN(inn); err != nil {
+		return "", err
+	}
+	if len(inn) == 10 {
+		return models.OrgTypeLegalEntity, nil
+	}
+	return models.OrgTypeIndividualEntrepreneur, nil
+}
+
+// ValidateKPP проверяет КПП (необязательный). КПП есть только у юридических лиц
+func ValidateKPP(kpp string, orgType models.OrgType) error {
+	if kpp == "" {
+		return nil
+	}
+	if orgType == models.OrgTypeIndividualEntrepreneur {
+		return errors.New("у индивидуального предпринимателя нет КПП")
+	}
+	if !kppRegex.MatchString(kpp) {
+		return errors.New("КПП должен содержать 9 символов: 4 цифры, 2 цифры или заглавные латинские буквы, 3 цифры")
+	}
+	return nil
+}
+
+// ValidateOGRN проверяет ОГРН (13 цифр) или ОГРНИП (15 цифр), необязательный.
+// Длина должна соответствовать типу организации.
+func ValidateOGRN(ogrn string, orgType models.OrgType) error {
+	if ogrn == "" {
+		return nil
+	}
+	if !ogrnRegex.MatchString(ogrn) {
+		return errors.New("ОГРН должен содержать 13 цифр, ОГРНИП — 15 цифр")
+	}
+	switch {
+	case orgType == models.OrgTypeLegalEntity && len(ogrn) != 13:
+		return errors.New("у юридического лица ОГРН из 13 цифр")
+	case orgType == models.OrgTypeIndividualEntrepreneur && len(ogrn) != 15:
+		return errors.New("у индивидуального предпринимателя ОГРНИП из 15 цифр")
+	}
+
+	// Контрольная цифра — остаток от деления числа без нее на 11 (ОГРН) или 13 (ОГРНИП), взятый по модулю 10
+	divisor := uint64(11)
+	if len(ogrn) == 15 {
+		divisor = 13
+	}
+	last := len(ogrn) - 1
+	number, err := strconv.ParseUint(ogrn[:last], 10, 64)
+	if err != nil {
+		return ErrInvalidFormat
+	}
+	if byte('0'+number%divisor%10) != ogrn[last] {
+		return errors.New("неверное контрольное число ОГРН")
+	}
 	return nil
 }
 
@@ -60,15 +152,27 @@ func ValidateOrgName(name string) error {
 	if name == "" {
 		return ErrRequired
 	}
-	if len(name) < 2 || len(name) > 255 {
+	if n := utf8.RuneCountInString(name); n < 2 || n > 255 {
 		return errors.New("название организации должно содержать от 2 до 255 символов")
 	}
-	if !latinCyrillicRegex.MatchString(name) {
-		return errors.New("название организации может содержать только буквы, цифры, пробелы и дефисы")
+	if !orgNameRegex.MatchString(name) {
+		return errors.New("название организации может содержать только буквы, цифры, пробелы, кавычки и знаки . , - & ( ) № +")
 	}
 	return nil
 }
 
+// ValidatePartnerStatus проверяет статус партнера
+func ValidatePartnerStatus(status string) error {
+	if status == "" {
+		return ErrRequired
+	}
+	switch status {
+	case models.PartnerStatusActive, models.PartnerStatusPending, models.PartnerStatusInactive:
+		return nil
+	}
+	return errors.New("статус партнера должен быть active, pending или inactive")
+}
+
 // ValidateFZType проверяет тип ФЗ
 func ValidateFZType(fzType string) error {
 	if fzType == "" {
@@ -114,16 +218,7 @@ func ValidateImplementationDate(date string) error {
 
 // ValidateRequestStatus проверяет, является ли статус допустимым
 func ValidateRequestStatus(status string) error {
-	validStatuses := map[string]struct{}{
-		"На рассмотрении": {},
-		"В работе":        {},
-		"На уточнении":    {},
-		"Одобрена":        {},
-		"Отклонена":       {},
-		"Завершена":       {},
-	}
-
-	if _, ok := validStatuses[status]; !ok {
+	if _, ok := workflow.Parse(status); !ok {
 		return errors.New("недопустимый статус заявки")
 	}
 	return nil
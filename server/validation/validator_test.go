@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+func TestValidateINN(t *testing.T) {
+	tests := []struct {
+		name  string
+		inn   string
+		valid bool
+	}{
+		{"LegalEntity", "7707083893", true},
+		{"Individual", "500100732259", true},
+		{"LegalEntityBadChecksum", "7707083894", false},
+		{"IndividualBadFirstChecksum", "500100732269", false},
+		{"IndividualBadSecondChecksum", "500100732258", false},
+		{"WrongLength", "77070838", false},
+		{"Letters", "770708389A", false},
+		{"Empty", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateINN(tc.inn)
+			if (err == nil) != tc.valid {
+				t.Errorf("ValidateINN(%q) = %v, want valid=%v", tc.inn, err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestDetectOrgType(t *testing.T) {
+	tests := []struct {
+		inn  string
+		want models.OrgType
+		ok   bool
+	}{
+		{"7707083893", models.OrgTypeLegalEntity, true},
+		{"500100732259", models.OrgTypeIndividualEntrepreneur, true},
+		{"7707083894", "", false},
+	}
+
+	for _, tc := range tests {
+		got, err := DetectOrgType(tc.inn)
+		if got != tc.want || (err == nil) != tc.ok {
+			t.Errorf("DetectOrgType(%q) = (%q, %v), want (%q, ok=%v)", tc.inn, got, err, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestValidateKPP(t *testing.T) {
+	tests := []struct {
+		name    string
+		kpp     string
+		orgType models.OrgType
+		valid   bool
+	}{
+		{"Digits", "773601001", models.OrgTypeLegalEntity, true},
+		{"ReasonLetters", "7736AB001", models.OrgTypeLegalEntity, true},
+		{"Empty", "", models.OrgTypeIndividualEntrepreneur, true},
+		{"Individual", "773601001", models.OrgTypeIndividualEntrepreneur, false},
+		{"Short", "77360100", models.OrgTypeLegalEntity, false},
+		{"Lowercase", "7736ab001", models.OrgTypeLegalEntity, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateKPP(tc.kpp, tc.orgType)
+			if (err == nil) != tc.valid {
+				t.Errorf("ValidateKPP(%q, %q) = %v, want valid=%v", tc.kpp, tc.orgType, err, tc.valid)
+			}
+		})
+	}
+}
+
+func TestValidateOGRN(t *testing.T) {
+	tests := []struct {
+		name    string
+		ogrn    string
+		orgType models.OrgType
+		valid   bool
+	}{
+		{"LegalEntity", "1027700132195", models.OrgTypeLegalEntity, true},
+		{"Individual", "304770100100019", models.OrgTypeIndividualEntrepreneur, true},
+		{"Empty", "", models.OrgTypeLegalEntity, true},
+		{"BadChecksum", "1027700132196", models.OrgTypeLegalEntity, false},
+		{"IndividualBadChecksum", "304770100100018", models.OrgTypeIndividualEntrepreneur, false},
+		{"LengthMismatchesType", "1027700132195", models.OrgTypeIndividualEntrepreneur, false},
+		{"WrongLength", "10277001321", models.OrgTypeLegalEntity, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateOGRN(tc.ogrn, tc.orgType)
+			if (err == nil) != tc.valid {
+				t.Errorf("ValidateOGRN(%q, %q) = %v, want valid=%v", tc.ogrn, tc.orgType, err, tc.valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+// Package workflow описывает жизненный цикл заявки: допустимые статусы
+// и разрешённые переходы между ними с учётом роли пользователя.
+// Это единственный источник правды для статусов на сервере.
+package workflow
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+// Transition описывает разрешённый переход между статусами.
+type Transition struct {
+	From  models.RequestStatus `json:"from"`
+	To    models.RequestStatus `json:"to"`
+	Roles []models.UserRole    `json:"roles"` // Роли, которым разрешён переход
+}
+
+// StatusInfo описывает статус для клиентов API.
+type StatusInfo struct {
+	Value   models.RequestStatus `json:"value"`
+	Initial bool                 `json:"initial"`
+	Final   bool                 `json:"final"`
+}
+
+// Graph — полное описание жизненного цикла заявки.
+type Graph struct {
+	Statuses    []StatusInfo `json:"statuses"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// TransitionError возвращается при попытке выполнить недопустимый переход.
+type TransitionError struct {
+	From    models.RequestStatus
+	To      models.RequestStatus
+	Allowed []models.RequestStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("transition from %q to %q is not allowed", e.From, e.To)
+}
+
+// statuses перечислены в порядке, в котором их удобно показывать пользователю.
+var statuses = []models.RequestStatus{
+	models.StatusPending,
+	models.StatusInProgress,
+	models.StatusClarify,
+	models.StatusApproved,
+	models.StatusRejected,
+	models.StatusCompleted,
+	models.StatusExpired,
+}
+
+var (
+	managerOnly = []models.UserRole{models.RoleManager}
+	partnerOnly = []models.UserRole{models.RoleUser}
+	// Переход выполняет только сервер (фоновая задача), пользователям он недоступен
+	systemOnly = []models.UserRole{}
+)
+
+// transitions — граф разрешённых переходов.
+var transitions = []Transition{
+	{From: models.StatusPending, To: models.StatusInProgress, Roles: managerOnly},
+	{From: models.StatusPending, To: models.StatusClarify, Roles: managerOnly},
+	{From: models.StatusPending, To: models.StatusApproved, Roles: managerOnly},
+	{From: models.StatusPending, To: models.StatusRejected, Roles: managerOnly},
+
+	{From: models.StatusInProgress, To: models.StatusClarify, Roles: managerOnly},
+	{From: models.StatusInProgress, To: models.StatusApproved, Roles: managerOnly},
+	{From: models.StatusInProgress, To: models.StatusRejected, Roles: managerOnly},
+
+	// Из "Требует уточнения" заявку возвращает только партнёр, повторно отправляя её
+	{From: models.StatusClarify, To: models.StatusPending, Roles: partnerOnly},
+
+	{From: models.StatusApproved, To: models.StatusCompleted, Roles: managerOnly},
+	// По окончании срока защиты (protected_until или ожидаемой даты закрытия)
+	{From: models.StatusApproved, To: models.StatusExpired, Roles: systemOnly},
+}
+
+// legacyAliases — значения, которые исторически принимались API и остались в request_status_enum.
+var legacyAliases = map[string]models.RequestStatus{
+	"На уточнении": models.StatusClarify,
+	"Одобрена":     models.StatusApproved,
+	"Отклонена":    models.StatusRejected,
+	"Завершена":    models.StatusCompleted,
+}
+
+// Statuses возвращает список всех допустимых статусов.
+func Statuses() []models.RequestStatus {
+	return slices.Clone(statuses)
+}
+
+// IsValid проверяет, является ли статус допустимым (без учёта устаревших синонимов).
+func IsValid(status models.RequestStatus) bool {
+	return slices.Contains(statuses, status)
+}
+
+// Parse приводит строку к допустимому статусу, принимая устаревшие синонимы.
+func Parse(value string) (models.RequestStatus, bool) {
+	status := models.RequestStatus(value)
+	if IsValid(status) {
+		return status, true
+	}
+	if alias, ok := legacyAliases[value]; ok {
+		return alias, true
+	}
+	return "", false
+}
+
+// Spellings возвращает статус и его устаревшие синонимы — все значения request_status_enum,
+// которыми статус может быть записан в базе.
+func Spellings(status models.RequestStatus) []string {
+	values := []string{string(status)}
+	for alias, s := range legacyAliases {
+		if s == status {
+			values = append(values, alias)
+		}
+	}
+	slices.Sort(values[1:])
+	return values
+}
+
+// IsFinal сообщает, что из статуса нет ни одного перехода.
+func IsFinal(status models.RequestStatus) bool {
+	for _, t := range transitions {
+		if t.From == status {
+			return false
+		}
+	}
+	return true
+}
+
+// IsRegistered сообщает, что сделка по заявке одобрена и зарегистрирована за партнером:
+// заявка одобрена или уже выполнена. Учитывает устаревшие написания статусов.
+func IsRegistered(status models.RequestStatus) bool {
+	status, _ = Parse(string(status))
+	return status == models.StatusApproved || status == models.StatusCompleted
+}
+
+// ActiveStatuses возвращает статусы незавершенных заявок — те, из которых еще возможны переходы.
+func ActiveStatuses() []models.RequestStatus {
+	active := []models.RequestStatus{}
+	for _, s := range statuses {
+		if !IsFinal(s) {
+			active = append(active, s)
+		}
+	}
+	return active
+}
+
+// AllowedTransitions возвращает статусы, в которые роль может перевести заявку из from.
+func AllowedTransitions(from models.RequestStatus, role models.UserRole) []models.RequestStatus {
+	allowed := []models.RequestStatus{}
+	for _, t := range transitions {
+		if t.From == from && slices.Contains(t.Roles, role) {
+			allowed = append(allowed, t.To)
+		}
+	}
+	return allowed
+}
+
+// CheckTransition возвращает *TransitionError, если роль не может перевести заявку из from в to.
+func CheckTransition(from, to models.RequestStatus, role models.UserRole) error {
+	allowed := AllowedTransitions(from, role)
+	if slices.Contains(allowed, to) {
+		return nil
+	}
+	return &TransitionError{From: from, To: to, Allowed: allowed}
+}
+
+// Describe возвращает граф статусов для отдачи клиентам.
+func Describe() Graph {
+	graph := Graph{
+		Statuses:    make([]StatusInfo, 0, len(statuses)),
+		Transitions: slices.Clone(transitions),
+	}
+	for _, s := range statuses {
+		graph.Statuses = append(graph.Statuses, StatusInfo{
+			Value:   s,
+			Initial: s == models.StatusPending,
+			Final:   IsFinal(s),
+		})
+	}
+	return graph
+}
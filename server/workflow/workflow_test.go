@@ -0,0 +1,125 @@
+package workflow
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/eeephemera/zvk-requests/server/models"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  models.RequestStatus
+		ok    bool
+	}{
+		{"Canonical", "Одобрено", models.StatusApproved, true},
+		{"LegacyApproved", "Одобрена", models.StatusApproved, true},
+		{"LegacyClarify", "На уточнении", models.StatusClarify, true},
+		{"LegacyCompleted", "Завершена", models.StatusCompleted, true},
+		{"Unknown", "Удалено", "", false},
+		{"Empty", "", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := Parse(tc.input)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("Parse(%q) = (%q, %v), want (%q, %v)", tc.input, got, ok, tc.want, tc.ok)
+			}
+		})
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    models.RequestStatus
+		to      models.RequestStatus
+		role    models.UserRole
+		allowed bool
+	}{
+		{"ManagerApprovesPending", models.StatusPending, models.StatusApproved, models.RoleManager, true},
+		{"ManagerAsksClarification", models.StatusInProgress, models.StatusClarify, models.RoleManager, true},
+		{"PartnerResubmits", models.StatusClarify, models.StatusPending, models.RoleUser, true},
+		{"ManagerCannotResubmit", models.StatusClarify, models.StatusPending, models.RoleManager, false},
+		{"PartnerCannotApprove", models.StatusPending, models.StatusApproved, models.RoleUser, false},
+		{"NothingOutOfCompleted", models.StatusCompleted, models.StatusPending, models.RoleManager, false},
+		{"ManagerCannotExpire", models.StatusApproved, models.StatusExpired, models.RoleManager, false},
+		{"NothingOutOfExpired", models.StatusExpired, models.StatusApproved, models.RoleManager, false},
+		{"SameStatus", models.StatusPending, models.StatusPending, models.RoleManager, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckTransition(tc.from, tc.to, tc.role)
+			if tc.allowed && err != nil {
+				t.Errorf("CheckTransition(%q, %q, %s) returned error: %v", tc.from, tc.to, tc.role, err)
+			}
+			if !tc.allowed {
+				var transitionErr *TransitionError
+				if !errors.As(err, &transitionErr) {
+					t.Fatalf("CheckTransition(%q, %q, %s) = %v, want *TransitionError", tc.from, tc.to, tc.role, err)
+				}
+				if transitionErr.From != tc.from || transitionErr.To != tc.to {
+					t.Errorf("TransitionError has From=%q To=%q", transitionErr.From, transitionErr.To)
+				}
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	graph := Describe()
+	if len(graph.Statuses) != len(statuses) {
+		t.Fatalf("Describe() returned %d statuses, want %d", len(graph.Statuses), len(statuses))
+	}
+
+	for _, s := range graph.Statuses {
+		if s.Value == models.StatusPending && !s.Initial {
+			t.Errorf("status %q should be initial", s.Value)
+		}
+		if (s.Value == models.StatusCompleted || s.Value == models.StatusExpired) && !s.Final {
+			t.Errorf("status %q should be final", s.Value)
+		}
+	}
+
+	// Все переходы должны ссылаться только на известные статусы
+	for _, tr := range graph.Transitions {
+		if !IsValid(tr.From) || !IsValid(tr.To) {
+			t.Errorf("transition %q -> %q references unknown status", tr.From, tr.To)
+		}
+	}
+}
+
+func TestActiveStatuses(t *testing.T) {
+	active := ActiveStatuses()
+	want := []models.RequestStatus{models.StatusPending, models.StatusInProgress, models.StatusClarify, models.StatusApproved}
+	if !slices.Equal(active, want) {
+		t.Errorf("ActiveStatuses() = %v, want %v", active, want)
+	}
+}
+
+func TestIsRegistered(t *testing.T) {
+	for _, status := range []models.RequestStatus{models.StatusApproved, models.StatusCompleted, "Одобрена", "Завершена"} {
+		if !IsRegistered(status) {
+			t.Errorf("IsRegistered(%q) = false, want true", status)
+		}
+	}
+	for _, status := range []models.RequestStatus{models.StatusPending, models.StatusRejected, models.StatusExpired, "unknown"} {
+		if IsRegistered(status) {
+			t.Errorf("IsRegistered(%q) = true, want false", status)
+		}
+	}
+}
+
+func TestSpellings(t *testing.T) {
+	if got, want := Spellings(models.StatusApproved), []string{"Одобрено", "Одобрена"}; !slices.Equal(got, want) {
+		t.Errorf("Spellings(Approved) = %v, want %v", got, want)
+	}
+	if got, want := Spellings(models.StatusPending), []string{"На рассмотрении"}; !slices.Equal(got, want) {
+		t.Errorf("Spellings(Pending) = %v, want %v", got, want)
+	}
+}
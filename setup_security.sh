@@ -29,6 +29,9 @@ DB_SSLMODE=disable
 JWT_SECRET=$(openssl rand -base64 32)
 JWT_EXPIRATION=24h
 
+# Certificate verification codes (must differ from JWT_SECRET)
+CERTIFICATE_SECRET=$(openssl rand -base64 32)
+
 # Server settings
 SERVER_PORT=8081
 APP_ENV=production